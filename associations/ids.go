@@ -0,0 +1,84 @@
+package associations
+
+import "slices"
+
+// Ids is the set of ids of the objects associated to an object through a
+// join table. The ids added and removed since it was loaded or last written
+// are tracked so only the changed rows of the join table are written, the
+// changes of a set that was never loaded are written as well.
+type Ids[K comparable] struct {
+	ids     []K
+	added   []K
+	removed []K
+	loaded  bool
+}
+
+// Load sets the ids read from the join table, the pending
+// changes are applied on top of them.
+func (a *Ids[K]) Load(ids []K) {
+	a.ids = slices.DeleteFunc(slices.Clone(ids), func(id K) bool {
+		return slices.Contains(a.removed, id)
+	})
+	for _, id := range a.added {
+		if !slices.Contains(a.ids, id) {
+			a.ids = append(a.ids, id)
+		}
+	}
+	a.loaded = true
+}
+
+func (a Ids[K]) Loaded() bool {
+	return a.loaded
+}
+
+// Ids returns the associated ids, only the added ones if it was never loaded.
+func (a Ids[K]) Ids() []K {
+	return slices.Clone(a.ids)
+}
+
+func (a Ids[K]) Contains(id K) bool {
+	return slices.Contains(a.ids, id)
+}
+
+func (a *Ids[K]) Add(id K) {
+	if slices.Contains(a.ids, id) {
+		return
+	}
+	a.ids = append(a.ids, id)
+	if i := slices.Index(a.removed, id); i >= 0 {
+		a.removed = slices.Delete(a.removed, i, i+1)
+		return
+	}
+	a.added = append(a.added, id)
+}
+
+func (a *Ids[K]) Remove(id K) {
+	if i := slices.Index(a.added, id); i >= 0 {
+		a.added = slices.Delete(a.added, i, i+1)
+		a.ids = slices.DeleteFunc(a.ids, func(v K) bool { return v == id })
+		return
+	}
+	if slices.Contains(a.removed, id) {
+		return
+	}
+	a.ids = slices.DeleteFunc(a.ids, func(v K) bool { return v == id })
+	a.removed = append(a.removed, id)
+}
+
+// Changes returns the ids added and removed since the
+// set was loaded or last written to the join table.
+func (a Ids[K]) Changes() (added []any, removed []any) {
+	for _, v := range a.added {
+		added = append(added, v)
+	}
+	for _, v := range a.removed {
+		removed = append(removed, v)
+	}
+	return added, removed
+}
+
+// Commit marks the changes as written to the join table.
+func (a *Ids[K]) Commit() {
+	a.added = nil
+	a.removed = nil
+}
@@ -0,0 +1,29 @@
+package associations
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestIds(t *testing.T) {
+	var a Ids[string]
+	a.Add("a")
+	a.Add("b")
+	a.Remove("b")
+	a.Remove("c")
+	added, removed := a.Changes()
+	if !slices.Equal(added, []any{"a"}) || !slices.Equal(removed, []any{"c"}) {
+		t.Fatalf("unexpected changes %v %v", added, removed)
+	}
+	a.Load([]string{"c", "d"})
+	if !slices.Equal(a.Ids(), []string{"d", "a"}) || !a.Loaded() {
+		t.Fatalf("unexpected ids after load %v", a.Ids())
+	}
+	a.Commit()
+	a.Remove("d")
+	a.Add("d")
+	added, removed = a.Changes()
+	if len(added) != 0 || len(removed) != 0 {
+		t.Fatalf("expected no changes got %v %v", added, removed)
+	}
+}
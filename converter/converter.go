@@ -0,0 +1,181 @@
+package converter
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Converter converts the values of a domain type T to the values
+// written to its column and the values scanned from it back to T.
+type Converter[T any] interface {
+	ToDB(v T) (any, error)
+	FromDB(src any) (T, error)
+}
+
+type funcConverter[T any] struct {
+	toDB   func(v T) (any, error)
+	fromDB func(src any) (T, error)
+}
+
+func (c funcConverter[T]) ToDB(v T) (any, error) {
+	return c.toDB(v)
+}
+
+func (c funcConverter[T]) FromDB(src any) (T, error) {
+	return c.fromDB(src)
+}
+
+// Func returns a Converter made of the two conversion functions.
+func Func[T any](toDB func(v T) (any, error), fromDB func(src any) (T, error)) Converter[T] {
+	return funcConverter[T]{toDB: toDB, fromDB: fromDB}
+}
+
+var (
+	converters = map[string]any{
+		"duration": Duration(),
+	}
+	mu sync.RWMutex
+)
+
+// Register makes the converter available to the data mappers under name,
+// the name referenced by the converter of the fields in the configuration.
+func Register[T any](name string, c Converter[T]) {
+	mu.Lock()
+	defer mu.Unlock()
+	converters[name] = c
+}
+
+func lookup[T any](name string) (Converter[T], error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	v, ok := converters[name]
+	if !ok {
+		return nil, fmt.Errorf("the converter %s is not registered", name)
+	}
+	c, ok := v.(Converter[T])
+	if !ok {
+		var zero [0]T
+		return nil, fmt.Errorf("the converter %s does not convert values of type %v", name, reflect.TypeOf(zero).Elem())
+	}
+	return c, nil
+}
+
+func ToDB[T any](name string, v T) (any, error) {
+	c, err := lookup[T](name)
+	if err != nil {
+		return nil, err
+	}
+	value, err := c.ToDB(v)
+	if err != nil {
+		return nil, fmt.Errorf("error at converter %s ToDB %w", name, err)
+	}
+	return value, nil
+}
+
+func FromDB[T any](name string, src any) (T, error) {
+	c, err := lookup[T](name)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	value, err := c.FromDB(src)
+	if err != nil {
+		return value, fmt.Errorf("error at converter %s FromDB %w", name, err)
+	}
+	return value, nil
+}
+
+// Duration writes a time.Duration as a bigint of nanoseconds.
+func Duration() Converter[time.Duration] {
+	return Func(
+		func(v time.Duration) (any, error) {
+			return int64(v), nil
+		},
+		func(src any) (time.Duration, error) {
+			switch src := src.(type) {
+			case nil:
+				return 0, nil
+			case int64:
+				return time.Duration(src), nil
+			default:
+				return 0, fmt.Errorf("can't convert %T to time.Duration", src)
+			}
+		},
+	)
+}
+
+// Int writes the named integer types, such as enums, as their underlying integer.
+func Int[T ~int | ~int8 | ~int16 | ~int32 | ~int64]() Converter[T] {
+	return Func(
+		func(v T) (any, error) {
+			return int64(v), nil
+		},
+		func(src any) (T, error) {
+			switch src := src.(type) {
+			case nil:
+				return 0, nil
+			case int16:
+				return T(src), nil
+			case int32:
+				return T(src), nil
+			case int64:
+				return T(src), nil
+			default:
+				return 0, fmt.Errorf("can't convert %T to %T", src, T(0))
+			}
+		},
+	)
+}
+
+// String writes the named string types as text.
+func String[T ~string]() Converter[T] {
+	return Func(
+		func(v T) (any, error) {
+			return string(v), nil
+		},
+		func(src any) (T, error) {
+			switch src := src.(type) {
+			case nil:
+				return "", nil
+			case string:
+				return T(src), nil
+			default:
+				return "", fmt.Errorf("can't convert %T to %T", src, T(""))
+			}
+		},
+	)
+}
+
+// Enum writes the constants of T as the labels of a PostgreSQL enum.
+func Enum[T comparable](labels map[T]string) Converter[T] {
+	values := make(map[string]T, len(labels))
+	for k, v := range labels {
+		values[v] = k
+	}
+	return Func(
+		func(v T) (any, error) {
+			label, ok := labels[v]
+			if !ok {
+				return nil, fmt.Errorf("%v is not a constant of the enum", v)
+			}
+			return label, nil
+		},
+		func(src any) (T, error) {
+			var zero T
+			switch src := src.(type) {
+			case nil:
+				return zero, nil
+			case string:
+				v, ok := values[src]
+				if !ok {
+					return zero, fmt.Errorf("the label %s does not match any constant of %T", src, zero)
+				}
+				return v, nil
+			default:
+				return zero, fmt.Errorf("can't convert %T to %T", src, zero)
+			}
+		},
+	)
+}
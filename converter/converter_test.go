@@ -0,0 +1,67 @@
+package converter
+
+import (
+	"testing"
+	"time"
+)
+
+type status int
+
+func TestRegistry(t *testing.T) {
+	Register("status", Int[status]())
+	value, err := ToDB("status", status(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != int64(2) {
+		t.Fatalf("expected int64 2 got %T %v", value, value)
+	}
+	s, err := FromDB[status]("status", int32(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != 3 {
+		t.Fatalf("expected 3 got %v", s)
+	}
+	_, err = FromDB[time.Duration]("status", int64(1))
+	if err == nil {
+		t.Fatal("expected an error converting with a converter of another type")
+	}
+	_, err = ToDB("missing", 1)
+	if err == nil {
+		t.Fatal("expected an error for a converter that is not registered")
+	}
+	d, err := FromDB[time.Duration]("duration", int64(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != time.Second {
+		t.Fatalf("expected %v got %v", time.Second, d)
+	}
+}
+
+func TestEnum(t *testing.T) {
+	c := Enum(map[status]string{1: "active", 2: "archived"})
+	value, err := c.ToDB(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "archived" {
+		t.Fatalf("expected archived got %v", value)
+	}
+	_, err = c.ToDB(3)
+	if err == nil {
+		t.Fatal("expected an error for a value that is not a constant")
+	}
+	s, err := c.FromDB("active")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != 1 {
+		t.Fatalf("expected 1 got %v", s)
+	}
+	_, err = c.FromDB("deleted")
+	if err == nil {
+		t.Fatal("expected an error for an unknown label")
+	}
+}
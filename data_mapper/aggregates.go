@@ -0,0 +1,65 @@
+package data_mapper
+
+import (
+	"clearly-not-a-secret-project/query_object"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// The statement of the source without its terminator,
+// to be selected from as a subquery.
+func subquery(source StatementSource) string {
+	return strings.TrimSuffix(strings.TrimSpace(source.Sql()), ";")
+}
+
+// Exists tells whether the row of the id is stored, without loading
+// the object. The ghosts in the identity map are not taken as stored.
+func (d PostgreSQLDataMapper[T, K]) Exists(ctx context.Context, id K) (bool, error) {
+	if d.ExistsStatement == "" {
+		return false, fmt.Errorf("exists is not supported for type %v, it requires an exists statement", d.DomainType)
+	}
+	stmt := &PreparedStatement{
+		conn:  d.executor(ctx),
+		query: d.ExistsStatement,
+		args:  make([]interface{}, 0),
+	}
+	stmt.Append(id)
+	err := d.appendTenant(ctx, stmt)
+	if err != nil {
+		return false, err
+	}
+	var exists bool
+	err = stmt.conn.QueryRow(ctx, stmt.query, stmt.args...).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("error at execute query %w", err)
+	}
+	return exists, nil
+}
+
+// Count returns the number of rows of the source, narrowed
+// like the query objects of FindMany, without loading them.
+func (d PostgreSQLDataMapper[T, K]) Count(ctx context.Context, source StatementSource) (int64, error) {
+	var count int64
+	err := d.Aggregate(ctx, source, query_object.Count(), &count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Aggregate scans into dest the value of the aggregate computed over the
+// rows of the source, narrowed like the query objects of FindMany. The
+// minimum and maximum of no rows are NULL, dest must be able to hold it.
+func (d PostgreSQLDataMapper[T, K]) Aggregate(ctx context.Context, source StatementSource, aggregate query_object.Aggregate, dest any) error {
+	source, err := d.scope(ctx, source)
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf("SELECT %s FROM (%s) AS source;", aggregate.Sql(), subquery(source))
+	err = d.executor(ctx).QueryRow(ctx, query, source.Parameters()...).Scan(dest)
+	if err != nil {
+		return fmt.Errorf("error at execute query %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package data_mapper
+
+import (
+	"clearly-not-a-secret-project/interfaces"
+	"clearly-not-a-secret-project/query_object"
+	"context"
+	"testing"
+)
+
+func TestPostgreSQLDataMapper_Exists(t *testing.T) {
+	d := PostgreSQLDataMapper[interfaces.DomainObject[string], string]{}
+	_, err := d.Exists(context.Background(), "a")
+	if err == nil {
+		t.Fatal("expected an error for a mapper without exists statement")
+	}
+}
+
+func TestPostgreSQLDataMapper_Aggregate(t *testing.T) {
+	d := PostgreSQLDataMapper[interfaces.DomainObject[string], string]{
+		TenantColumn:     "tenant_id",
+		TenantLoadedMaps: make(map[string]map[string]interfaces.DomainObject[string]),
+	}
+	ctx := WithTenant(context.Background(), "acme")
+	_, err := d.Count(ctx, rawSource("SELECT id FROM aggregate;"))
+	if err == nil {
+		t.Fatal("expected an error for a source that can't be scoped")
+	}
+	var amount int64
+	err = d.Aggregate(ctx, rawSource("SELECT id, amount FROM aggregate;"), query_object.Sum("amount"), &amount)
+	if err == nil {
+		t.Fatal("expected an error for a source that can't be scoped")
+	}
+}
@@ -0,0 +1,98 @@
+package data_mapper
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// The operations recorded in the history tables.
+const (
+	insertOperation  = "insert"
+	upsertOperation  = "upsert"
+	updateOperation  = "update"
+	removeOperation  = "remove"
+	restoreOperation = "restore"
+)
+
+// The columns set by the audited updates, after the columns of DoUpdate.
+var auditUpdateColumns = []string{"updated_at", "updated_by"}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx carrying the actor written to the
+// audit columns and history rows by the audited data mappers.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor carried by ctx, if any.
+func ActorFrom(ctx context.Context) (string, bool) {
+	actor, ok := ctx.Value(actorKey{}).(string)
+	return actor, ok
+}
+
+func (d PostgreSQLDataMapper[T, K]) actor(ctx context.Context) (string, error) {
+	actor, ok := ActorFrom(ctx)
+	if !ok || actor == "" {
+		return "", fmt.Errorf("the audited type %v requires an actor in the context", d.DomainType)
+	}
+	return actor, nil
+}
+
+// Appends the audit values to the arguments of an insert, the values of
+// created_at, created_by, updated_at and updated_by, or of an update,
+// the values of updated_at and updated_by.
+func (d PostgreSQLDataMapper[T, K]) appendAudit(ctx context.Context, stmt *PreparedStatement, now time.Time, insert bool) error {
+	if !d.Audited {
+		return nil
+	}
+	actor, err := d.actor(ctx)
+	if err != nil {
+		return err
+	}
+	if insert {
+		stmt.Append(now)
+		stmt.Append(actor)
+	}
+	stmt.Append(now)
+	stmt.Append(actor)
+	return nil
+}
+
+// Runs fn with the transaction carried by ctx or, if there is none, inside
+// a new transaction that is committed when fn succeeds.
+func (d PostgreSQLDataMapper[T, K]) inTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return inTx(ctx, d.Db, fn)
+}
+
+// The history row of the operation on the row id holding its state before the operation.
+func (d PostgreSQLDataMapper[T, K]) historyBefore(id K, operation, actor string) *PreparedStatement {
+	return &PreparedStatement{
+		query: fmt.Sprintf("INSERT INTO %s (row_id, operation, changed_at, changed_by, before) "+
+			"VALUES ($1, $2::text, now(), $3::text, (SELECT to_jsonb(t) FROM %s t WHERE t.ID = $1));",
+			d.HistoryTable, d.Table),
+		args: []interface{}{id, operation, actor},
+	}
+}
+
+// Completes the last history row written in the session with the
+// state of the row id after the operation.
+func (d PostgreSQLDataMapper[T, K]) historyAfter(id K) *PreparedStatement {
+	return &PreparedStatement{
+		query: fmt.Sprintf("UPDATE %s SET after = (SELECT to_jsonb(t) FROM %s t WHERE t.ID = $1) "+
+			"WHERE history_id = currval(pg_get_serial_sequence('%s', 'history_id'));",
+			d.HistoryTable, d.Table, d.HistoryTable),
+		args: []interface{}{id},
+	}
+}
+
+// The history rows of the inserted rows ids holding their inserted state.
+func (d PostgreSQLDataMapper[T, K]) historyInsert(ids []K, operation, actor string) *PreparedStatement {
+	return &PreparedStatement{
+		query: fmt.Sprintf("INSERT INTO %s (row_id, operation, changed_at, changed_by, after) "+
+			"SELECT t.ID, $2::text, now(), $3::text, to_jsonb(t) FROM %s t WHERE t.ID = ANY($1);",
+			d.HistoryTable, d.Table),
+		args: []interface{}{ids, operation, actor},
+	}
+}
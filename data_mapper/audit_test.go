@@ -0,0 +1,64 @@
+package data_mapper
+
+import (
+	"clearly-not-a-secret-project/interfaces"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPostgreSQLDataMapper_appendAudit(t *testing.T) {
+	d := PostgreSQLDataMapper[interfaces.DomainObject[string], string]{Audited: true}
+	now := time.Now()
+	stmt := &PreparedStatement{}
+	err := d.appendAudit(context.Background(), stmt, now, true)
+	if err == nil {
+		t.Fatal("expected an error for a context without actor")
+	}
+	ctx := WithActor(context.Background(), "alice")
+	err = d.appendAudit(ctx, stmt, now, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stmt.args) != 4 || stmt.args[0] != now || stmt.args[1] != "alice" {
+		t.Fatalf("unexpected insert audit arguments %v", stmt.args)
+	}
+	stmt = &PreparedStatement{}
+	err = d.appendAudit(ctx, stmt, now, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stmt.args) != 2 {
+		t.Fatalf("unexpected update audit arguments %v", stmt.args)
+	}
+}
+
+func TestPostgreSQLDataMapper_queueHistory(t *testing.T) {
+	d := PostgreSQLDataMapper[interfaces.DomainObject[string], string]{
+		Table:           "aggregate",
+		RemoveStatement: "DELETE FROM aggregate WHERE ID = $1;",
+		LoadedMap:       make(map[string]interfaces.DomainObject[string]),
+		Audited:         true,
+		HistoryTable:    "aggregate_history",
+	}
+	b := NewBatch()
+	ctx := WithBatch(context.Background(), b)
+	err := d.Remove(ctx, "a")
+	if err == nil {
+		t.Fatal("expected an error for a context without actor")
+	}
+	err = d.Remove(WithActor(ctx, "alice"), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != 3 {
+		t.Fatalf("expected 3 queued statements got %d", b.Len())
+	}
+	expected := []string{"INSERT INTO aggregate_history", "DELETE FROM aggregate", "UPDATE aggregate_history"}
+	for i, v := range b.queued {
+		if !strings.HasPrefix(v.stmt.query, expected[i]) {
+			t.Fatalf("expected statement %d to start with %s got %s", i, expected[i], v.stmt.query)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package data_mapper
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Batcher is implemented by the executors able to send a pgx.Batch,
+// it's satisfied by *pgxpool.Pool, *pgx.Conn and pgx.Tx.
+type Batcher interface {
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+// Batch queues the statements of the data mappers called with a context
+// returned by WithBatch, instead of executing them one by one, and sends all
+// of them in a single round trip.
+// The batch is atomic, the changes the mappers make to their identity maps
+// are applied only after every statement succeeded.
+type Batch struct {
+	queued []*queuedStatement
+}
+
+type queuedStatement struct {
+	stmt      *PreparedStatement
+	object    any
+	returning func(resultSet pgx.Rows) error
+	onSuccess func()
+}
+
+func NewBatch() *Batch {
+	return &Batch{
+		queued: make([]*queuedStatement, 0),
+	}
+}
+
+type batchKey struct{}
+
+// WithBatch returns a copy of ctx carrying b, the inserts, updates and
+// removals of the data mappers called with the returned context are queued
+// into b until it is sent.
+func WithBatch(ctx context.Context, b *Batch) context.Context {
+	return context.WithValue(ctx, batchKey{}, b)
+}
+
+// BatchFrom returns the batch carried by ctx, if any.
+func BatchFrom(ctx context.Context) (*Batch, bool) {
+	b, ok := ctx.Value(batchKey{}).(*Batch)
+	return b, ok
+}
+
+func (b *Batch) Len() int {
+	return len(b.queued)
+}
+
+func (b *Batch) queue(stmt *PreparedStatement, object any, returning func(resultSet pgx.Rows) error, onSuccess func()) {
+	b.queued = append(b.queued, &queuedStatement{
+		stmt:      stmt,
+		object:    object,
+		returning: returning,
+		onSuccess: onSuccess,
+	})
+}
+
+// Send sends all the queued statements in one round trip and empties the
+// batch. If any statement fails it returns a *BatchError with an error for
+// each failed statement and the domain object it was queued for.
+func (b *Batch) Send(ctx context.Context, db Batcher) error {
+	if len(b.queued) == 0 {
+		return nil
+	}
+	queued := b.queued
+	b.queued = make([]*queuedStatement, 0)
+	batch := &pgx.Batch{}
+	for _, v := range queued {
+		batch.Queue(v.stmt.query, v.stmt.args...)
+	}
+	results := db.SendBatch(ctx, batch)
+	batchErr := &BatchError{}
+	for i, v := range queued {
+		err := v.result(results)
+		if err != nil {
+			batchErr.Errors = append(batchErr.Errors, &StatementError{
+				Index:  i,
+				Query:  v.stmt.query,
+				Object: v.object,
+				Err:    err,
+			})
+		}
+	}
+	err := results.Close()
+	if err != nil && len(batchErr.Errors) == 0 {
+		return fmt.Errorf("error at closing the batch results %w", err)
+	}
+	if len(batchErr.Errors) > 0 {
+		return batchErr
+	}
+	for _, v := range queued {
+		if v.onSuccess != nil {
+			v.onSuccess()
+		}
+	}
+	return nil
+}
+
+func (v *queuedStatement) result(results pgx.BatchResults) error {
+	if v.returning == nil {
+		_, err := results.Exec()
+		return err
+	}
+	resultSet, err := results.Query()
+	if err != nil {
+		return err
+	}
+	defer resultSet.Close()
+	if !resultSet.Next() {
+		if resultSet.Err() != nil {
+			return resultSet.Err()
+		}
+		return errNoReturnedRows
+	}
+	err = v.returning(resultSet)
+	if err != nil {
+		return fmt.Errorf("error at doReturning %w", err)
+	}
+	resultSet.Close()
+	return resultSet.Err()
+}
+
+// StatementError is the error of a single statement of a batch.
+type StatementError struct {
+	Index  int
+	Query  string
+	Object any
+	Err    error
+}
+
+func (e *StatementError) Error() string {
+	return fmt.Sprintf("statement %d %s for %v: %v", e.Index, e.Query, e.Object, e.Err)
+}
+
+func (e *StatementError) Unwrap() error {
+	return e.Err
+}
+
+type BatchError struct {
+	Errors []*StatementError
+}
+
+func (e *BatchError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, v := range e.Errors {
+		messages = append(messages, v.Error())
+	}
+	return fmt.Sprintf("batch failed:\n%s", strings.Join(messages, "\n"))
+}
+
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, v := range e.Errors {
+		errs = append(errs, v)
+	}
+	return errs
+}
@@ -0,0 +1,81 @@
+package data_mapper
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type fakeBatcher struct {
+	failAt int
+	sent   *pgx.Batch
+}
+
+func (f *fakeBatcher) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	f.sent = b
+	return &fakeBatchResults{failAt: f.failAt}
+}
+
+type fakeBatchResults struct {
+	pgx.BatchResults
+	failAt int
+	index  int
+}
+
+func (r *fakeBatchResults) Exec() (pgconn.CommandTag, error) {
+	defer func() { r.index++ }()
+	if r.index == r.failAt {
+		return pgconn.CommandTag{}, errors.New("duplicate key")
+	}
+	return pgconn.NewCommandTag("UPDATE 1"), nil
+}
+
+func (r *fakeBatchResults) Close() error {
+	return nil
+}
+
+func TestBatch_Send(t *testing.T) {
+	ctx := context.Background()
+	t.Run("Success", func(t *testing.T) {
+		b := NewBatch()
+		applied := 0
+		for _, v := range []string{"a", "b"} {
+			b.queue(&PreparedStatement{query: "UPDATE aggregate SET name = $2 WHERE ID = $1", args: []any{v, v}}, v, nil, func() { applied++ })
+		}
+		db := &fakeBatcher{failAt: -1}
+		err := b.Send(ctx, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if db.sent.Len() != 2 {
+			t.Fatalf("expected 2 queued statements got %d", db.sent.Len())
+		}
+		if applied != 2 {
+			t.Fatalf("expected 2 applied statements got %d", applied)
+		}
+		if b.Len() != 0 {
+			t.Fatalf("expected an empty batch after send got %d statements", b.Len())
+		}
+	})
+	t.Run("Error", func(t *testing.T) {
+		b := NewBatch()
+		applied := 0
+		for _, v := range []string{"a", "b", "c"} {
+			b.queue(&PreparedStatement{query: "DELETE FROM aggregate WHERE ID = $1", args: []any{v}}, v, nil, func() { applied++ })
+		}
+		err := b.Send(ctx, &fakeBatcher{failAt: 1})
+		var batchErr *BatchError
+		if !errors.As(err, &batchErr) {
+			t.Fatalf("expected a batch error got %v", err)
+		}
+		if len(batchErr.Errors) != 1 || batchErr.Errors[0].Object != "b" {
+			t.Fatalf("expected the error to be attributed to b got %v", batchErr)
+		}
+		if applied != 0 {
+			t.Fatalf("expected no applied statements got %d", applied)
+		}
+	})
+}
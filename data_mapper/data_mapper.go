@@ -3,34 +3,66 @@ package data_mapper
 import (
 	"clearly-not-a-secret-project/interfaces"
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// ErrNotFound is returned when no row, or stream of events,
+// holds the object with the id being found.
+var ErrNotFound = errors.New("the object was not found")
+
+var errNoReturnedRows = errors.New("the returning clause returned no rows")
+
 type StatementSource interface {
 	Sql() string
 	Parameters() []interface{}
 }
 
+// Statement is the source of a statement written by hand,
+// its parameters are taken in order as $1, $2 and so on.
+type Statement struct {
+	sql    string
+	params []interface{}
+}
+
+func NewStatement(sql string, params ...interface{}) Statement {
+	return Statement{sql: sql, params: params}
+}
+
+func (s Statement) Sql() string {
+	return s.sql
+}
+
+func (s Statement) Parameters() []interface{} {
+	return s.params
+}
+
 type DataMapper[T interfaces.DomainObject[K], K comparable] interface {
 	Insert(ctx context.Context, obj T) (K, error)
+	Upsert(ctx context.Context, obj T) (K, error)
+	InsertMany(ctx context.Context, objs []T) ([]K, error)
 	Update(ctx context.Context, obj T) error
 	Remove(ctx context.Context, id K) error
 	Find(ctx context.Context, id K) (T, error)
 	FindMany(ctx context.Context, source StatementSource) ([]T, error)
+	Count(ctx context.Context, source StatementSource) (int64, error)
+	Exists(ctx context.Context, id K) (bool, error)
 	getId(rows pgx.Rows) (K, error)
-	load(resultSet pgx.Rows) (T, error)
-	loadAll(resultSet pgx.Rows) ([]T, error)
+	load(loaded map[K]T, resultSet pgx.Rows) (T, error)
+	loadAll(loaded map[K]T, resultSet pgx.Rows) ([]T, error)
 	interfaces.LazyLoading[T, K]
 	interfaces.Registrable
 }
 
 type PreparedStatement struct {
-	conn  *pgxpool.Pool
+	conn  Executor
 	query string
 	args  []interface{}
 }
@@ -59,97 +91,530 @@ type Markable interface {
 }
 
 type PostgreSQLDataMapper[T interfaces.DomainObject[K], K comparable] struct {
-	Db              *pgxpool.Pool
-	LoadedMap       map[K]T
-	FindStatement   string
-	InsertStatement string
-	UpdateStatement string
-	RemoveStatement string
-	DoLoad          func(resultSet pgx.Rows) (T, error)
-	DoInsert        func(obj T, stmt *PreparedStatement) error
-	DoUpdate        func(obj T, stmt *PreparedStatement) error
-	DomainType      reflect.Type
-	LazyLoading     bool
-	CreateGhost     func(id K) T
-	DoLoadLine      func(resultSet pgx.Rows, obj T) error
+	Db               *pgxpool.Pool
+	LoadedMap        map[K]T
+	Table            string
+	InsertColumns    []string
+	UpdateColumns    []string
+	ReturningColumns []string
+	FindStatement    string
+	InsertStatement  string
+	UpdateStatement  string
+	RemoveStatement  string
+	UpsertStatement  string
+	ExistsStatement  string
+	DoLoad           func(resultSet pgx.Rows) (T, error)
+	DoInsert         func(obj T, stmt *PreparedStatement) error
+	DoUpdate         func(obj T, stmt *PreparedStatement) error
+	DomainType       reflect.Type
+	LazyLoading      bool
+	CreateGhost      func(id K) T
+	DoLoadLine       func(resultSet pgx.Rows, obj T) error
+	NextId           func(ctx context.Context, db Executor) (K, error)
+	DoSetId          func(obj T, id K) error
+	DoReturning      func(resultSet pgx.Rows, obj T) error
+	// DoUpsertReturning reads the row returned by the UpsertStatement whose
+	// conflict columns are not the id, it returns the id of the stored row
+	// that is set into the object with DoSetId.
+	DoUpsertReturning func(resultSet pgx.Rows, obj T) (K, error)
+	// SoftDeleteColumn is the timestamp column set by the RemoveStatement
+	// instead of deleting the row, the rows where it is not null are excluded
+	// from the FindStatement and the query objects of FindMany.
+	SoftDeleteColumn              string
+	FindIncludingDeletedStatement string
+	RestoreStatement              string
+	// Audited mappers append the audit values, taken from the context, to the
+	// arguments of DoInsert and DoUpdate. The objects of mappers with a
+	// HistoryTable have their changes recorded in it.
+	Audited      bool
+	HistoryTable string
+	// TenantColumn is the column holding the tenant of the rows of the tenant
+	// scoped mappers, their statements are restricted to the tenant carried
+	// by the context and their objects kept in the TenantLoadedMaps of their
+	// tenant instead of the LoadedMap.
+	TenantColumn     string
+	TenantLoadedMaps map[string]map[K]T
+	// OutboxTable receives the events recorded by the objects that
+	// implement interfaces.EventRecorder.
+	OutboxTable string
+	// Projections are written after each insert, update, removal and
+	// restoration of an object in the transaction of its statement.
+	Projections []Projection[T]
+	// SubtypeStatements write the types of the hierarchy mapped by the
+	// mapper, DoLoad builds the type of each row from its discriminator.
+	SubtypeStatements map[reflect.Type]SubtypeStatements
+	// ManyToMany are the associations of the objects written to their
+	// join tables in the transaction of the statement of the object.
+	ManyToMany []ManyToMany[T]
+	// Cursors encode the sort keys of the objects in the cursors of the
+	// pages returned by FindPage.
+	Cursors CursorEncoder[T]
+	// SortColumns are the columns allowed to sort the pages, any other
+	// column is rejected before being written in the statement of a page.
+	SortColumns []string
 }
 
 func (d PostgreSQLDataMapper[T, K]) Type() reflect.Type {
 	return d.DomainType
 }
 
+// The transaction carried by the context or the pool.
+func (d PostgreSQLDataMapper[T, K]) executor(ctx context.Context) Executor {
+	if tx, ok := TxFrom(ctx); ok {
+		return tx
+	}
+	return d.Db
+}
+
 func (d PostgreSQLDataMapper[T, K]) Insert(ctx context.Context, obj T) (K, error) {
+	return d.insert(ctx, insertOperation, d.insertStatement(obj), obj)
+}
+
+// Upsert inserts the object or, when a row with the same conflict
+// columns already exists, updates its columns flagged for update. The
+// soft deleted row is restored. The upserts of the tenant scoped types
+// fail when the conflicting row belongs to another tenant, it's never
+// overwritten.
+func (d PostgreSQLDataMapper[T, K]) Upsert(ctx context.Context, obj T) (K, error) {
+	var nilK K
+	if d.UpsertStatement == "" {
+		return nilK, fmt.Errorf("upsert is not supported for type %v, it requires conflict columns written by the insert", d.DomainType)
+	}
+	id, err := d.insert(ctx, upsertOperation, d.UpsertStatement, obj)
+	if errors.Is(err, errNoReturnedRows) && d.TenantColumn != "" {
+		return nilK, fmt.Errorf("the upsert of %v %v wrote no row, its conflict columns match a row of another tenant", d.DomainType, obj.Id())
+	}
+	return id, err
+}
+
+func (d PostgreSQLDataMapper[T, K]) insert(ctx context.Context, operation, query string, obj T) (K, error) {
 	var nilK K
 	stmt := &PreparedStatement{
-		conn:  d.Db,
-		query: d.InsertStatement,
+		conn:  d.executor(ctx),
+		query: query,
 		args:  make([]interface{}, 0),
 	}
+	if d.NextId != nil && obj.Id() == nilK {
+		id, err := d.NextId(ctx, d.executor(ctx))
+		if err != nil {
+			return nilK, err
+		}
+		err = d.DoSetId(obj, id)
+		if err != nil {
+			return nilK, err
+		}
+	}
 	err := d.DoInsert(obj, stmt)
 	if err != nil {
 		return nilK, err
 	}
-	_, err = stmt.Execute(ctx)
+	err = d.appendAudit(ctx, stmt, time.Now(), true)
 	if err != nil {
 		return nilK, err
 	}
-	id := obj.Id()
-	d.LoadedMap[id] = obj
-	return id, nil
+	err = d.appendTenant(ctx, stmt)
+	if err != nil {
+		return nilK, err
+	}
+	loaded, err := d.identityMap(ctx)
+	if err != nil {
+		return nilK, err
+	}
+	var returning func(resultSet pgx.Rows) error
+	switch {
+	case operation == upsertOperation && d.DoUpsertReturning != nil:
+		returning = func(resultSet pgx.Rows) error {
+			return d.setStoredId(resultSet, obj)
+		}
+	case d.DoReturning != nil:
+		returning = func(resultSet pgx.Rows) error {
+			return d.DoReturning(resultSet, obj)
+		}
+	case operation == upsertOperation && d.TenantColumn != "":
+		// the returned row tells the upsert wrote the row of the tenant
+		returning = func(resultSet pgx.Rows) error {
+			return nil
+		}
+	}
+	err = d.execute(ctx, operation, obj.Id, stmt, obj, returning, func() {
+		loaded[obj.Id()] = obj
+		if tracker, ok := any(obj).(interfaces.DirtyTracker); ok {
+			tracker.ClearDirtyColumns()
+		}
+	})
+	if err != nil {
+		return nilK, err
+	}
+	return obj.Id(), nil
+}
+
+// Sets the id of the row the upsert wrote into the object, it's not
+// the id of the object when its conflict columns matched a stored row.
+func (d PostgreSQLDataMapper[T, K]) setStoredId(resultSet pgx.Rows, obj T) error {
+	id, err := d.DoUpsertReturning(resultSet, obj)
+	if err != nil {
+		return err
+	}
+	if id == obj.Id() {
+		return nil
+	}
+	if d.DoSetId == nil {
+		return fmt.Errorf("the id %v of the stored row of type %v can't be set into the object", id, d.DomainType)
+	}
+	return d.DoSetId(obj, id)
 }
 
 func (d PostgreSQLDataMapper[T, K]) Update(ctx context.Context, obj T) error {
 	stmt := &PreparedStatement{
-		conn:  d.Db,
-		query: d.UpdateStatement,
+		conn:  d.executor(ctx),
+		query: d.updateStatement(obj),
 		args:  make([]interface{}, 0),
 	}
 	err := d.DoUpdate(obj, stmt)
 	if err != nil {
 		return err
 	}
-	_, err = stmt.Execute(ctx)
+	err = d.appendAudit(ctx, stmt, time.Now(), false)
 	if err != nil {
 		return err
 	}
-	id := obj.Id()
-	d.LoadedMap[id] = obj
-	return nil
+	tracker, tracked := any(obj).(interfaces.DirtyTracker)
+	if tracked && d.UpdateColumns != nil {
+		dirty := slices.DeleteFunc(tracker.DirtyColumns(), func(column string) bool {
+			return !slices.Contains(d.UpdateColumns, column)
+		})
+		switch {
+		case len(dirty) > 0:
+			if d.Audited {
+				dirty = append(dirty, auditUpdateColumns...)
+			}
+			stmt.query, stmt.args = d.partialUpdate(dirty, stmt.args)
+		case !d.writesAfter(updateOperation, obj):
+			return nil
+		default:
+			// without changed columns only the associations, the
+			// events, the history and the projections are written
+			stmt = nil
+		}
+	}
+	if stmt != nil {
+		err = d.appendTenant(ctx, stmt)
+		if err != nil {
+			return err
+		}
+	}
+	loaded, err := d.identityMap(ctx)
+	if err != nil {
+		return err
+	}
+	return d.execute(ctx, updateOperation, obj.Id, stmt, obj, nil, func() {
+		loaded[obj.Id()] = obj
+		if tracked {
+			tracker.ClearDirtyColumns()
+		}
+	})
+}
+
+// Builds an update statement that only sets the dirty columns from the
+// arguments appended by DoUpdate, the id followed by the UpdateColumns.
+// The tenant of the tenant scoped mappers is the parameter that follows them.
+func (d PostgreSQLDataMapper[T, K]) partialUpdate(dirty []string, args []interface{}) (string, []interface{}) {
+	sets := make([]string, 0, len(dirty))
+	dirtyArgs := []interface{}{args[0]}
+	for i, column := range d.UpdateColumns {
+		if slices.Contains(dirty, column) {
+			dirtyArgs = append(dirtyArgs, args[i+1])
+			sets = append(sets, fmt.Sprintf("%s = $%d", column, len(dirtyArgs)))
+		}
+	}
+	where := "ID = $1"
+	if d.TenantColumn != "" {
+		where += fmt.Sprintf(" AND %s = $%d", d.TenantColumn, len(dirtyArgs)+1)
+	}
+	return fmt.Sprintf("UPDATE %s SET %s WHERE %s", d.Table, strings.Join(sets, ","), where), dirtyArgs
 }
 
 func (d PostgreSQLDataMapper[T, K]) Remove(ctx context.Context, id K) error {
 	stmt := &PreparedStatement{
-		conn:  d.Db,
+		conn:  d.executor(ctx),
 		query: d.RemoveStatement,
 		args:  make([]interface{}, 0),
 	}
 	stmt.Append(id)
-	_, err := stmt.Execute(ctx)
+	err := d.appendTenant(ctx, stmt)
 	if err != nil {
 		return err
 	}
-	delete(d.LoadedMap, id)
+	loaded, err := d.identityMap(ctx)
+	if err != nil {
+		return err
+	}
+	var object any = id
+	if obj, ok := loaded[id]; ok {
+		object = obj
+	}
+	return d.execute(ctx, removeOperation, func() K { return id }, stmt, object, nil, func() {
+		delete(loaded, id)
+	})
+}
+
+// Executes the statement of the operation on the row identified by id, or
+// queues it when the context carries a batch. The history of the row, the
+// events recorded by the object and its projections are written in the
+// same transaction.
+// returning reads the row returned by the statement, if any, and onSuccess
+// is called once the statement succeeds. Without statement, an update of no
+// column, only the statements written around it are executed.
+func (d PostgreSQLDataMapper[T, K]) execute(
+	ctx context.Context,
+	operation string,
+	id func() K,
+	stmt *PreparedStatement,
+	object any,
+	returning func(resultSet pgx.Rows) error,
+	onSuccess func(),
+) error {
+	events := d.pendingEvents(object)
+	if len(events) > 0 {
+		success := onSuccess
+		onSuccess = func() {
+			success()
+			object.(interfaces.EventRecorder).PullEvents()
+		}
+	}
+	if changed := d.changedAssociations(object); len(changed) > 0 {
+		success := onSuccess
+		onSuccess = func() {
+			success()
+			for _, v := range changed {
+				v.Commit()
+			}
+		}
+	}
+	if b, ok := BatchFrom(ctx); ok {
+		return d.queue(ctx, b, operation, id, stmt, object, returning, onSuccess)
+	}
+	if !d.writesAfter(operation, object) {
+		err := d.run(ctx, stmt, returning)
+		if err != nil {
+			return err
+		}
+		onSuccess()
+		return nil
+	}
+	err := d.inTx(ctx, func(ctx context.Context) error {
+		var nilK K
+		// the rows without id before the statement are being inserted
+		inserted := operation == insertOperation || id() == nilK
+		if !inserted {
+			before, err := d.before(ctx, operation, id())
+			if err != nil {
+				return err
+			}
+			for _, v := range before {
+				err = d.run(ctx, v, nil)
+				if err != nil {
+					return fmt.Errorf("error writing the history or the associations %w", err)
+				}
+			}
+		}
+		if stmt != nil {
+			err := d.run(ctx, stmt, returning)
+			if err != nil {
+				return err
+			}
+		}
+		after, err := d.after(ctx, operation, inserted, id(), object)
+		if err != nil {
+			return err
+		}
+		for _, v := range after {
+			err = d.run(ctx, v, nil)
+			if err != nil {
+				return fmt.Errorf("error writing the history, the associations, the outbox or the projections %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	onSuccess()
 	return nil
 }
 
+// Queues the statement, its history and the outbox events into the batch,
+// the id of the row must be known before sending the batch to write them.
+func (d PostgreSQLDataMapper[T, K]) queue(
+	ctx context.Context,
+	b *Batch,
+	operation string,
+	id func() K,
+	stmt *PreparedStatement,
+	object any,
+	returning func(resultSet pgx.Rows) error,
+	onSuccess func(),
+) error {
+	if !d.writesAfter(operation, object) {
+		b.queue(stmt, object, returning, onSuccess)
+		return nil
+	}
+	if operation == upsertOperation && d.DoUpsertReturning != nil {
+		return fmt.Errorf("the history, associations, events and projections of type %v can't be batched with upserts, the id of the row is read back from the upsert", d.DomainType)
+	}
+	var nilK K
+	rowId := id()
+	if rowId == nilK {
+		return fmt.Errorf("the history, associations, events and projections of type %v can't be batched before the database generates the id", d.DomainType)
+	}
+	inserted := operation == insertOperation
+	before := make([]*PreparedStatement, 0)
+	if !inserted {
+		var err error
+		before, err = d.before(ctx, operation, rowId)
+		if err != nil {
+			return err
+		}
+	}
+	after, err := d.after(ctx, operation, inserted, rowId, object)
+	if err != nil {
+		return err
+	}
+	for _, v := range before {
+		b.queue(v, object, nil, nil)
+	}
+	if stmt != nil {
+		b.queue(stmt, object, returning, onSuccess)
+	}
+	for i, v := range after {
+		if stmt == nil && i == len(after)-1 {
+			// the last statement succeeds for the object without statement
+			b.queue(v, object, nil, onSuccess)
+			continue
+		}
+		b.queue(v, object, nil, nil)
+	}
+	return nil
+}
+
+// Reports whether the statement of the operation on the object is surrounded
+// by the statements of its history, its associations, its events or its
+// projections.
+func (d PostgreSQLDataMapper[T, K]) writesAfter(operation string, object any) bool {
+	return d.HistoryTable != "" || len(d.pendingEvents(object)) > 0 || len(d.Projections) > 0 ||
+		len(d.changedAssociations(object)) > 0 ||
+		(operation == removeOperation && len(d.ManyToMany) > 0 && d.SoftDeleteColumn == "")
+}
+
+// The statements written before the statement of the operation on the
+// row id, its history and the rows of the join tables of removed objects.
+func (d PostgreSQLDataMapper[T, K]) before(ctx context.Context, operation string, id K) ([]*PreparedStatement, error) {
+	before := make([]*PreparedStatement, 0)
+	if d.HistoryTable != "" {
+		actor, err := d.actor(ctx)
+		if err != nil {
+			return nil, err
+		}
+		before = append(before, d.historyBefore(id, operation, actor))
+	}
+	if operation == removeOperation {
+		before = append(before, d.associate(operation, id, nil)...)
+	}
+	return before, nil
+}
+
+// The statements written after the statement of the operation on the row id,
+// its history, its associations, the events recorded by the object and its
+// projections.
+func (d PostgreSQLDataMapper[T, K]) after(ctx context.Context, operation string, inserted bool, id K, object any) ([]*PreparedStatement, error) {
+	after := make([]*PreparedStatement, 0)
+	if d.HistoryTable != "" {
+		actor, err := d.actor(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if inserted {
+			after = append(after, d.historyInsert([]K{id}, operation, actor))
+		} else {
+			after = append(after, d.historyAfter(id))
+		}
+	}
+	if operation != removeOperation {
+		after = append(after, d.associate(operation, id, object)...)
+	}
+	outbox, err := d.outbox(id, object)
+	if err != nil {
+		return nil, err
+	}
+	projections, err := d.project(ctx, operation, id, object)
+	if err != nil {
+		return nil, err
+	}
+	after = append(after, outbox...)
+	return append(after, projections...), nil
+}
+
+// Runs the statement on the executor of the context, returning
+// reads the single row returned by the statement.
+func (d PostgreSQLDataMapper[T, K]) run(ctx context.Context, stmt *PreparedStatement, returning func(resultSet pgx.Rows) error) error {
+	stmt.conn = d.executor(ctx)
+	if returning == nil {
+		_, err := stmt.Execute(ctx)
+		return err
+	}
+	return returningRow(ctx, stmt, returning)
+}
+
+// Runs the statement on its connection and reads the single row it returns.
+func returningRow(ctx context.Context, stmt *PreparedStatement, returning func(resultSet pgx.Rows) error) error {
+	rows, err := stmt.ExecuteQuery(ctx)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if rows.Err() != nil {
+			return rows.Err()
+		}
+		return errNoReturnedRows
+	}
+	err = returning(rows)
+	if err != nil {
+		return fmt.Errorf("error at doReturning %w", err)
+	}
+	rows.Close()
+	return rows.Err()
+}
+
 func (d PostgreSQLDataMapper[T, K]) Load(obj T) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 	if !obj.IsGhost() {
 		return fmt.Errorf("assertion error: the object to load is not a ghost")
 	}
+	ctx, err := d.tenantContext(ctx, obj)
+	if err != nil {
+		return err
+	}
 	stmt := &PreparedStatement{
-		conn:  d.Db,
+		conn:  d.executor(ctx),
 		query: d.FindStatement,
 		args:  make([]interface{}, 0),
 	}
 	stmt.Append(obj.Id())
+	err = d.appendTenant(ctx, stmt)
+	if err != nil {
+		return err
+	}
 	rows, err := stmt.ExecuteQuery(ctx)
 	if err != nil {
 		return fmt.Errorf("error at execute query %w", err)
 	}
 	if !rows.Next() {
-		return fmt.Errorf("rows next returned false")
+		if rows.Err() != nil {
+			return rows.Err()
+		}
+		return fmt.Errorf("%w: %v %v", ErrNotFound, d.DomainType, obj.Id())
 	}
 	return d.loadLine(rows, obj)
 }
@@ -170,6 +635,9 @@ func (d PostgreSQLDataMapper[T, K]) loadLine(resultSet pgx.Rows, obj T) error {
 	if err != nil {
 		return err
 	}
+	if tracker, ok := any(obj).(interfaces.DirtyTracker); ok {
+		tracker.ClearDirtyColumns()
+	}
 	if resultSet.Err() != nil {
 		return err
 	}
@@ -179,41 +647,65 @@ func (d PostgreSQLDataMapper[T, K]) loadLine(resultSet pgx.Rows, obj T) error {
 
 func (d PostgreSQLDataMapper[T, K]) Find(ctx context.Context, id K) (T, error) {
 	var nilT T
-	if obj, ok := d.LoadedMap[id]; ok {
+	loaded, err := d.identityMap(ctx)
+	if err != nil {
+		return nilT, err
+	}
+	if obj, ok := loaded[id]; ok {
 		return obj, nil
 	}
 	if d.LazyLoading && d.CreateGhost != nil {
 		result := d.CreateGhost(id)
-		d.LoadedMap[id] = result
+		loaded[id] = result
 		return result, nil
 	}
 	stmt := &PreparedStatement{
-		conn:  d.Db,
+		conn:  d.executor(ctx),
 		query: d.FindStatement,
 		args:  make([]interface{}, 0),
 	}
 	stmt.Append(id)
+	err = d.appendTenant(ctx, stmt)
+	if err != nil {
+		return nilT, err
+	}
 	rows, err := stmt.ExecuteQuery(ctx)
 	if err != nil {
 		return nilT, fmt.Errorf("error at execute query %w", err)
 	}
 	if !rows.Next() {
-		return nilT, fmt.Errorf("rows next returned false")
+		if rows.Err() != nil {
+			return nilT, rows.Err()
+		}
+		return nilT, fmt.Errorf("%w: %v %v", ErrNotFound, d.DomainType, id)
 	}
-	return d.load(rows)
+	return d.load(loaded, rows)
 }
 
 func (d PostgreSQLDataMapper[T, K]) FindMany(ctx context.Context, source StatementSource) ([]T, error) {
+	source, err := d.scope(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	return d.findMany(ctx, source)
+}
+
+// Runs the statement of an already scoped source.
+func (d PostgreSQLDataMapper[T, K]) findMany(ctx context.Context, source StatementSource) ([]T, error) {
+	loaded, err := d.identityMap(ctx)
+	if err != nil {
+		return nil, err
+	}
 	stmt := &PreparedStatement{
-		conn:  d.Db,
-		query: d.RemoveStatement,
-		args:  make([]interface{}, 0),
+		conn:  d.executor(ctx),
+		query: source.Sql(),
+		args:  source.Parameters(),
 	}
 	rows, err := stmt.ExecuteQuery(ctx)
 	if err != nil {
 		return nil, err
 	}
-	result, err := d.loadAll(rows)
+	result, err := d.loadAll(loaded, rows)
 	if err != nil {
 		return nil, err
 	}
@@ -224,61 +716,44 @@ func (d PostgreSQLDataMapper[T, K]) FindMany(ctx context.Context, source Stateme
 	return result, nil
 }
 
+// The id is the first column of the row, it's scanned into a K so pgx
+// converts the int8, int4 and uuid columns into the type of the ids.
 func (d PostgreSQLDataMapper[T, K]) getId(rows pgx.Rows) (K, error) {
-	var (
-		nilK  K
-		index int = 0
-	)
-	fieldDescriptions := len(rows.FieldDescriptions())
-	if index > fieldDescriptions {
-		return nilK, fmt.Errorf("index out of range")
-	}
-	values := make([]interface{}, fieldDescriptions)
-	for i := range fieldDescriptions {
-		var v interface{}
-		values[i] = &v
+	var id K
+	values := make([]interface{}, len(rows.FieldDescriptions()))
+	if len(values) == 0 {
+		return id, fmt.Errorf("the row has no id column")
 	}
+	// the nil destinations skip the other columns
+	values[0] = &id
 	err := rows.Scan(values...)
 	if err != nil {
-		return nilK, err
-	}
-	ptr, ok := values[index].(*interface{})
-	if !ok {
-		return nilK, fmt.Errorf("getId wrong interface assertion %v is %v could not cast to",
-			values[index],
-			reflect.TypeOf(values[index]))
+		return id, err
 	}
-	v := *ptr
-	toId, ok := v.(K)
-	if !ok {
-		return nilK, fmt.Errorf("getId wrong interface assertion %v is %v could not cast to, the value does not implement comparable interface",
-			toId,
-			reflect.TypeOf(v))
-	}
-	return toId, nil
+	return id, nil
 }
 
-func (d PostgreSQLDataMapper[T, K]) load(resultSet pgx.Rows) (T, error) {
+func (d PostgreSQLDataMapper[T, K]) load(loaded map[K]T, resultSet pgx.Rows) (T, error) {
 	var nilT T
 	id, err := d.getId(resultSet)
 	if err != nil {
 		return nilT, fmt.Errorf("error at load getId %w", err)
 	}
-	if obj, ok := d.LoadedMap[id]; ok {
+	if obj, ok := loaded[id]; ok {
 		return obj, nil
 	}
 	result, err := d.DoLoad(resultSet)
 	if err != nil {
 		return nilT, fmt.Errorf("error at doLoad %w", err)
 	}
-	d.LoadedMap[id] = result
+	loaded[id] = result
 	return result, nil
 }
 
-func (d PostgreSQLDataMapper[T, K]) loadAll(resultSet pgx.Rows) ([]T, error) {
+func (d PostgreSQLDataMapper[T, K]) loadAll(loaded map[K]T, resultSet pgx.Rows) ([]T, error) {
 	result := make([]T, 0)
 	for resultSet.Next() {
-		obj, err := d.load(resultSet)
+		obj, err := d.load(loaded, resultSet)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,39 @@
+package data_mapper
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ValidateEnum checks that every label is a value of the PostgreSQL enum type name.
+func ValidateEnum(ctx context.Context, db Executor, name string, labels []string) error {
+	rows, err := db.Query(ctx, "SELECT enumlabel FROM pg_enum WHERE enumtypid = $1::text::regtype;", name)
+	if err != nil {
+		return fmt.Errorf("error reading the labels of the enum %s %w", name, err)
+	}
+	defer rows.Close()
+	dbLabels := make([]string, 0, len(labels))
+	for rows.Next() {
+		var label string
+		err = rows.Scan(&label)
+		if err != nil {
+			return err
+		}
+		dbLabels = append(dbLabels, label)
+	}
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+	missing := make([]string, 0)
+	for _, v := range labels {
+		if !slices.Contains(dbLabels, v) {
+			missing = append(missing, v)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("the enum %s is missing the labels %s", name, strings.Join(missing, ", "))
+	}
+	return nil
+}
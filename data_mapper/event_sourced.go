@@ -0,0 +1,413 @@
+package data_mapper
+
+import (
+	"clearly-not-a-secret-project/domain_events"
+	"clearly-not-a-secret-project/interfaces"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// EventsDDL creates the append-only table of the events written by the
+// event sourced data mappers and the table of the snapshots of their objects.
+const EventsDDL = `CREATE TABLE IF NOT EXISTS events (
+	stream_type TEXT NOT NULL,
+	stream_id TEXT NOT NULL,
+	version BIGINT NOT NULL,
+	event_type TEXT NOT NULL,
+	payload JSONB NOT NULL,
+	occurred_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (stream_type, stream_id, version)
+);
+CREATE TABLE IF NOT EXISTS snapshots (
+	stream_type TEXT NOT NULL,
+	stream_id TEXT NOT NULL,
+	version BIGINT NOT NULL,
+	state JSONB NOT NULL,
+	PRIMARY KEY (stream_type, stream_id, version)
+);`
+
+// ErrVersionConflict is returned when the events of an object are appended
+// at a version already written by another data mapper since it was found.
+var ErrVersionConflict = errors.New("the stream of events was changed concurrently")
+
+// the error code of the unique violations.
+const uniqueViolation = "23505"
+
+// EventSourcedDataMapper stores its objects as the streams of the events they
+// record, one stream for each id of the StreamType. The objects are found by
+// replaying their events, after restoring their latest snapshot, if any.
+// The objects must implement interfaces.EventSourced.
+type EventSourcedDataMapper[T interfaces.DomainObject[K], K comparable] struct {
+	Db             *pgxpool.Pool
+	LoadedMap      map[K]T
+	StreamType     string
+	EventsTable    string
+	SnapshotsTable string
+	// SnapshotEvery stores a snapshot of the object each time its version
+	// reaches a multiple of it, with zero no snapshots are stored.
+	SnapshotEvery int64
+	Decoder       domain_events.Decoder
+	DomainType    reflect.Type
+	CreateGhost   func(id K) T
+	DoSnapshot    func(obj T) ([]byte, error)
+	DoRestore     func(obj T, state []byte) error
+}
+
+func (d EventSourcedDataMapper[T, K]) Type() reflect.Type {
+	return d.DomainType
+}
+
+// The transaction carried by the context or the pool.
+func (d EventSourcedDataMapper[T, K]) executor(ctx context.Context) Executor {
+	if tx, ok := TxFrom(ctx); ok {
+		return tx
+	}
+	return d.Db
+}
+
+func (d EventSourcedDataMapper[T, K]) eventSourced(obj T) (interfaces.EventSourced, error) {
+	recorder, ok := any(obj).(interfaces.EventSourced)
+	if !ok {
+		return nil, fmt.Errorf("the type %v is not event sourced", d.DomainType)
+	}
+	return recorder, nil
+}
+
+// Insert starts the stream of the object with its pending events.
+func (d EventSourcedDataMapper[T, K]) Insert(ctx context.Context, obj T) (K, error) {
+	var nilK K
+	recorder, err := d.eventSourced(obj)
+	if err != nil {
+		return nilK, err
+	}
+	err = d.newStream(obj, recorder)
+	if err != nil {
+		return nilK, err
+	}
+	err = d.save(ctx, obj)
+	if err != nil {
+		return nilK, err
+	}
+	return obj.Id(), nil
+}
+
+// Checks that the object starts a stream, it has no stored events and
+// at least one pending event, the streams without events are never written.
+func (d EventSourcedDataMapper[T, K]) newStream(obj T, recorder interfaces.EventSourced) error {
+	if recorder.Version() != 0 {
+		return fmt.Errorf("the object %v of type %v is already stored", obj.Id(), d.DomainType)
+	}
+	if len(recorder.PendingEvents()) == 0 {
+		return fmt.Errorf("the object %v of type %v has no events to start its stream", obj.Id(), d.DomainType)
+	}
+	return nil
+}
+
+// Upsert appends the pending events of the object to its stream, starting it if needed.
+func (d EventSourcedDataMapper[T, K]) Upsert(ctx context.Context, obj T) (K, error) {
+	var nilK K
+	recorder, err := d.eventSourced(obj)
+	if err != nil {
+		return nilK, err
+	}
+	if recorder.Version() == 0 {
+		err = d.newStream(obj, recorder)
+		if err != nil {
+			return nilK, err
+		}
+	}
+	err = d.save(ctx, obj)
+	if err != nil {
+		return nilK, err
+	}
+	return obj.Id(), nil
+}
+
+// InsertMany starts the streams of all the objects in one transaction.
+func (d EventSourcedDataMapper[T, K]) InsertMany(ctx context.Context, objs []T) ([]K, error) {
+	done := make([]func(), 0, len(objs))
+	err := inTx(ctx, d.Db, func(ctx context.Context) error {
+		for _, obj := range objs {
+			recorder, err := d.eventSourced(obj)
+			if err != nil {
+				return err
+			}
+			err = d.newStream(obj, recorder)
+			if err != nil {
+				return err
+			}
+			onSuccess, err := d.append(ctx, obj)
+			if err != nil {
+				return err
+			}
+			done = append(done, onSuccess)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]K, 0, len(objs))
+	for i, obj := range objs {
+		done[i]()
+		ids = append(ids, obj.Id())
+	}
+	return ids, nil
+}
+
+// Update appends the pending events of the object to its stream, it fails
+// with ErrVersionConflict if other events were appended since it was found.
+func (d EventSourcedDataMapper[T, K]) Update(ctx context.Context, obj T) error {
+	return d.save(ctx, obj)
+}
+
+func (d EventSourcedDataMapper[T, K]) Remove(ctx context.Context, id K) error {
+	return fmt.Errorf("the streams of the event sourced type %v are append-only, removals must be recorded as events", d.DomainType)
+}
+
+func (d EventSourcedDataMapper[T, K]) save(ctx context.Context, obj T) error {
+	var onSuccess func()
+	err := inTx(ctx, d.Db, func(ctx context.Context) error {
+		var err error
+		onSuccess, err = d.append(ctx, obj)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	onSuccess()
+	return nil
+}
+
+// Appends the pending events of the object to its stream and, when its new
+// version reaches a multiple of SnapshotEvery, stores its snapshot. The
+// returned function updates the object once the transaction succeeds.
+func (d EventSourcedDataMapper[T, K]) append(ctx context.Context, obj T) (func(), error) {
+	recorder, err := d.eventSourced(obj)
+	if err != nil {
+		return nil, err
+	}
+	events := recorder.PendingEvents()
+	version := recorder.Version()
+	next := version + int64(len(events))
+	onSuccess := func() {
+		recorder.SetVersion(next)
+		recorder.PullEvents()
+		d.LoadedMap[obj.Id()] = obj
+	}
+	if len(events) == 0 {
+		return onSuccess, nil
+	}
+	streamId := fmt.Sprint(obj.Id())
+	stmt := &PreparedStatement{
+		conn:  d.executor(ctx),
+		query: d.appendStmt(len(events)),
+		args:  make([]interface{}, 0, len(events)*6),
+	}
+	now := time.Now()
+	for i, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding the event %s %w", event.EventName(), err)
+		}
+		stmt.args = append(stmt.args, d.StreamType, streamId, version+int64(i)+1, event.EventName(), payload, now)
+	}
+	_, err = stmt.Execute(ctx)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return nil, fmt.Errorf("%w: %v %v at version %d", ErrVersionConflict, d.DomainType, obj.Id(), version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error appending the events %w", err)
+	}
+	if d.snapshotDue(version, next) {
+		state, err := d.DoSnapshot(obj)
+		if err != nil {
+			return nil, fmt.Errorf("error at doSnapshot %w", err)
+		}
+		_, err = d.executor(ctx).Exec(ctx, d.snapshotStmt(), d.StreamType, streamId, next, state)
+		if err != nil {
+			return nil, fmt.Errorf("error storing the snapshot %w", err)
+		}
+	}
+	return onSuccess, nil
+}
+
+// Reports whether a multiple of SnapshotEvery is in (version, next].
+func (d EventSourcedDataMapper[T, K]) snapshotDue(version, next int64) bool {
+	if d.SnapshotEvery <= 0 || d.SnapshotsTable == "" || d.DoSnapshot == nil {
+		return false
+	}
+	return next/d.SnapshotEvery > version/d.SnapshotEvery
+}
+
+func (d EventSourcedDataMapper[T, K]) appendStmt(n int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "INSERT INTO %s (stream_type, stream_id, version, event_type, payload, occurred_at) VALUES ", d.EventsTable)
+	for i := range n {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		p := i * 6
+		fmt.Fprintf(&b, "($%d,$%d,$%d,$%d,$%d,$%d)", p+1, p+2, p+3, p+4, p+5, p+6)
+	}
+	b.WriteString(";")
+	return b.String()
+}
+
+func (d EventSourcedDataMapper[T, K]) snapshotStmt() string {
+	return fmt.Sprintf("INSERT INTO %s (stream_type, stream_id, version, state) VALUES ($1, $2, $3, $4) "+
+		"ON CONFLICT DO NOTHING;", d.SnapshotsTable)
+}
+
+// Find rebuilds the object from its stream, the objects already
+// found or saved are returned from the identity map.
+func (d EventSourcedDataMapper[T, K]) Find(ctx context.Context, id K) (T, error) {
+	var nilT T
+	if obj, ok := d.LoadedMap[id]; ok {
+		return obj, nil
+	}
+	obj := d.CreateGhost(id)
+	err := d.replay(ctx, obj)
+	if err != nil {
+		return nilT, err
+	}
+	d.LoadedMap[id] = obj
+	return obj, nil
+}
+
+func (d EventSourcedDataMapper[T, K]) FindMany(ctx context.Context, source StatementSource) ([]T, error) {
+	return nil, fmt.Errorf("the event sourced type %v can't be queried, its objects are found by id", d.DomainType)
+}
+
+func (d EventSourcedDataMapper[T, K]) Count(ctx context.Context, source StatementSource) (int64, error) {
+	return 0, fmt.Errorf("the event sourced type %v can't be queried, its objects are found by id", d.DomainType)
+}
+
+// Exists tells whether the stream of the id has any event.
+func (d EventSourcedDataMapper[T, K]) Exists(ctx context.Context, id K) (bool, error) {
+	var exists bool
+	err := d.executor(ctx).QueryRow(ctx, fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE stream_type = $1 AND stream_id = $2);",
+		d.EventsTable), d.StreamType, fmt.Sprint(id)).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("error reading the events %w", err)
+	}
+	return exists, nil
+}
+
+func (d EventSourcedDataMapper[T, K]) Load(obj T) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	return d.replay(ctx, obj)
+}
+
+// Applies to the ghost the events of its stream after its latest snapshot,
+// when they can't be applied the ghost is reset and evicted from the
+// identity map so that the next access replays the stream again.
+func (d EventSourcedDataMapper[T, K]) replay(ctx context.Context, obj T) error {
+	if !obj.IsGhost() {
+		return fmt.Errorf("assertion error: the object to load is not a ghost")
+	}
+	err := d.applyStream(ctx, obj)
+	if err != nil {
+		d.resetGhost(obj)
+		if loaded, ok := d.LoadedMap[obj.Id()]; ok && any(loaded) == any(obj) {
+			delete(d.LoadedMap, obj.Id())
+		}
+	}
+	return err
+}
+
+// Overwrites the object with a new ghost of its id, undoing the loading
+// status and the events applied before the failure.
+func (d EventSourcedDataMapper[T, K]) resetGhost(obj T) {
+	v, ghost := reflect.ValueOf(obj), reflect.ValueOf(d.CreateGhost(obj.Id()))
+	if v.Kind() != reflect.Pointer || v.IsNil() || ghost.Type() != v.Type() || ghost.IsNil() {
+		return
+	}
+	v.Elem().Set(ghost.Elem())
+}
+
+func (d EventSourcedDataMapper[T, K]) applyStream(ctx context.Context, obj T) error {
+	recorder, err := d.eventSourced(obj)
+	if err != nil {
+		return err
+	}
+	err = obj.MarkLoading()
+	if err != nil {
+		return err
+	}
+	db := d.executor(ctx)
+	streamId := fmt.Sprint(obj.Id())
+	var version int64
+	if d.SnapshotsTable != "" && d.DoRestore != nil {
+		var state []byte
+		err = db.QueryRow(ctx, fmt.Sprintf("SELECT version, state FROM %s WHERE stream_type = $1 AND stream_id = $2 "+
+			"ORDER BY version DESC LIMIT 1;", d.SnapshotsTable), d.StreamType, streamId).Scan(&version, &state)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("error reading the snapshot %w", err)
+		}
+		if err == nil {
+			err = d.DoRestore(obj, state)
+			if err != nil {
+				return fmt.Errorf("error at doRestore %w", err)
+			}
+		}
+	}
+	rows, err := db.Query(ctx, fmt.Sprintf("SELECT version, event_type, payload FROM %s WHERE stream_type = $1 AND stream_id = $2 "+
+		"AND version > $3 ORDER BY version;", d.EventsTable), d.StreamType, streamId, version)
+	if err != nil {
+		return fmt.Errorf("error reading the events %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			eventType string
+			payload   []byte
+		)
+		err = rows.Scan(&version, &eventType, &payload)
+		if err != nil {
+			return fmt.Errorf("error reading the events %w", err)
+		}
+		event, err := d.Decoder.Decode(eventType, payload)
+		if err != nil {
+			return err
+		}
+		err = recorder.Apply(event)
+		if err != nil {
+			return fmt.Errorf("error applying the event %s at version %d %w", eventType, version, err)
+		}
+	}
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+	if version == 0 {
+		return fmt.Errorf("%w: the stream %v of type %v has no events", ErrNotFound, obj.Id(), d.DomainType)
+	}
+	recorder.SetVersion(version)
+	return obj.MarkLoaded()
+}
+
+// The event sourced objects are not loaded from rows.
+func (d EventSourcedDataMapper[T, K]) getId(rows pgx.Rows) (K, error) {
+	var nilK K
+	return nilK, fmt.Errorf("the event sourced type %v is not loaded from rows", d.DomainType)
+}
+
+func (d EventSourcedDataMapper[T, K]) load(loaded map[K]T, resultSet pgx.Rows) (T, error) {
+	var nilT T
+	return nilT, fmt.Errorf("the event sourced type %v is not loaded from rows", d.DomainType)
+}
+
+func (d EventSourcedDataMapper[T, K]) loadAll(loaded map[K]T, resultSet pgx.Rows) ([]T, error) {
+	return nil, fmt.Errorf("the event sourced type %v is not loaded from rows", d.DomainType)
+}
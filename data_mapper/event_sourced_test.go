@@ -0,0 +1,168 @@
+package data_mapper
+
+import (
+	"clearly-not-a-secret-project/domain_events"
+	"clearly-not-a-secret-project/interfaces"
+	"clearly-not-a-secret-project/lazy_loading"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type renamedObject struct {
+	scoredObject
+	version int64
+}
+
+func (o *renamedObject) Apply(event domain_events.Event) error {
+	o.name = event.(renamed).Name
+	return nil
+}
+
+func (o *renamedObject) Version() int64                        { return o.version }
+func (o *renamedObject) SetVersion(version int64)              { o.version = version }
+func (o *renamedObject) RecordEvent(event domain_events.Event) {}
+func (o *renamedObject) PendingEvents() []domain_events.Event  { return nil }
+func (o *renamedObject) PullEvents() []domain_events.Event     { return nil }
+
+type storedEvent struct {
+	eventType string
+	payload   string
+}
+
+type fakeEventsTx struct {
+	pgx.Tx
+	events []storedEvent
+}
+
+func (f *fakeEventsTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return &fakeEventsRows{events: f.events}, nil
+}
+
+type fakeEventsRows struct {
+	pgx.Rows
+	events []storedEvent
+	next   int
+}
+
+func (f *fakeEventsRows) Next() bool {
+	f.next++
+	return f.next <= len(f.events)
+}
+
+func (f *fakeEventsRows) Scan(dest ...any) error {
+	event := f.events[f.next-1]
+	*dest[0].(*int64) = int64(f.next)
+	*dest[1].(*string) = event.eventType
+	*dest[2].(*[]byte) = []byte(event.payload)
+	return nil
+}
+
+func (f *fakeEventsRows) Close()     {}
+func (f *fakeEventsRows) Err() error { return nil }
+
+func TestEventSourcedDataMapper_snapshotDue(t *testing.T) {
+	d := EventSourcedDataMapper[interfaces.DomainObject[string], string]{
+		SnapshotsTable: "snapshots",
+		SnapshotEvery:  10,
+		DoSnapshot: func(obj interfaces.DomainObject[string]) ([]byte, error) {
+			return nil, nil
+		},
+	}
+	tests := []struct {
+		version, next int64
+		due           bool
+	}{
+		{version: 0, next: 3, due: false},
+		{version: 8, next: 10, due: true},
+		{version: 9, next: 12, due: true},
+		{version: 10, next: 11, due: false},
+		{version: 5, next: 25, due: true},
+	}
+	for _, test := range tests {
+		if due := d.snapshotDue(test.version, test.next); due != test.due {
+			t.Fatalf("expected due %v from %d to %d got %v", test.due, test.version, test.next, due)
+		}
+	}
+	d.SnapshotEvery = 0
+	if d.snapshotDue(9, 10) {
+		t.Fatal("expected no snapshots when SnapshotEvery is zero")
+	}
+}
+
+func TestEventSourcedDataMapper_appendStmt(t *testing.T) {
+	d := EventSourcedDataMapper[interfaces.DomainObject[string], string]{EventsTable: "events"}
+	expected := "INSERT INTO events (stream_type, stream_id, version, event_type, payload, occurred_at) " +
+		"VALUES ($1,$2,$3,$4,$5,$6),($7,$8,$9,$10,$11,$12);"
+	if stmt := d.appendStmt(2); stmt != expected {
+		t.Fatalf("expected %s got %s", expected, stmt)
+	}
+	// the event sourced mappers are registered as any other data mapper
+	var _ DataMapper[interfaces.DomainObject[string], string] = d
+}
+
+func TestEventSourcedDataMapper_replay(t *testing.T) {
+	d := EventSourcedDataMapper[*renamedObject, int64]{
+		EventsTable: "events",
+		StreamType:  "renamed",
+		LoadedMap:   map[int64]*renamedObject{},
+		Decoder:     domain_events.NewDecoder(domain_events.DecodingOf[renamed]()),
+		CreateGhost: func(id int64) *renamedObject {
+			return &renamedObject{scoredObject: scoredObject{id: id}}
+		},
+	}
+	tx := &fakeEventsTx{events: []storedEvent{{"Renamed", `{"name":"a"}`}, {"Renamed", `{"name":2}`}}}
+	ctx := WithTx(context.Background(), tx)
+	if _, err := d.Find(ctx, 1); err == nil {
+		t.Fatal("expected the error decoding the payload of the second event")
+	}
+	if _, ok := d.LoadedMap[1]; ok {
+		t.Fatal("expected the object not to enter the identity map")
+	}
+	ghost := d.CreateGhost(2)
+	d.LoadedMap[2] = ghost
+	err := d.replay(ctx, ghost)
+	var decodeErr *json.UnmarshalTypeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("expected the error decoding the payload got %v", err)
+	}
+	if !ghost.IsGhost() || ghost.name != "" || ghost.version != 0 {
+		t.Fatalf("expected the object to be reset to a ghost got %+v", ghost)
+	}
+	if _, ok := d.LoadedMap[2]; ok {
+		t.Fatal("expected the ghost to be evicted from the identity map")
+	}
+	tx.events = tx.events[:1]
+	obj, err := d.Find(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !obj.IsLoaded() || obj.name != "a" || obj.version != 1 {
+		t.Fatalf("expected the object to be replayed again got %+v", obj)
+	}
+}
+
+func TestEventSourcedDataMapper_insertWithoutEvents(t *testing.T) {
+	d := EventSourcedDataMapper[*renamedObject, int64]{
+		EventsTable: "events",
+		StreamType:  "renamed",
+		LoadedMap:   map[int64]*renamedObject{},
+	}
+	ctx := WithTx(context.Background(), &fakeEventsTx{})
+	obj := &renamedObject{scoredObject: scoredObject{id: 1, status: lazy_loading.LOADED}}
+	if _, err := d.Insert(ctx, obj); err == nil {
+		t.Fatal("expected an error starting a stream without events")
+	}
+	if _, err := d.InsertMany(ctx, []*renamedObject{obj}); err == nil {
+		t.Fatal("expected an error starting the streams without events")
+	}
+	if _, err := d.Upsert(ctx, obj); err == nil {
+		t.Fatal("expected an error upserting a new stream without events")
+	}
+	if len(d.LoadedMap) != 0 {
+		t.Fatal("expected the objects without a stream not to enter the identity map")
+	}
+}
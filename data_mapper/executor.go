@@ -0,0 +1,64 @@
+package data_mapper
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Executor runs the statements of the data mappers,
+// it's satisfied by *pgxpool.Pool, *pgxpool.Conn, *pgx.Conn and pgx.Tx.
+type Executor interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Copier is implemented by the executors able to run the COPY protocol.
+type Copier interface {
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+}
+
+type txKey struct{}
+
+// WithTx returns a copy of ctx carrying tx, the data mappers called with
+// the returned context run their statements inside the transaction.
+func WithTx(ctx context.Context, tx pgx.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// TxFrom returns the transaction carried by ctx, if any.
+func TxFrom(ctx context.Context) (pgx.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(pgx.Tx)
+	return tx, ok
+}
+
+// Returns a copy of ctx carrying no transaction, its statements
+// run on a connection of the pool of the data mapper.
+func withoutTx(ctx context.Context) context.Context {
+	return context.WithValue(ctx, txKey{}, nil)
+}
+
+// Runs fn with the transaction carried by ctx or, if there is none, inside
+// a new transaction of db that is committed when fn succeeds.
+func inTx(ctx context.Context, db *pgxpool.Pool, fn func(ctx context.Context) error) error {
+	if _, ok := TxFrom(ctx); ok {
+		return fn(ctx)
+	}
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error at begin transaction %w", err)
+	}
+	err = fn(WithTx(ctx, tx))
+	if err != nil {
+		rollbackErr := tx.Rollback(ctx)
+		if rollbackErr != nil {
+			return fmt.Errorf("%w\nerror at rollback %w", err, rollbackErr)
+		}
+		return err
+	}
+	return tx.Commit(ctx)
+}
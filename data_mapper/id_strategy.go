@@ -0,0 +1,57 @@
+package data_mapper
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewUUIDv7 returns the string form of a time ordered uuid.
+func NewUUIDv7() (string, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return "", fmt.Errorf("error generating uuidv7 %w", err)
+	}
+	return id.String(), nil
+}
+
+// NewULID returns a 26 characters ulid, a 48 bits millisecond timestamp
+// followed by 80 random bits encoded in Crockford's base32.
+func NewULID() (string, error) {
+	var b [16]byte
+	ms := uint64(time.Now().UnixMilli())
+	for i := range 6 {
+		b[i] = byte(ms >> (8 * (5 - i)))
+	}
+	_, err := rand.Read(b[6:])
+	if err != nil {
+		return "", fmt.Errorf("error generating ulid %w", err)
+	}
+	var dst [26]byte
+	// 128 bits are encoded as 130 bits, the first character holds the 3 leading bits.
+	hi := uint64(b[0])<<56 | uint64(b[1])<<48 | uint64(b[2])<<40 | uint64(b[3])<<32 |
+		uint64(b[4])<<24 | uint64(b[5])<<16 | uint64(b[6])<<8 | uint64(b[7])
+	lo := uint64(b[8])<<56 | uint64(b[9])<<48 | uint64(b[10])<<40 | uint64(b[11])<<32 |
+		uint64(b[12])<<24 | uint64(b[13])<<16 | uint64(b[14])<<8 | uint64(b[15])
+	for i := 25; i >= 0; i-- {
+		dst[i] = crockfordAlphabet[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(dst[:]), nil
+}
+
+// NextVal reserves the next value of the given sequence.
+func NextVal[K any](ctx context.Context, db Executor, sequence string) (K, error) {
+	var id K
+	err := db.QueryRow(ctx, "SELECT nextval($1::text::regclass)", sequence).Scan(&id)
+	if err != nil {
+		return id, fmt.Errorf("error at nextval of sequence %s %w", sequence, err)
+	}
+	return id, nil
+}
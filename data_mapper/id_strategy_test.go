@@ -0,0 +1,103 @@
+package data_mapper
+
+import (
+	"clearly-not-a-secret-project/interfaces"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// The row of an id column of the oid followed by a text column,
+// decoded from the binary format as pgx does.
+type fakeIdRows struct {
+	pgx.Rows
+	types  *pgtype.Map
+	oids   []uint32
+	values [][]byte
+}
+
+func newFakeIdRows(t *testing.T, oid uint32, id any) *fakeIdRows {
+	types := pgtype.NewMap()
+	r := &fakeIdRows{types: types, oids: []uint32{oid, pgtype.TextOID}}
+	for i, v := range []any{id, "name"} {
+		value, err := types.Encode(r.oids[i], pgtype.BinaryFormatCode, v, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.values = append(r.values, value)
+	}
+	return r
+}
+
+func (r *fakeIdRows) FieldDescriptions() []pgconn.FieldDescription {
+	fields := make([]pgconn.FieldDescription, 0, len(r.oids))
+	for _, v := range r.oids {
+		fields = append(fields, pgconn.FieldDescription{DataTypeOID: v, Format: pgtype.BinaryFormatCode})
+	}
+	return fields
+}
+
+func (r *fakeIdRows) Scan(dest ...any) error {
+	for i, v := range dest {
+		if v == nil {
+			continue
+		}
+		err := r.types.Scan(r.oids[i], pgtype.BinaryFormatCode, r.values[i], v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getIdOf[K comparable](t *testing.T, oid uint32, id any) K {
+	d := PostgreSQLDataMapper[interfaces.DomainObject[K], K]{}
+	got, err := d.getId(newFakeIdRows(t, oid, id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestPostgreSQLDataMapper_getId(t *testing.T) {
+	if id := getIdOf[int](t, pgtype.Int8OID, int64(7)); id != 7 {
+		t.Fatalf("expected the int id 7 got %d", id)
+	}
+	if id := getIdOf[int32](t, pgtype.Int8OID, int64(7)); id != 7 {
+		t.Fatalf("expected the int32 id 7 of a bigserial got %d", id)
+	}
+	if id := getIdOf[int64](t, pgtype.Int4OID, int32(7)); id != 7 {
+		t.Fatalf("expected the int64 id 7 of a serial got %d", id)
+	}
+	expected := uuid.Must(uuid.NewV7())
+	if id := getIdOf[uuid.UUID](t, pgtype.UUIDOID, [16]byte(expected)); id != expected {
+		t.Fatalf("expected the uuid id %s got %s", expected, id)
+	}
+	if id := getIdOf[string](t, pgtype.UUIDOID, [16]byte(expected)); id != expected.String() {
+		t.Fatalf("expected the string id %s got %s", expected, id)
+	}
+}
+
+func TestNewULID(t *testing.T) {
+	prev := ""
+	for range 100 {
+		id, err := NewULID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(id) != 26 {
+			t.Fatalf("expected 26 characters got %d in %s", len(id), id)
+		}
+		if strings.Trim(id, crockfordAlphabet) != "" {
+			t.Fatalf("the ulid %s is not encoded in Crockford's base32", id)
+		}
+		if id[:10] < prev[:min(len(prev), 10)] {
+			t.Fatalf("the ulid %s timestamp is lower than the previous %s", id, prev)
+		}
+		prev = id
+	}
+}
@@ -0,0 +1,342 @@
+package data_mapper
+
+import (
+	"clearly-not-a-secret-project/domain_events"
+	"clearly-not-a-secret-project/interfaces"
+	"clearly-not-a-secret-project/query_object"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// InMemoryDataMapper keeps the objects in memory in place of the rows of a
+// table, it replaces a PostgreSQLDataMapper in the tests of the domain. A
+// copy of each object built by DoCopy is stored as its row, so the changes of
+// an object are only found by another identity map once it's updated, and the
+// objects found, copied from their rows, are kept in the LoadedMap. The query objects of FindMany and Count are
+// evaluated against the values read by the Columns. The values generated by
+// the database, the audit, the tenancy and the soft delete are not emulated.
+type InMemoryDataMapper[T interfaces.DomainObject[K], K comparable] struct {
+	LoadedMap  map[K]T
+	Table      string
+	DomainType reflect.Type
+	// Columns read the values of the columns of an object, the
+	// columns compared by the criteria of the query objects.
+	Columns map[string]func(obj T) any
+	NextId  func(ctx context.Context) (K, error)
+	DoSetId func(obj T, id K) error
+	// DoCopy builds a new object holding a copy of the values of the
+	// mapped fields of obj, as its row holds them.
+	DoCopy      func(obj T) (T, error)
+	LazyLoading bool
+	CreateGhost func(id K) T
+	// DoLoadLine sets the fields of the ghost to a copy of those of row.
+	DoLoadLine func(row T, obj T) error
+	// Outbox receives the events pulled from the objects that
+	// implement interfaces.EventRecorder once they are saved.
+	Outbox []domain_events.Event
+	rows   map[K]T
+	ids    []K
+}
+
+// Sequence returns the NextId of the in-memory data mappers of the objects
+// whose ids are generated by the database, it counts from 1.
+func Sequence[K ~int | ~int32 | ~int64]() func(ctx context.Context) (K, error) {
+	var last K
+	return func(ctx context.Context) (K, error) {
+		last++
+		return last, nil
+	}
+}
+
+func (d *InMemoryDataMapper[T, K]) Type() reflect.Type {
+	return d.DomainType
+}
+
+// The identity map, created by the first object found or saved.
+func (d *InMemoryDataMapper[T, K]) loaded() map[K]T {
+	if d.LoadedMap == nil {
+		d.LoadedMap = make(map[K]T)
+	}
+	return d.LoadedMap
+}
+
+// ClonePointer returns a pointer to a copy of the value v points to, the
+// in-memory data mappers copy the pointer fields of the objects with it.
+func ClonePointer[T any](v *T) *T {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
+
+// CopyJSON returns a copy of v encoded and decoded as json, the in-memory
+// data mappers copy the json fields of the objects with it.
+func CopyJSON[T any](v T) (T, error) {
+	var c T
+	data, err := json.Marshal(v)
+	if err != nil {
+		return c, fmt.Errorf("error encoding the value %v %w", v, err)
+	}
+	err = json.Unmarshal(data, &c)
+	if err != nil {
+		return c, fmt.Errorf("error decoding the value %v %w", v, err)
+	}
+	return c, nil
+}
+
+func (d *InMemoryDataMapper[T, K]) copyOf(obj T) (T, error) {
+	if d.DoCopy == nil {
+		var nilT T
+		return nilT, fmt.Errorf("the in-memory data mapper of type %v can't copy its objects without DoCopy", d.DomainType)
+	}
+	return d.DoCopy(obj)
+}
+
+// Stores the row of the object and keeps the object in the identity map.
+func (d *InMemoryDataMapper[T, K]) save(obj T) error {
+	row, err := d.copyOf(obj)
+	if err != nil {
+		return err
+	}
+	if d.rows == nil {
+		d.rows = make(map[K]T)
+	}
+	if _, ok := d.rows[obj.Id()]; !ok {
+		d.ids = append(d.ids, obj.Id())
+	}
+	d.rows[obj.Id()] = row
+	d.loaded()[obj.Id()] = obj
+	if tracker, ok := any(obj).(interfaces.DirtyTracker); ok {
+		tracker.ClearDirtyColumns()
+	}
+	if recorder, ok := any(obj).(interfaces.EventRecorder); ok {
+		d.Outbox = append(d.Outbox, recorder.PullEvents()...)
+	}
+	return nil
+}
+
+// Sets the next id to the object without id, the objects with an id keep it.
+func (d *InMemoryDataMapper[T, K]) assignId(ctx context.Context, obj T) error {
+	var nilK K
+	if d.NextId == nil || obj.Id() != nilK {
+		return nil
+	}
+	id, err := d.NextId(ctx)
+	if err != nil {
+		return err
+	}
+	return d.DoSetId(obj, id)
+}
+
+func (d *InMemoryDataMapper[T, K]) Insert(ctx context.Context, obj T) (K, error) {
+	var nilK K
+	err := d.assignId(ctx, obj)
+	if err != nil {
+		return nilK, err
+	}
+	if _, ok := d.rows[obj.Id()]; ok {
+		return nilK, fmt.Errorf("the object %v of type %v already exists", obj.Id(), d.DomainType)
+	}
+	err = d.save(obj)
+	if err != nil {
+		return nilK, err
+	}
+	return obj.Id(), nil
+}
+
+// Upsert inserts the object or replaces the one with the same id.
+func (d *InMemoryDataMapper[T, K]) Upsert(ctx context.Context, obj T) (K, error) {
+	var nilK K
+	err := d.assignId(ctx, obj)
+	if err != nil {
+		return nilK, err
+	}
+	err = d.save(obj)
+	if err != nil {
+		return nilK, err
+	}
+	return obj.Id(), nil
+}
+
+// InsertMany inserts all the objects or, when any of them already
+// exists, none of them.
+func (d *InMemoryDataMapper[T, K]) InsertMany(ctx context.Context, objs []T) ([]K, error) {
+	ids := make([]K, 0, len(objs))
+	for _, v := range objs {
+		err := d.assignId(ctx, v)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := d.rows[v.Id()]; ok || slices.Contains(ids, v.Id()) {
+			return nil, fmt.Errorf("the object %v of type %v already exists", v.Id(), d.DomainType)
+		}
+		ids = append(ids, v.Id())
+	}
+	for _, v := range objs {
+		err := d.save(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+func (d *InMemoryDataMapper[T, K]) Update(ctx context.Context, obj T) error {
+	if _, ok := d.rows[obj.Id()]; !ok {
+		return fmt.Errorf("%w: %v %v", ErrNotFound, d.DomainType, obj.Id())
+	}
+	return d.save(obj)
+}
+
+func (d *InMemoryDataMapper[T, K]) Remove(ctx context.Context, id K) error {
+	if _, ok := d.rows[id]; ok {
+		delete(d.rows, id)
+		d.ids = slices.DeleteFunc(d.ids, func(v K) bool { return v == id })
+	}
+	delete(d.LoadedMap, id)
+	return nil
+}
+
+// The object of the row with the id from the identity map or, once
+// copied from the row, added to it.
+func (d *InMemoryDataMapper[T, K]) loadRow(id K) (T, error) {
+	if obj, ok := d.loaded()[id]; ok {
+		return obj, nil
+	}
+	row, ok := d.rows[id]
+	if !ok {
+		var nilT T
+		return nilT, fmt.Errorf("%w: %v %v", ErrNotFound, d.DomainType, id)
+	}
+	obj, err := d.copyOf(row)
+	if err != nil {
+		return obj, err
+	}
+	d.loaded()[id] = obj
+	return obj, nil
+}
+
+func (d *InMemoryDataMapper[T, K]) Find(ctx context.Context, id K) (T, error) {
+	if obj, ok := d.loaded()[id]; ok {
+		return obj, nil
+	}
+	if d.LazyLoading && d.CreateGhost != nil {
+		result := d.CreateGhost(id)
+		d.loaded()[id] = result
+		return result, nil
+	}
+	return d.loadRow(id)
+}
+
+// Loads the ghost with the row of its id.
+func (d *InMemoryDataMapper[T, K]) Load(obj T) error {
+	if !obj.IsGhost() {
+		return fmt.Errorf("assertion error: the object to load is not a ghost")
+	}
+	if d.DoLoadLine == nil {
+		return fmt.Errorf("the in-memory data mapper of type %v can't load its ghosts without DoLoadLine", d.DomainType)
+	}
+	row, ok := d.rows[obj.Id()]
+	if !ok {
+		return fmt.Errorf("%w: %v %v", ErrNotFound, d.DomainType, obj.Id())
+	}
+	err := obj.MarkLoading()
+	if err != nil {
+		return err
+	}
+	err = d.DoLoadLine(row, obj)
+	if err != nil {
+		return err
+	}
+	err = obj.MarkLoaded()
+	if err != nil {
+		return err
+	}
+	if tracker, ok := any(obj).(interfaces.DirtyTracker); ok {
+		tracker.ClearDirtyColumns()
+	}
+	return nil
+}
+
+// The rows of the source, it must be a query object of the table.
+func (d *InMemoryDataMapper[T, K]) selectRows(source StatementSource) ([]T, error) {
+	if marked, ok := source.(includingDeleted); ok {
+		source = marked.StatementSource
+	}
+	q, ok := source.(*query_object.QueryObject)
+	if !ok {
+		return nil, fmt.Errorf("the in-memory data mapper of type %v only evaluates query objects", d.DomainType)
+	}
+	if q.Table() != d.Table {
+		return nil, fmt.Errorf("the query of the table %s can't be evaluated by the data mapper of %s", q.Table(), d.Table)
+	}
+	err := q.Err()
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]T, 0, len(d.ids))
+	for _, v := range d.ids {
+		rows = append(rows, d.rows[v])
+	}
+	return query_object.Select(q, rows, func(obj T) query_object.Row {
+		return func(column string) (any, error) {
+			get, ok := d.Columns[column]
+			if !ok {
+				return nil, fmt.Errorf("the column %s of type %v can't be read in memory", column, d.DomainType)
+			}
+			return get(obj), nil
+		}
+	})
+}
+
+// FindMany returns the objects of the rows selected by the query object
+// from the identity map, the rows are evaluated as they were saved.
+func (d *InMemoryDataMapper[T, K]) FindMany(ctx context.Context, source StatementSource) ([]T, error) {
+	rows, err := d.selectRows(source)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]T, 0, len(rows))
+	for _, v := range rows {
+		obj, err := d.loadRow(v.Id())
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, obj)
+	}
+	return result, nil
+}
+
+func (d *InMemoryDataMapper[T, K]) Count(ctx context.Context, source StatementSource) (int64, error) {
+	rows, err := d.selectRows(source)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(rows)), nil
+}
+
+func (d *InMemoryDataMapper[T, K]) Exists(ctx context.Context, id K) (bool, error) {
+	_, ok := d.rows[id]
+	return ok, nil
+}
+
+// The objects in memory are not loaded from rows.
+func (d *InMemoryDataMapper[T, K]) getId(rows pgx.Rows) (K, error) {
+	var nilK K
+	return nilK, fmt.Errorf("the in-memory data mapper of type %v reads no rows", d.DomainType)
+}
+
+func (d *InMemoryDataMapper[T, K]) load(loaded map[K]T, resultSet pgx.Rows) (T, error) {
+	var nilT T
+	return nilT, fmt.Errorf("the in-memory data mapper of type %v reads no rows", d.DomainType)
+}
+
+func (d *InMemoryDataMapper[T, K]) loadAll(loaded map[K]T, resultSet pgx.Rows) ([]T, error) {
+	return nil, fmt.Errorf("the in-memory data mapper of type %v reads no rows", d.DomainType)
+}
@@ -0,0 +1,240 @@
+package data_mapper
+
+import (
+	"clearly-not-a-secret-project/interfaces"
+	"clearly-not-a-secret-project/lazy_loading"
+	"clearly-not-a-secret-project/query_object"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+type scoredObject struct {
+	id     int64
+	name   string
+	score  *int
+	status lazy_loading.LoadStatus
+}
+
+func (o *scoredObject) Id() int64          { return o.id }
+func (o *scoredObject) Type() reflect.Type { return reflect.TypeOf(o) }
+func (o *scoredObject) IsGhost() bool      { return o.status == lazy_loading.GHOST }
+func (o *scoredObject) IsLoaded() bool     { return o.status == lazy_loading.LOADED }
+
+func (o *scoredObject) MarkLoading() error {
+	if o.status != lazy_loading.GHOST {
+		return fmt.Errorf("the object %d is not a ghost", o.id)
+	}
+	o.status = lazy_loading.LOADING
+	return nil
+}
+
+func (o *scoredObject) MarkLoaded() error {
+	if o.status != lazy_loading.LOADING {
+		return fmt.Errorf("the object %d is not loading", o.id)
+	}
+	o.status = lazy_loading.LOADED
+	return nil
+}
+
+func (o *scoredObject) SetId(id int64)      { o.id = id }
+func (o *scoredObject) SetName(name string) { o.name = name }
+func (o *scoredObject) SetScore(score *int) { o.score = score }
+
+func newScoredObject(name string, score *int) *scoredObject {
+	return &scoredObject{name: name, score: score, status: lazy_loading.LOADED}
+}
+
+func newScoredMapper() *InMemoryDataMapper[interfaces.DomainObject[int64], int64] {
+	return &InMemoryDataMapper[interfaces.DomainObject[int64], int64]{
+		Table:      "scored",
+		DomainType: reflect.TypeOf(&scoredObject{}),
+		Columns: map[string]func(obj interfaces.DomainObject[int64]) any{
+			"id":    func(obj interfaces.DomainObject[int64]) any { return obj.(*scoredObject).id },
+			"name":  func(obj interfaces.DomainObject[int64]) any { return obj.(*scoredObject).name },
+			"score": func(obj interfaces.DomainObject[int64]) any { return obj.(*scoredObject).score },
+		},
+		NextId: Sequence[int64](),
+		DoSetId: func(obj interfaces.DomainObject[int64], id int64) error {
+			obj.(*scoredObject).SetId(id)
+			return nil
+		},
+		DoCopy: func(obj interfaces.DomainObject[int64]) (interfaces.DomainObject[int64], error) {
+			subject := obj.(*scoredObject)
+			c := newScoredObject(subject.name, ClonePointer(subject.score))
+			c.SetId(subject.id)
+			return c, nil
+		},
+		CreateGhost: func(id int64) interfaces.DomainObject[int64] {
+			return &scoredObject{id: id}
+		},
+		DoLoadLine: func(row interfaces.DomainObject[int64], obj interfaces.DomainObject[int64]) error {
+			subject := obj.(*scoredObject)
+			subject.SetName(row.(*scoredObject).name)
+			subject.SetScore(ClonePointer(row.(*scoredObject).score))
+			return nil
+		},
+	}
+}
+
+func TestInMemoryDataMapper(t *testing.T) {
+	ctx := context.Background()
+	d := newScoredMapper()
+	one, two := 1, 2
+	objs := []*scoredObject{newScoredObject("a", &two), newScoredObject("b", &one), newScoredObject("c", nil)}
+	for i, v := range objs {
+		id, err := d.Insert(ctx, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != int64(i+1) {
+			t.Fatalf("expected the id %d got %d", i+1, id)
+		}
+	}
+	if _, err := d.Insert(ctx, objs[0]); err == nil {
+		t.Fatal("expected an error inserting an object twice")
+	}
+	found, err := d.Find(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found != objs[0] {
+		t.Fatal("expected the object of the identity map")
+	}
+	if _, err = d.Find(ctx, 4); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound got %v", err)
+	}
+	objs[0].SetName("d")
+	*objs[0].score = 3
+	d.LoadedMap = nil
+	found, err = d.Find(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == objs[0] || found.(*scoredObject).name != "a" || *found.(*scoredObject).score != 2 {
+		t.Fatalf("expected a copy of the row as it was saved got %v", found)
+	}
+	*objs[0].score = 2
+	err = d.Update(ctx, objs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = d.Update(ctx, &scoredObject{id: 4}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound updating a missing object got %v", err)
+	}
+	query := query_object.New("scored", "id", "name", "score").
+		Where(query_object.IsNotNull("score")).
+		OrderBy(query_object.Asc("score"))
+	many, err := d.FindMany(ctx, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(many) != 2 || many[0].Id() != 2 || many[1] != any(objs[0]) {
+		t.Fatalf("expected the objects with a score by score got %v", many)
+	}
+	count, err := d.Count(ctx, query_object.New("scored", "id").Where(query_object.Like("name", "_")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Fatalf("expected 3 objects got %d", count)
+	}
+	if _, err = d.FindMany(ctx, NewStatement("SELECT id FROM scored;")); err == nil {
+		t.Fatal("expected an error for a source that is not a query object")
+	}
+	if _, err = d.FindMany(ctx, query_object.New("other", "id")); err == nil {
+		t.Fatal("expected an error for a query of another table")
+	}
+	err = d.Remove(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exists, err := d.Exists(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatal("expected the removed object not to exist")
+	}
+	many, err = d.FindMany(ctx, query_object.New("scored", "id").OrderBy(query_object.Desc("id")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]int64, 0, len(many))
+	for _, v := range many {
+		ids = append(ids, v.Id())
+	}
+	if !slices.Equal(ids, []int64{3, 1}) {
+		t.Fatalf("expected the ids 3, 1 got %v", ids)
+	}
+}
+
+func TestInMemoryDataMapper_Load(t *testing.T) {
+	ctx := context.Background()
+	d := newScoredMapper()
+	score := 1
+	_, err := d.Insert(ctx, newScoredObject("a", &score))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.LoadedMap = nil
+	d.LazyLoading = true
+	ghost, err := d.Find(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ghost.IsGhost() {
+		t.Fatal("expected a ghost")
+	}
+	err = d.Load(ghost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := ghost.(*scoredObject)
+	if !loaded.IsLoaded() || loaded.name != "a" || *loaded.score != 1 {
+		t.Fatalf("expected the ghost to be loaded with its row got %v", loaded)
+	}
+	*loaded.score = 2
+	if *d.rows[1].(*scoredObject).score != 1 {
+		t.Fatal("expected the loaded object not to share the values of its row")
+	}
+	if err = d.Load(ghost); err == nil {
+		t.Fatal("expected an error loading a loaded object")
+	}
+}
+
+func TestInMemoryDataMapper_InsertMany(t *testing.T) {
+	ctx := context.Background()
+	d := newScoredMapper()
+	_, err := d.Insert(ctx, &scoredObject{id: 2, status: lazy_loading.LOADED})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.InsertMany(ctx, []interfaces.DomainObject[int64]{&scoredObject{id: 1}, &scoredObject{id: 2}})
+	if err == nil {
+		t.Fatal("expected an error inserting an existing object")
+	}
+	if exists, _ := d.Exists(ctx, 1); exists {
+		t.Fatal("expected none of the objects to be inserted")
+	}
+}
+
+func TestInMemoryDataMapper_Outbox(t *testing.T) {
+	d := &InMemoryDataMapper[interfaces.DomainObject[string], string]{
+		DoCopy: func(obj interfaces.DomainObject[string]) (interfaces.DomainObject[string], error) {
+			return &recordingObject{id: obj.Id()}, nil
+		},
+	}
+	obj := &recordingObject{id: "a"}
+	obj.RecordEvent(renamed{Name: "b"})
+	_, err := d.Insert(context.Background(), obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Outbox) != 1 || len(obj.PendingEvents()) != 0 {
+		t.Fatalf("expected the event to be pulled to the outbox got %v", d.Outbox)
+	}
+}
@@ -0,0 +1,59 @@
+package data_mapper
+
+import (
+	"context"
+	"reflect"
+)
+
+// SubtypeStatements are the statements writing the objects of one
+// type of a hierarchy, they may write to several tables at once.
+type SubtypeStatements struct {
+	InsertStatement string
+	UpdateStatement string
+}
+
+// Subtypes returns the types of the hierarchy mapped by the data
+// mapper, the registry resolves each one of them to the mapper.
+func (d PostgreSQLDataMapper[T, K]) Subtypes() []reflect.Type {
+	types := make([]reflect.Type, 0, len(d.SubtypeStatements))
+	for t := range d.SubtypeStatements {
+		types = append(types, t)
+	}
+	return types
+}
+
+// The insert statement of the type of obj.
+func (d PostgreSQLDataMapper[T, K]) insertStatement(obj T) string {
+	if s, ok := d.SubtypeStatements[reflect.TypeOf(obj)]; ok {
+		return s.InsertStatement
+	}
+	return d.InsertStatement
+}
+
+// The update statement of the type of obj.
+func (d PostgreSQLDataMapper[T, K]) updateStatement(obj T) string {
+	if s, ok := d.SubtypeStatements[reflect.TypeOf(obj)]; ok {
+		return s.UpdateStatement
+	}
+	return d.UpdateStatement
+}
+
+// Inserts the objects of a hierarchy one by one in a transaction,
+// their types are written with different statements.
+func (d PostgreSQLDataMapper[T, K]) insertEach(ctx context.Context, objs []T) ([]K, error) {
+	ids := make([]K, 0, len(objs))
+	err := d.inTx(ctx, func(ctx context.Context) error {
+		for _, obj := range objs {
+			id, err := d.Insert(ctx, obj)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
@@ -0,0 +1,56 @@
+package data_mapper
+
+import (
+	"clearly-not-a-secret-project/interfaces"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestPostgreSQLDataMapper_subtypeStatements(t *testing.T) {
+	d := PostgreSQLDataMapper[interfaces.DomainObject[string], string]{
+		Table:           "payment",
+		InsertStatement: "INSERT INTO payment (id) VALUES ($1);",
+		LoadedMap:       make(map[string]interfaces.DomainObject[string]),
+		DoInsert: func(obj interfaces.DomainObject[string], stmt *PreparedStatement) error {
+			stmt.Append(obj.Id())
+			return nil
+		},
+		DoUpdate: func(obj interfaces.DomainObject[string], stmt *PreparedStatement) error {
+			stmt.Append(obj.Id())
+			return nil
+		},
+		SubtypeStatements: map[reflect.Type]SubtypeStatements{
+			reflect.TypeOf(&recordingObject{}): {
+				InsertStatement: "INSERT INTO payment (kind, id) VALUES ('card', $1);",
+				UpdateStatement: "UPDATE payment SET kind = 'card' WHERE ID = $1",
+			},
+		},
+	}
+	if subtypes := d.Subtypes(); len(subtypes) != 1 || subtypes[0] != reflect.TypeOf(&recordingObject{}) {
+		t.Fatalf("unexpected subtypes %v", subtypes)
+	}
+	b := NewBatch()
+	ctx := WithBatch(context.Background(), b)
+	obj := &recordingObject{id: "a"}
+	_, err := d.Insert(ctx, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.Update(ctx, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"INSERT INTO payment (kind, id) VALUES ('card', $1);",
+		"UPDATE payment SET kind = 'card' WHERE ID = $1",
+	}
+	if b.Len() != len(expected) {
+		t.Fatalf("expected %d queued statements got %d", len(expected), b.Len())
+	}
+	for i, v := range b.queued {
+		if v.stmt.query != expected[i] {
+			t.Fatalf("expected statement %d to be %s got %s", i, expected[i], v.stmt.query)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package data_mapper
+
+import (
+	"clearly-not-a-secret-project/interfaces"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// PostgreSQL accepts at most 65535 parameters in a single statement.
+const maxParameters = 65535
+
+// The error codes of PostgreSQL refusing a copy.
+const (
+	featureNotSupported   = "0A000"
+	insufficientPrivilege = "42501"
+)
+
+// InsertMany inserts all the objects using the COPY protocol. It falls back
+// to multi-row insert statements when the executor can't copy or the
+// database refuses the copy. The objects with values generated by the
+// database are inserted one statement each, the rows returned by a
+// multi-row insert are in no guaranteed order to write them back. When
+// the context carries a batch the insert statements are queued into it.
+// The inserted objects enter the identity map only if all of them are inserted.
+// The objects of a hierarchy are inserted one by one in a transaction.
+func (d PostgreSQLDataMapper[T, K]) InsertMany(ctx context.Context, objs []T) ([]K, error) {
+	var nilK K
+	if len(objs) == 0 {
+		return []K{}, nil
+	}
+	if len(d.SubtypeStatements) > 0 {
+		return d.insertEach(ctx, objs)
+	}
+	db := d.executor(ctx)
+	rows := make([][]any, 0, len(objs))
+	now := time.Now()
+	for _, obj := range objs {
+		if d.NextId != nil && obj.Id() == nilK {
+			id, err := d.NextId(ctx, db)
+			if err != nil {
+				return nil, err
+			}
+			err = d.DoSetId(obj, id)
+			if err != nil {
+				return nil, err
+			}
+		}
+		stmt := &PreparedStatement{
+			conn:  db,
+			query: d.InsertStatement,
+			args:  make([]interface{}, 0, len(d.InsertColumns)),
+		}
+		err := d.DoInsert(obj, stmt)
+		if err != nil {
+			return nil, err
+		}
+		err = d.appendAudit(ctx, stmt, now, true)
+		if err != nil {
+			return nil, err
+		}
+		err = d.appendTenant(ctx, stmt)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, stmt.args)
+	}
+	loaded, err := d.identityMap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	onSuccess := func() {
+		for _, obj := range objs {
+			for _, v := range d.changedAssociations(obj) {
+				v.Commit()
+			}
+			loaded[obj.Id()] = obj
+			if tracker, ok := any(obj).(interfaces.DirtyTracker); ok {
+				tracker.ClearDirtyColumns()
+			}
+			if recorder, ok := any(obj).(interfaces.EventRecorder); ok && d.OutboxTable != "" {
+				recorder.PullEvents()
+			}
+		}
+	}
+	recorded := slices.ContainsFunc(objs, func(obj T) bool {
+		return len(d.pendingEvents(obj)) > 0 || len(d.changedAssociations(obj)) > 0
+	})
+	if b, ok := BatchFrom(ctx); ok {
+		err = d.queueRows(ctx, b, db, objs, rows, onSuccess)
+	} else if d.HistoryTable == "" && !recorded && len(d.Projections) == 0 {
+		err = d.insertAll(ctx, db, objs, rows)
+	} else {
+		err = d.inTx(ctx, func(ctx context.Context) error {
+			return d.insertAndRecord(ctx, objs, rows)
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := BatchFrom(ctx); !ok {
+		onSuccess()
+	}
+	ids := make([]K, 0, len(objs))
+	for _, obj := range objs {
+		ids = append(ids, obj.Id())
+	}
+	return ids, nil
+}
+
+func (d PostgreSQLDataMapper[T, K]) insertAll(ctx context.Context, db Executor, objs []T, rows [][]any) error {
+	copier, ok := db.(Copier)
+	if !ok || d.DoReturning != nil || len(d.InsertColumns) == 0 {
+		return d.insertRows(ctx, db, objs, rows)
+	}
+	err := d.copyRows(ctx, copier, rows)
+	if copyRefused(err) {
+		return d.insertRows(ctx, db, objs, rows)
+	}
+	return err
+}
+
+// Copies the rows into the table, inside a transaction the copy runs in a
+// savepoint so that the transaction outlives a refused copy.
+func (d PostgreSQLDataMapper[T, K]) copyRows(ctx context.Context, copier Copier, rows [][]any) error {
+	table := pgx.Identifier(strings.Split(d.Table, "."))
+	tx, ok := copier.(pgx.Tx)
+	if !ok {
+		_, err := copier.CopyFrom(ctx, table, d.InsertColumns, pgx.CopyFromRows(rows))
+		return err
+	}
+	savepoint, err := tx.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error at begin savepoint %w", err)
+	}
+	_, err = savepoint.CopyFrom(ctx, table, d.InsertColumns, pgx.CopyFromRows(rows))
+	if err != nil {
+		rollbackErr := savepoint.Rollback(ctx)
+		if rollbackErr != nil {
+			return fmt.Errorf("%w\nerror at rollback %w", err, rollbackErr)
+		}
+		return err
+	}
+	return savepoint.Commit(ctx)
+}
+
+// Reports whether the database refused the copy, it isn't supported
+// or the user isn't granted it, the rows can still be inserted.
+func copyRefused(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == featureNotSupported || pgErr.Code == insufficientPrivilege
+}
+
+// Queues the insert statements into the batch, followed by the
+// history, the associations, the events and the projections of each object,
+// their ids must be known before sending the batch to write them. The COPY
+// protocol can't be batched.
+func (d PostgreSQLDataMapper[T, K]) queueRows(ctx context.Context, b *Batch, db Executor, objs []T, rows [][]any, onSuccess func()) error {
+	var nilK K
+	after := make([]*PreparedStatement, 0)
+	for _, obj := range objs {
+		if !d.writesAfter(insertOperation, obj) {
+			continue
+		}
+		if obj.Id() == nilK {
+			return fmt.Errorf("the history, associations, events and projections of type %v can't be batched before the database generates the id", d.DomainType)
+		}
+		stmts, err := d.after(ctx, insertOperation, true, obj.Id(), obj)
+		if err != nil {
+			return err
+		}
+		after = append(after, stmts...)
+	}
+	queued := d.insertStmts(db, objs, rows)
+	for _, v := range after {
+		queued = append(queued, &queuedStatement{stmt: v, object: objs})
+	}
+	// the objects are inserted once the last statement succeeds
+	queued[len(queued)-1].onSuccess = onSuccess
+	b.queued = append(b.queued, queued...)
+	return nil
+}
+
+// Inserts the rows followed by their history, their associations,
+// the events recorded by the objects and their projections.
+func (d PostgreSQLDataMapper[T, K]) insertAndRecord(ctx context.Context, objs []T, rows [][]any) error {
+	err := d.insertAll(ctx, d.executor(ctx), objs, rows)
+	if err != nil {
+		return err
+	}
+	if d.HistoryTable != "" {
+		actor, err := d.actor(ctx)
+		if err != nil {
+			return err
+		}
+		ids := make([]K, 0, len(objs))
+		for _, obj := range objs {
+			ids = append(ids, obj.Id())
+		}
+		err = d.run(ctx, d.historyInsert(ids, insertOperation, actor), nil)
+		if err != nil {
+			return fmt.Errorf("error writing the history %w", err)
+		}
+	}
+	for _, obj := range objs {
+		for _, v := range d.associate(insertOperation, obj.Id(), obj) {
+			err := d.run(ctx, v, nil)
+			if err != nil {
+				return fmt.Errorf("error writing the associations %w", err)
+			}
+		}
+		outbox, err := d.outbox(obj.Id(), obj)
+		if err != nil {
+			return err
+		}
+		for _, v := range outbox {
+			err = d.run(ctx, v, nil)
+			if err != nil {
+				return fmt.Errorf("error writing the outbox %w", err)
+			}
+		}
+		projections, err := d.project(ctx, insertOperation, obj.Id(), obj)
+		if err != nil {
+			return err
+		}
+		for _, v := range projections {
+			err = d.run(ctx, v, nil)
+			if err != nil {
+				return fmt.Errorf("error writing the projections %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (d PostgreSQLDataMapper[T, K]) insertRows(ctx context.Context, db Executor, objs []T, rows [][]any) error {
+	for _, v := range d.insertStmts(db, objs, rows) {
+		if v.returning == nil {
+			_, err := v.stmt.Execute(ctx)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		err := returningRow(ctx, v.stmt, v.returning)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The insert statements of the rows, as few multi-row statements as the
+// parameters limit allows. The objects with values to write back are
+// inserted one statement each, reading the row returned by its insert.
+func (d PostgreSQLDataMapper[T, K]) insertStmts(db Executor, objs []T, rows [][]any) []*queuedStatement {
+	if d.DoReturning != nil {
+		stmts := make([]*queuedStatement, 0, len(objs))
+		for i, obj := range objs {
+			stmts = append(stmts, &queuedStatement{
+				stmt:   &PreparedStatement{conn: db, query: d.insertStatement(obj), args: rows[i]},
+				object: obj,
+				returning: func(resultSet pgx.Rows) error {
+					return d.DoReturning(resultSet, obj)
+				},
+			})
+		}
+		return stmts
+	}
+	size := d.chunkSize(len(rows))
+	stmts := make([]*queuedStatement, 0, len(rows)/size+1)
+	for start := 0; start < len(rows); start += size {
+		end := min(start+size, len(rows))
+		stmts = append(stmts, &queuedStatement{stmt: d.insertRowsChunk(db, rows[start:end]), object: objs[start:end]})
+	}
+	return stmts
+}
+
+// The number of rows inserted by a statement within the parameters limit.
+func (d PostgreSQLDataMapper[T, K]) chunkSize(n int) int {
+	if len(d.InsertColumns) == 0 {
+		return n
+	}
+	return min(n, maxParameters/len(d.InsertColumns))
+}
+
+// The multi-row insert statement of the rows of a chunk.
+func (d PostgreSQLDataMapper[T, K]) insertRowsChunk(db Executor, rows [][]any) *PreparedStatement {
+	stmt := &PreparedStatement{
+		conn:  db,
+		query: d.insertRowsStmt(len(rows)),
+		args:  make([]interface{}, 0, len(rows)*len(d.InsertColumns)),
+	}
+	for _, row := range rows {
+		stmt.args = append(stmt.args, row...)
+	}
+	return stmt
+}
+
+func (d PostgreSQLDataMapper[T, K]) insertRowsStmt(n int) string {
+	var b strings.Builder
+	if len(d.InsertColumns) == 0 {
+		// rows made only of column defaults
+		fmt.Fprintf(&b, "INSERT INTO %s SELECT FROM generate_series(1, %d)%s;", d.Table, n, d.returningClause())
+		return b.String()
+	}
+	fmt.Fprintf(&b, "INSERT INTO %s (%s) VALUES ", d.Table, strings.Join(d.InsertColumns, ","))
+	param := 1
+	for i := range n {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString("(")
+		for j := range d.InsertColumns {
+			if j > 0 {
+				b.WriteString(",")
+			}
+			fmt.Fprintf(&b, "$%d", param)
+			param++
+		}
+		b.WriteString(")")
+	}
+	b.WriteString(d.returningClause())
+	b.WriteString(";")
+	return b.String()
+}
+
+func (d PostgreSQLDataMapper[T, K]) returningClause() string {
+	if len(d.ReturningColumns) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" RETURNING %s", strings.Join(d.ReturningColumns, ","))
+}
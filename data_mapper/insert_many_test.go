@@ -0,0 +1,170 @@
+package data_mapper
+
+import (
+	"clearly-not-a-secret-project/interfaces"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type fakeCopyTx struct {
+	pgx.Tx
+	copyErr    error
+	executed   []string
+	rolledBack int
+}
+
+func (f *fakeCopyTx) Begin(ctx context.Context) (pgx.Tx, error) { return f, nil }
+func (f *fakeCopyTx) Commit(ctx context.Context) error          { return nil }
+
+func (f *fakeCopyTx) Rollback(ctx context.Context) error {
+	f.rolledBack++
+	return nil
+}
+
+func (f *fakeCopyTx) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	return 0, f.copyErr
+}
+
+func (f *fakeCopyTx) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	f.executed = append(f.executed, sql)
+	return pgconn.NewCommandTag("INSERT 0 1"), nil
+}
+
+func newScoredInsertMapper(columns ...string) PostgreSQLDataMapper[interfaces.DomainObject[int64], int64] {
+	return PostgreSQLDataMapper[interfaces.DomainObject[int64], int64]{
+		Table:         "scored",
+		InsertColumns: columns,
+		LoadedMap:     map[int64]interfaces.DomainObject[int64]{},
+		DoInsert: func(obj interfaces.DomainObject[int64], stmt *PreparedStatement) error {
+			for range columns {
+				stmt.Append(obj.Id())
+			}
+			return nil
+		},
+	}
+}
+
+func TestPostgreSQLDataMapper_insertRowsStmt(t *testing.T) {
+	d := newScoredInsertMapper("id", "name")
+	d.ReturningColumns = []string{"version"}
+	expected := "INSERT INTO scored (id,name) VALUES ($1,$2),($3,$4),($5,$6) RETURNING version;"
+	if stmt := d.insertRowsStmt(3); stmt != expected {
+		t.Fatalf("expected %s got %s", expected, stmt)
+	}
+	d.InsertColumns = nil
+	expected = "INSERT INTO scored SELECT FROM generate_series(1, 2) RETURNING version;"
+	if stmt := d.insertRowsStmt(2); stmt != expected {
+		t.Fatalf("expected %s got %s", expected, stmt)
+	}
+}
+
+func TestPostgreSQLDataMapper_queueInsertMany(t *testing.T) {
+	d := newScoredInsertMapper("id", "name", "score")
+	size := maxParameters / 3
+	objs := make([]interfaces.DomainObject[int64], 0, size+1)
+	for i := range size + 1 {
+		objs = append(objs, &scoredObject{id: int64(i + 1)})
+	}
+	b := NewBatch()
+	ids, err := d.InsertMany(WithBatch(context.Background(), b), objs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != len(objs) {
+		t.Fatalf("expected %d ids got %d", len(objs), len(ids))
+	}
+	if b.Len() != 2 {
+		t.Fatalf("expected the rows to be split in 2 statements got %d", b.Len())
+	}
+	first, last := b.queued[0].stmt, b.queued[1].stmt
+	if len(first.args) != size*3 || !strings.HasSuffix(first.query, "($65533,$65534,$65535);") {
+		t.Fatalf("expected the first statement to fill the parameters got %d arguments", len(first.args))
+	}
+	if len(last.args) != 3 || last.query != "INSERT INTO scored (id,name,score) VALUES ($1,$2,$3);" {
+		t.Fatalf("expected the last statement to insert the last row got %s", last.query)
+	}
+	if len(d.LoadedMap) != 0 {
+		t.Fatal("expected the objects to enter the identity map once the batch is sent")
+	}
+	err = b.Send(context.Background(), &fakeBatcher{failAt: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.LoadedMap) != len(objs) {
+		t.Fatalf("expected %d loaded objects got %d", len(objs), len(d.LoadedMap))
+	}
+}
+
+func TestPostgreSQLDataMapper_insertAll(t *testing.T) {
+	d := newScoredInsertMapper("id")
+	objs := []interfaces.DomainObject[int64]{&scoredObject{id: 1}, &scoredObject{id: 2}}
+	rows := [][]any{{int64(1)}, {int64(2)}}
+	t.Run("CopyRefused", func(t *testing.T) {
+		tx := &fakeCopyTx{copyErr: &pgconn.PgError{Code: insufficientPrivilege}}
+		err := d.insertAll(context.Background(), tx, objs, rows)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tx.rolledBack != 1 {
+			t.Fatal("expected the savepoint of the copy to be rolled back")
+		}
+		if len(tx.executed) != 1 || tx.executed[0] != "INSERT INTO scored (id) VALUES ($1),($2);" {
+			t.Fatalf("expected the rows to be inserted by a statement got %v", tx.executed)
+		}
+	})
+	t.Run("CopyFailed", func(t *testing.T) {
+		tx := &fakeCopyTx{copyErr: &pgconn.PgError{Code: uniqueViolation}}
+		err := d.insertAll(context.Background(), tx, objs, rows)
+		if err == nil || !errors.Is(err, tx.copyErr) {
+			t.Fatalf("expected the copy error got %v", err)
+		}
+		if len(tx.executed) != 0 {
+			t.Fatalf("expected no insert statement got %v", tx.executed)
+		}
+	})
+}
+
+func TestPostgreSQLDataMapper_insertReturning(t *testing.T) {
+	d := newScoredInsertMapper("name")
+	d.InsertStatement = "INSERT INTO scored (name) VALUES ($1) RETURNING id;"
+	d.ReturningColumns = []string{"id"}
+	d.DoReturning = func(resultSet pgx.Rows, obj interfaces.DomainObject[int64]) error {
+		var id int64
+		err := resultSet.Scan(&id)
+		obj.(*scoredObject).SetId(id)
+		return err
+	}
+	objs := []interfaces.DomainObject[int64]{&scoredObject{}, &scoredObject{}, &scoredObject{}}
+	rows := [][]any{{"a"}, {"b"}, {"c"}}
+	tx := &fakeReturningTx{returned: [][]any{{int64(7)}}}
+	err := d.insertAll(context.Background(), tx, objs, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tx.queried) != len(objs) || tx.queried[0] != d.InsertStatement {
+		t.Fatalf("expected the objects to be inserted one statement each got %v", tx.queried)
+	}
+	for _, obj := range objs {
+		if obj.Id() != 7 {
+			t.Fatalf("expected the returned id to be written back got %d", obj.Id())
+		}
+	}
+	b := NewBatch()
+	_, err = d.InsertMany(WithBatch(context.Background(), b), objs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != len(objs) {
+		t.Fatalf("expected a queued statement for each object got %d", b.Len())
+	}
+	for i, v := range b.queued {
+		if v.stmt.query != d.InsertStatement || v.returning == nil || v.object != objs[i] {
+			t.Fatalf("expected the insert of the object %d reading its row got %s", i, v.stmt.query)
+		}
+	}
+}
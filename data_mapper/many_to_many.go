@@ -0,0 +1,129 @@
+package data_mapper
+
+import (
+	"clearly-not-a-secret-project/associations"
+	"clearly-not-a-secret-project/interfaces"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ManyToMany associates the objects of a data mapper to the ids of other
+// objects through the rows of a join table, the owner column holds the id
+// of the object and the target column the associated id. The join table
+// must have a unique constraint on both columns.
+type ManyToMany[T any] struct {
+	JoinTable    string
+	OwnerColumn  string
+	TargetColumn string
+	Association  func(obj T) interfaces.Association
+}
+
+// The associations of the object with changes to write.
+func (d PostgreSQLDataMapper[T, K]) changedAssociations(object any) []interfaces.Association {
+	obj, ok := object.(T)
+	if !ok {
+		return nil
+	}
+	changed := make([]interfaces.Association, 0)
+	for _, v := range d.ManyToMany {
+		association := v.Association(obj)
+		if association == nil {
+			continue
+		}
+		added, removed := association.Changes()
+		if len(added) > 0 || len(removed) > 0 {
+			changed = append(changed, association)
+		}
+	}
+	return changed
+}
+
+// The rows of the join tables of the object with the id are deleted before
+// it's removed, unless it's soft deleted. Otherwise the statements insert
+// the added ids and delete the removed ones.
+func (d PostgreSQLDataMapper[T, K]) associate(operation string, id K, object any) []*PreparedStatement {
+	stmts := make([]*PreparedStatement, 0)
+	if operation == removeOperation {
+		if d.SoftDeleteColumn != "" {
+			return nil
+		}
+		for _, v := range d.ManyToMany {
+			stmts = append(stmts, &PreparedStatement{
+				query: fmt.Sprintf("DELETE FROM %s WHERE %s = $1;", v.JoinTable, v.OwnerColumn),
+				args:  []interface{}{id},
+			})
+		}
+		return stmts
+	}
+	obj, ok := object.(T)
+	if !ok {
+		return nil
+	}
+	for _, v := range d.ManyToMany {
+		association := v.Association(obj)
+		if association == nil {
+			continue
+		}
+		added, removed := association.Changes()
+		if len(removed) > 0 {
+			stmt := &PreparedStatement{args: []interface{}{id}}
+			params := make([]string, 0, len(removed))
+			for _, target := range removed {
+				stmt.Append(target)
+				params = append(params, fmt.Sprintf("$%d", len(stmt.args)))
+			}
+			stmt.query = fmt.Sprintf("DELETE FROM %s WHERE %s = $1 AND %s IN (%s);",
+				v.JoinTable, v.OwnerColumn, v.TargetColumn, strings.Join(params, ","))
+			stmts = append(stmts, stmt)
+		}
+		if len(added) > 0 {
+			stmt := &PreparedStatement{args: []interface{}{id}}
+			rows := make([]string, 0, len(added))
+			for _, target := range added {
+				stmt.Append(target)
+				rows = append(rows, fmt.Sprintf("($1,$%d)", len(stmt.args)))
+			}
+			stmt.query = fmt.Sprintf("INSERT INTO %s (%s,%s) VALUES %s ON CONFLICT DO NOTHING;",
+				v.JoinTable, v.OwnerColumn, v.TargetColumn, strings.Join(rows, ","))
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
+
+// LoadAssociation reads the ids associated to the object with the id owner
+// through the join table of the association and loads them into ids.
+func LoadAssociation[T interfaces.DomainObject[K], K comparable, A comparable](
+	ctx context.Context,
+	d PostgreSQLDataMapper[T, K],
+	association ManyToMany[T],
+	owner K,
+	ids *associations.Ids[A],
+) ([]A, error) {
+	stmt := &PreparedStatement{
+		conn: d.executor(ctx),
+		query: fmt.Sprintf("SELECT %s FROM %s WHERE %s = $1 ORDER BY %s;",
+			association.TargetColumn, association.JoinTable, association.OwnerColumn, association.TargetColumn),
+		args: []interface{}{owner},
+	}
+	rows, err := stmt.ExecuteQuery(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error at execute query %w", err)
+	}
+	defer rows.Close()
+	loaded := make([]A, 0)
+	for rows.Next() {
+		var id A
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the association %s %w", association.JoinTable, err)
+		}
+		loaded = append(loaded, id)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	ids.Load(loaded)
+	return ids.Ids(), nil
+}
@@ -0,0 +1,84 @@
+package data_mapper
+
+import (
+	"clearly-not-a-secret-project/associations"
+	"clearly-not-a-secret-project/interfaces"
+	"context"
+	"reflect"
+	"testing"
+)
+
+type taggedObject struct {
+	id   string
+	tags associations.Ids[string]
+}
+
+func (o *taggedObject) Id() string                      { return o.id }
+func (o *taggedObject) Type() reflect.Type              { return reflect.TypeOf(o) }
+func (o *taggedObject) IsGhost() bool                   { return false }
+func (o *taggedObject) IsLoaded() bool                  { return true }
+func (o *taggedObject) MarkLoading() error              { return nil }
+func (o *taggedObject) MarkLoaded() error               { return nil }
+func (o *taggedObject) Tags() *associations.Ids[string] { return &o.tags }
+
+func TestPostgreSQLDataMapper_queueManyToMany(t *testing.T) {
+	obj := &taggedObject{id: "a"}
+	obj.Tags().Load([]string{"x", "y"})
+	obj.Tags().Remove("x")
+	obj.Tags().Add("z")
+	d := PostgreSQLDataMapper[interfaces.DomainObject[string], string]{
+		Table:           "aggregate",
+		UpdateStatement: "UPDATE aggregate SET id = $1 WHERE ID = $1",
+		RemoveStatement: "DELETE FROM aggregate WHERE ID = $1;",
+		LoadedMap:       map[string]interfaces.DomainObject[string]{"a": obj},
+		DoUpdate: func(obj interfaces.DomainObject[string], stmt *PreparedStatement) error {
+			stmt.Append(obj.Id())
+			return nil
+		},
+		ManyToMany: []ManyToMany[interfaces.DomainObject[string]]{
+			{
+				JoinTable:    "aggregate_tag",
+				OwnerColumn:  "aggregate_id",
+				TargetColumn: "tag_id",
+				Association: func(obj interfaces.DomainObject[string]) interfaces.Association {
+					return obj.(*taggedObject).Tags()
+				},
+			},
+		},
+	}
+	b := NewBatch()
+	ctx := WithBatch(context.Background(), b)
+	err := d.Update(ctx, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.Remove(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"UPDATE aggregate SET id = $1 WHERE ID = $1",
+		"DELETE FROM aggregate_tag WHERE aggregate_id = $1 AND tag_id IN ($2);",
+		"INSERT INTO aggregate_tag (aggregate_id,tag_id) VALUES ($1,$2) ON CONFLICT DO NOTHING;",
+		"DELETE FROM aggregate_tag WHERE aggregate_id = $1;",
+		"DELETE FROM aggregate WHERE ID = $1;",
+	}
+	if b.Len() != len(expected) {
+		t.Fatalf("expected %d queued statements got %d", len(expected), b.Len())
+	}
+	for i, v := range b.queued {
+		if v.stmt.query != expected[i] {
+			t.Fatalf("expected statement %d to be %s got %s", i, expected[i], v.stmt.query)
+		}
+	}
+	if b.queued[1].stmt.args[1] != "x" || b.queued[2].stmt.args[1] != "z" {
+		t.Fatalf("unexpected arguments %v %v", b.queued[1].stmt.args, b.queued[2].stmt.args)
+	}
+	err = b.Send(context.Background(), &fakeBatcher{failAt: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added, removed := obj.Tags().Changes(); len(added) != 0 || len(removed) != 0 {
+		t.Fatalf("expected the changes to be committed got %v %v", added, removed)
+	}
+}
@@ -0,0 +1,23 @@
+package data_mapper
+
+import "reflect"
+
+// FromNullable returns the value pointed by v,
+// or the zero value of T when the column was NULL.
+func FromNullable[T any](v *T) T {
+	var zero T
+	if v == nil {
+		return zero
+	}
+	return *v
+}
+
+// ToNullable returns nil, written as NULL, for the zero value
+// of T and a pointer to v otherwise. It writes the fields whose
+// zero value is NULL, they are opted in with zeroIsNull.
+func ToNullable[T any](v T) *T {
+	if reflect.ValueOf(&v).Elem().IsZero() {
+		return nil
+	}
+	return &v
+}
@@ -0,0 +1,51 @@
+package data_mapper
+
+import (
+	"clearly-not-a-secret-project/domain_events"
+	"clearly-not-a-secret-project/interfaces"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// OutboxDDL creates the outbox table written by the data mappers with an
+// OutboxTable and read by the relay, the events not yet published have
+// a null published_at.
+const OutboxDDL = `CREATE TABLE IF NOT EXISTS outbox (
+	id BIGSERIAL PRIMARY KEY,
+	aggregate_type TEXT NOT NULL,
+	aggregate_id TEXT NOT NULL,
+	event_type TEXT NOT NULL,
+	payload JSONB NOT NULL,
+	occurred_at TIMESTAMPTZ NOT NULL,
+	published_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS outbox_unpublished ON outbox (id) WHERE published_at IS NULL;`
+
+// The events recorded by the object waiting to be written to the outbox.
+func (d PostgreSQLDataMapper[T, K]) pendingEvents(object any) []domain_events.Event {
+	recorder, ok := object.(interfaces.EventRecorder)
+	if !ok || d.OutboxTable == "" {
+		return nil
+	}
+	return recorder.PendingEvents()
+}
+
+// The statements writing the pending events of the object with the given id to the outbox.
+func (d PostgreSQLDataMapper[T, K]) outbox(id K, object any) ([]*PreparedStatement, error) {
+	events := d.pendingEvents(object)
+	stmts := make([]*PreparedStatement, 0, len(events))
+	now := time.Now()
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding the event %s %w", event.EventName(), err)
+		}
+		stmts = append(stmts, &PreparedStatement{
+			query: fmt.Sprintf("INSERT INTO %s (aggregate_type, aggregate_id, event_type, payload, occurred_at) "+
+				"VALUES ($1, $2, $3, $4, $5);", d.OutboxTable),
+			args: []interface{}{d.Table, fmt.Sprint(id), event.EventName(), payload, now},
+		})
+	}
+	return stmts, nil
+}
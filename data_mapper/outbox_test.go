@@ -0,0 +1,119 @@
+package data_mapper
+
+import (
+	"clearly-not-a-secret-project/domain_events"
+	"clearly-not-a-secret-project/interfaces"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type renamed struct {
+	Name string `json:"name"`
+}
+
+func (e renamed) EventName() string {
+	return "Renamed"
+}
+
+type recordingObject struct {
+	id     string
+	events domain_events.Events
+}
+
+func (o *recordingObject) Id() string                            { return o.id }
+func (o *recordingObject) Type() reflect.Type                    { return reflect.TypeOf(o) }
+func (o *recordingObject) IsGhost() bool                         { return false }
+func (o *recordingObject) IsLoaded() bool                        { return true }
+func (o *recordingObject) MarkLoading() error                    { return nil }
+func (o *recordingObject) MarkLoaded() error                     { return nil }
+func (o *recordingObject) RecordEvent(event domain_events.Event) { o.events.Record(event) }
+func (o *recordingObject) PendingEvents() []domain_events.Event  { return o.events.Pending() }
+func (o *recordingObject) PullEvents() []domain_events.Event     { return o.events.Pull() }
+
+func TestPostgreSQLDataMapper_queueOutbox(t *testing.T) {
+	obj := &recordingObject{id: "a"}
+	obj.RecordEvent(renamed{Name: "b"})
+	obj.RecordEvent(renamed{Name: "c"})
+	d := PostgreSQLDataMapper[interfaces.DomainObject[string], string]{
+		Table:           "aggregate",
+		RemoveStatement: "DELETE FROM aggregate WHERE ID = $1;",
+		LoadedMap:       map[string]interfaces.DomainObject[string]{"a": obj},
+		OutboxTable:     "outbox",
+	}
+	b := NewBatch()
+	err := d.Remove(WithBatch(context.Background(), b), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"DELETE FROM aggregate", "INSERT INTO outbox", "INSERT INTO outbox"}
+	if b.Len() != len(expected) {
+		t.Fatalf("expected %d queued statements got %d", len(expected), b.Len())
+	}
+	for i, v := range b.queued {
+		if !strings.HasPrefix(v.stmt.query, expected[i]) {
+			t.Fatalf("expected statement %d to start with %s got %s", i, expected[i], v.stmt.query)
+		}
+	}
+	if payload := string(b.queued[1].stmt.args[3].([]byte)); payload != `{"name":"b"}` {
+		t.Fatalf("unexpected payload %s", payload)
+	}
+	if len(obj.PendingEvents()) != 2 {
+		t.Fatal("expected the events to be pending until the batch is sent")
+	}
+	err = b.Send(context.Background(), &fakeBatcher{failAt: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(obj.PendingEvents()) != 0 {
+		t.Fatalf("expected the events to be pulled got %v", obj.PendingEvents())
+	}
+}
+
+type trackedRecordingObject struct {
+	recordingObject
+	dirty []string
+}
+
+func (o *trackedRecordingObject) DirtyColumns() []string { return o.dirty }
+func (o *trackedRecordingObject) ClearDirtyColumns()     { o.dirty = nil }
+
+func TestPostgreSQLDataMapper_queueOutboxWithoutChanges(t *testing.T) {
+	obj := &trackedRecordingObject{recordingObject: recordingObject{id: "a"}}
+	obj.RecordEvent(renamed{Name: "b"})
+	d := PostgreSQLDataMapper[interfaces.DomainObject[string], string]{
+		Table:           "aggregate",
+		UpdateStatement: "UPDATE aggregate SET name = $2 WHERE ID = $1",
+		UpdateColumns:   []string{"name"},
+		LoadedMap:       map[string]interfaces.DomainObject[string]{},
+		OutboxTable:     "outbox",
+		DoUpdate: func(obj interfaces.DomainObject[string], stmt *PreparedStatement) error {
+			stmt.Append(obj.Id())
+			stmt.Append("b")
+			return nil
+		},
+	}
+	b := NewBatch()
+	err := d.Update(WithBatch(context.Background(), b), obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != 1 || !strings.HasPrefix(b.queued[0].stmt.query, "INSERT INTO outbox") {
+		t.Fatalf("expected only the outbox statement to be queued got %d statements", b.Len())
+	}
+	err = b.Send(context.Background(), &fakeBatcher{failAt: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(obj.PendingEvents()) != 0 {
+		t.Fatal("expected the events to be pulled once written")
+	}
+	err = d.Update(WithBatch(context.Background(), b), obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != 0 {
+		t.Fatalf("expected nothing to be queued without changes got %d statements", b.Len())
+	}
+}
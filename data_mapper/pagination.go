@@ -0,0 +1,192 @@
+package data_mapper
+
+import (
+	"clearly-not-a-secret-project/query_object"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Page requests Limit objects of the rows selected by Source sorted by Sort,
+// its last column must be unique, usually the id, for the order to be stable.
+// The page starts after the object the Cursor was computed from or, without
+// a cursor, after skipping Offset objects. The sort columns must be selected
+// by the source.
+type Page struct {
+	Source StatementSource
+	Limit  int
+	Cursor string
+	Offset int
+	Sort   []query_object.Order
+}
+
+// PageResult holds the objects of a page and the cursor of the
+// page that follows it, empty when the page is the last one.
+type PageResult[T any] struct {
+	Items []T
+	Next  string
+}
+
+// CursorEncoder writes the values of the sort columns of an object in an
+// opaque cursor and reads them back typed as the fields of their columns.
+type CursorEncoder[T any] interface {
+	Encode(obj T, columns []string) (string, error)
+	Decode(cursor string, columns []string) ([]any, error)
+}
+
+// EncodeCursor returns the url safe base64 of the json array of values.
+func EncodeCursor(values []any) (string, error) {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("error encoding the cursor %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor returns the json values of a cursor written by
+// EncodeCursor, it must hold a value for each of the n sort columns.
+func DecodeCursor(cursor string, n int) ([]json.RawMessage, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor %w", err)
+	}
+	var values []json.RawMessage
+	err = json.Unmarshal(data, &values)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor %w", err)
+	}
+	if len(values) != n {
+		return nil, fmt.Errorf("invalid cursor, it has %d values for %d sort columns", len(values), n)
+	}
+	return values, nil
+}
+
+// The columns of the page are written in its statement, they must be
+// among the sort columns of the mapper.
+func (p Page) valid(sortColumns []string) error {
+	if p.Source == nil {
+		return fmt.Errorf("the page has no statement source")
+	}
+	if p.Limit <= 0 {
+		return fmt.Errorf("the limit of the page must be positive")
+	}
+	if p.Offset < 0 {
+		return fmt.Errorf("the offset of the page can't be negative")
+	}
+	if len(p.Sort) == 0 {
+		return fmt.Errorf("the page must be sorted")
+	}
+	if p.Cursor != "" && p.Offset > 0 {
+		return fmt.Errorf("the page can't have both a cursor and an offset")
+	}
+	for _, v := range p.columns() {
+		if !slices.Contains(sortColumns, v) {
+			return fmt.Errorf("the column %s can't sort the page", v)
+		}
+	}
+	return nil
+}
+
+func (p Page) columns() []string {
+	columns := make([]string, 0, len(p.Sort))
+	for _, v := range p.Sort {
+		columns = append(columns, v.Column())
+	}
+	return columns
+}
+
+// Rows after the sort key, in the order of the page, are the ones greater
+// in a column sorted ascending or less in a descending one whose previous
+// columns equal the key.
+func (p Page) after(key []any) query_object.Criteria {
+	alternatives := make([]query_object.Criteria, 0, len(p.Sort))
+	for i, v := range p.Sort {
+		conditions := make([]query_object.Criteria, 0, i+1)
+		for j := range i {
+			conditions = append(conditions, query_object.Equals(p.Sort[j].Column(), key[j]))
+		}
+		if v.Descending() {
+			conditions = append(conditions, query_object.LessThan(v.Column(), key[i]))
+		} else {
+			conditions = append(conditions, query_object.GreaterThan(v.Column(), key[i]))
+		}
+		alternatives = append(alternatives, query_object.And(conditions...))
+	}
+	return query_object.Or(alternatives...)
+}
+
+// The statement selecting the rows of the page, after the sort key when it's
+// not nil, and one more row which tells whether there is a next page. The
+// statement of the source is a subquery so any source can be paginated.
+func (p Page) statement(key []any) StatementSource {
+	params := append(make([]interface{}, 0), p.Source.Parameters()...)
+	param := func(value any) string {
+		params = append(params, value)
+		return fmt.Sprintf("$%d", len(params))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "SELECT * FROM (%s) AS page", subquery(p.Source))
+	if key != nil {
+		fmt.Fprintf(&b, " WHERE %s", p.after(key).Sql(param))
+	}
+	orders := make([]string, 0, len(p.Sort))
+	for _, v := range p.Sort {
+		if v.Descending() {
+			orders = append(orders, fmt.Sprintf("%s DESC", v.Column()))
+		} else {
+			orders = append(orders, v.Column())
+		}
+	}
+	fmt.Fprintf(&b, " ORDER BY %s LIMIT %d", strings.Join(orders, ", "), p.Limit+1)
+	if p.Offset > 0 {
+		fmt.Fprintf(&b, " OFFSET %d", p.Offset)
+	}
+	b.WriteString(";")
+	return NewStatement(b.String(), params...)
+}
+
+// FindPage returns a page of the objects of the source, narrowed like the
+// query objects of FindMany, and the cursor of the next page computed from
+// the sort key of the last object by the Cursors of the mapper. The page
+// can only be sorted by the SortColumns of the mapper.
+func (d PostgreSQLDataMapper[T, K]) FindPage(ctx context.Context, page Page) (PageResult[T], error) {
+	var result PageResult[T]
+	err := page.valid(d.SortColumns)
+	if err != nil {
+		return result, err
+	}
+	var key []any
+	if page.Cursor != "" {
+		if d.Cursors == nil {
+			return result, fmt.Errorf("the type %v has no cursor encoder", d.DomainType)
+		}
+		key, err = d.Cursors.Decode(page.Cursor, page.columns())
+		if err != nil {
+			return result, err
+		}
+	}
+	page.Source, err = d.scope(ctx, page.Source)
+	if err != nil {
+		return result, err
+	}
+	objs, err := d.findMany(ctx, page.statement(key))
+	if err != nil {
+		return result, err
+	}
+	if len(objs) <= page.Limit {
+		result.Items = objs
+		return result, nil
+	}
+	result.Items = objs[:page.Limit]
+	if d.Cursors == nil {
+		return result, fmt.Errorf("the type %v has no cursor encoder", d.DomainType)
+	}
+	result.Next, err = d.Cursors.Encode(result.Items[page.Limit-1], page.columns())
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
@@ -0,0 +1,86 @@
+package data_mapper
+
+import (
+	"clearly-not-a-secret-project/query_object"
+	"reflect"
+	"testing"
+)
+
+func TestPage_statement(t *testing.T) {
+	source := query_object.New("aggregate", "id", "name").Where(query_object.Equals("name", "a"))
+	tests := map[string]struct {
+		page   Page
+		key    []any
+		sql    string
+		params []interface{}
+	}{
+		"offset": {
+			page:   Page{Source: source, Limit: 10, Offset: 20, Sort: []query_object.Order{query_object.Asc("id")}},
+			sql:    "SELECT * FROM (SELECT id, name FROM aggregate WHERE name = $1) AS page ORDER BY id LIMIT 11 OFFSET 20;",
+			params: []interface{}{"a"},
+		},
+		"keyset": {
+			page: Page{Source: source, Limit: 10, Sort: []query_object.Order{query_object.Desc("name"), query_object.Asc("id")}},
+			key:  []any{"b", int64(3)},
+			sql: "SELECT * FROM (SELECT id, name FROM aggregate WHERE name = $1) AS page " +
+				"WHERE ((name < $2) OR (name = $3 AND id > $4)) ORDER BY name DESC, id LIMIT 11;",
+			params: []interface{}{"a", "b", "b", int64(3)},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			stmt := tt.page.statement(tt.key)
+			if stmt.Sql() != tt.sql {
+				t.Fatalf("expected %s got %s", tt.sql, stmt.Sql())
+			}
+			if !reflect.DeepEqual(stmt.Parameters(), tt.params) {
+				t.Fatalf("expected %v got %v", tt.params, stmt.Parameters())
+			}
+		})
+	}
+	if source.Sql() != "SELECT id, name FROM aggregate WHERE name = $1;" {
+		t.Fatalf("the page modified its source %s", source.Sql())
+	}
+}
+
+func TestPage_valid(t *testing.T) {
+	source := query_object.New("aggregate", "id")
+	sort := []query_object.Order{query_object.Asc("id")}
+	invalid := map[string]Page{
+		"no source":         {Limit: 1, Sort: sort},
+		"no limit":          {Source: source, Sort: sort},
+		"unsorted":          {Source: source, Limit: 1},
+		"cursor and offset": {Source: source, Limit: 1, Sort: sort, Cursor: "x", Offset: 1},
+	}
+	columns := []string{"id", "name"}
+	invalid["injected column"] = Page{Source: source, Limit: 1, Sort: []query_object.Order{query_object.Asc("id; DROP TABLE aggregate; --")}}
+	invalid["unknown column"] = Page{Source: source, Limit: 1, Sort: []query_object.Order{query_object.Desc("email")}}
+	for name, page := range invalid {
+		if page.valid(columns) == nil {
+			t.Fatalf("expected the page %s to be invalid", name)
+		}
+	}
+	if err := (Page{Source: source, Limit: 1, Sort: sort}).valid(columns); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncodeCursor(t *testing.T) {
+	cursor, err := EncodeCursor([]any{"b", 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := DecodeCursor(cursor, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(values[0]) != `"b"` || string(values[1]) != "3" {
+		t.Fatalf("unexpected values %s", values)
+	}
+	if _, err = DecodeCursor(cursor, 1); err == nil {
+		t.Fatalf("expected an error decoding the cursor for other sort columns")
+	}
+	if _, err = DecodeCursor("not a cursor", 2); err == nil {
+		t.Fatalf("expected an error decoding an invalid cursor")
+	}
+}
@@ -0,0 +1,144 @@
+package data_mapper
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Projection is a read table kept in sync with the objects of a data mapper,
+// each object is written as the row with its id in the id column and the
+// columns returned by Project. The rows of the tenant scoped mappers also
+// have their tenant in the TenantColumn.
+type Projection[T any] struct {
+	Table   string
+	Project func(obj T) (map[string]any, error)
+}
+
+// The statements writing the projections of the object with the given id
+// after the operation, the rows of the removed objects are deleted and the
+// restored objects are read again including the deleted ones.
+func (d PostgreSQLDataMapper[T, K]) project(ctx context.Context, operation string, id K, object any) ([]*PreparedStatement, error) {
+	if len(d.Projections) == 0 {
+		return nil, nil
+	}
+	stmts := make([]*PreparedStatement, 0, len(d.Projections))
+	if operation == removeOperation {
+		for _, v := range d.Projections {
+			stmts = append(stmts, &PreparedStatement{
+				query: fmt.Sprintf("DELETE FROM %s WHERE id = $1;", v.Table),
+				args:  []interface{}{id},
+			})
+		}
+		return stmts, nil
+	}
+	obj, ok := object.(T)
+	if !ok {
+		var err error
+		obj, err = d.FindIncludingDeleted(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the object to project %w", err)
+		}
+	}
+	for _, v := range d.Projections {
+		stmt, err := d.projectionRow(ctx, v, obj)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+	}
+	return stmts, nil
+}
+
+// The upsert of the row of the object in the projection table.
+func (d PostgreSQLDataMapper[T, K]) projectionRow(ctx context.Context, projection Projection[T], obj T) (*PreparedStatement, error) {
+	row, err := projection.Project(obj)
+	if err != nil {
+		return nil, fmt.Errorf("error projecting into %s %w", projection.Table, err)
+	}
+	delete(row, "id")
+	if d.TenantColumn != "" {
+		tenant, err := d.tenant(ctx)
+		if err != nil {
+			return nil, err
+		}
+		row[d.TenantColumn] = tenant
+	}
+	columns := slices.Sorted(maps.Keys(row))
+	stmt := &PreparedStatement{
+		args: make([]interface{}, 0, len(columns)+1),
+	}
+	stmt.Append(obj.Id())
+	params := []string{"$1"}
+	sets := make([]string, 0, len(columns))
+	for _, column := range columns {
+		stmt.Append(row[column])
+		params = append(params, fmt.Sprintf("$%d", len(stmt.args)))
+		sets = append(sets, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+	}
+	action := "DO NOTHING"
+	if len(sets) > 0 {
+		action = "DO UPDATE SET " + strings.Join(sets, ", ")
+	}
+	stmt.query = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (id) %s;",
+		projection.Table, strings.Join(append([]string{"id"}, columns...), ", "), strings.Join(params, ", "), action)
+	return stmt, nil
+}
+
+// RebuildProjections writes again the projection rows of the objects of
+// source, usually the query object of all the objects of the mapper, in
+// one transaction. The row of each object is deleted before being written,
+// so the columns Project no longer returns are reset, and the rows of the
+// objects out of source are left as they are. The objects are streamed
+// detached from a connection of Db, outside the transaction of ctx, and
+// don't enter the identity map.
+func (d PostgreSQLDataMapper[T, K]) RebuildProjections(ctx context.Context, source StatementSource) error {
+	if len(d.Projections) == 0 {
+		return fmt.Errorf("the type %v has no projections", d.DomainType)
+	}
+	return d.inTx(ctx, func(txCtx context.Context) error {
+		for obj, err := range d.StreamDetached(withoutTx(ctx), source) {
+			if err != nil {
+				return err
+			}
+			for _, v := range d.Projections {
+				err = d.rebuildProjection(txCtx, v, obj)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Deletes the projection row of the object and writes it again.
+func (d PostgreSQLDataMapper[T, K]) rebuildProjection(ctx context.Context, projection Projection[T], obj T) error {
+	stmt := &PreparedStatement{
+		query: fmt.Sprintf("DELETE FROM %s WHERE id = $1", projection.Table),
+		args:  []interface{}{obj.Id()},
+	}
+	if d.TenantColumn != "" {
+		stmt.query += fmt.Sprintf(" AND %s = $2", d.TenantColumn)
+		err := d.appendTenant(ctx, stmt)
+		if err != nil {
+			return err
+		}
+	}
+	stmt.query += ";"
+	err := d.run(ctx, stmt, nil)
+	if err != nil {
+		return fmt.Errorf("error emptying the projection %s %w", projection.Table, err)
+	}
+	stmt, err = d.projectionRow(ctx, projection, obj)
+	if err != nil {
+		return err
+	}
+	err = d.run(ctx, stmt, nil)
+	if err != nil {
+		return fmt.Errorf("error writing the projection %s %w", projection.Table, err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package data_mapper
+
+import (
+	"clearly-not-a-secret-project/interfaces"
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestPostgreSQLDataMapper_projectionRow(t *testing.T) {
+	summary := Projection[interfaces.DomainObject[string]]{
+		Table: "aggregate_summary",
+		Project: func(obj interfaces.DomainObject[string]) (map[string]any, error) {
+			return map[string]any{"name": "b", "id": "ignored", "count": 2}, nil
+		},
+	}
+	tests := map[string]struct {
+		tenantColumn string
+		query        string
+		args         []interface{}
+	}{
+		"plain": {
+			query: "INSERT INTO aggregate_summary (id, count, name) VALUES ($1, $2, $3) " +
+				"ON CONFLICT (id) DO UPDATE SET count = EXCLUDED.count, name = EXCLUDED.name;",
+			args: []interface{}{"a", 2, "b"},
+		},
+		"tenant": {
+			tenantColumn: "tenant",
+			query: "INSERT INTO aggregate_summary (id, count, name, tenant) VALUES ($1, $2, $3, $4) " +
+				"ON CONFLICT (id) DO UPDATE SET count = EXCLUDED.count, name = EXCLUDED.name, tenant = EXCLUDED.tenant;",
+			args: []interface{}{"a", 2, "b", "acme"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			d := PostgreSQLDataMapper[interfaces.DomainObject[string], string]{TenantColumn: tt.tenantColumn}
+			stmt, err := d.projectionRow(WithTenant(context.Background(), "acme"), summary, &recordingObject{id: "a"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stmt.query != tt.query {
+				t.Fatalf("expected %s got %s", tt.query, stmt.query)
+			}
+			if !slices.Equal(stmt.args, tt.args) {
+				t.Fatalf("expected %v got %v", tt.args, stmt.args)
+			}
+		})
+	}
+}
+
+func TestPostgreSQLDataMapper_queueProjections(t *testing.T) {
+	obj := &recordingObject{id: "a"}
+	d := PostgreSQLDataMapper[interfaces.DomainObject[string], string]{
+		Table:           "aggregate",
+		UpdateStatement: "UPDATE aggregate SET name = $2 WHERE ID = $1",
+		RemoveStatement: "DELETE FROM aggregate WHERE ID = $1;",
+		LoadedMap:       map[string]interfaces.DomainObject[string]{"a": obj},
+		DoUpdate: func(obj interfaces.DomainObject[string], stmt *PreparedStatement) error {
+			stmt.Append(obj.Id())
+			stmt.Append("b")
+			return nil
+		},
+		Projections: []Projection[interfaces.DomainObject[string]]{{
+			Table: "aggregate_summary",
+			Project: func(obj interfaces.DomainObject[string]) (map[string]any, error) {
+				return map[string]any{"name": "b"}, nil
+			},
+		}},
+	}
+	b := NewBatch()
+	ctx := WithBatch(context.Background(), b)
+	err := d.Update(ctx, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.Remove(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"UPDATE aggregate SET name = $2 WHERE ID = $1",
+		"INSERT INTO aggregate_summary (id, name) VALUES ($1, $2) ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name;",
+		"DELETE FROM aggregate WHERE ID = $1;",
+		"DELETE FROM aggregate_summary WHERE id = $1;",
+	}
+	if b.Len() != len(expected) {
+		t.Fatalf("expected %d queued statements got %d", len(expected), b.Len())
+	}
+	for i, v := range b.queued {
+		if v.stmt.query != expected[i] {
+			t.Fatalf("expected statement %d to be %s got %s", i, expected[i], v.stmt.query)
+		}
+	}
+}
+
+func TestPostgreSQLDataMapper_rebuildProjection(t *testing.T) {
+	d := PostgreSQLDataMapper[interfaces.DomainObject[string], string]{
+		TenantColumn: "tenant",
+		Projections: []Projection[interfaces.DomainObject[string]]{{
+			Table: "aggregate_summary",
+			Project: func(obj interfaces.DomainObject[string]) (map[string]any, error) {
+				return map[string]any{"name": "b"}, nil
+			},
+		}},
+	}
+	tx := &fakeCopyTx{}
+	ctx := WithTx(WithTenant(context.Background(), "acme"), tx)
+	err := d.rebuildProjection(ctx, d.Projections[0], &recordingObject{id: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"DELETE FROM aggregate_summary WHERE id = $1 AND tenant = $2;",
+		"INSERT INTO aggregate_summary (id, name, tenant) VALUES ($1, $2, $3) " +
+			"ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, tenant = EXCLUDED.tenant;",
+	}
+	if !slices.Equal(tx.executed, expected) {
+		t.Fatalf("expected only the row of the object to be rebuilt %v got %v", expected, tx.executed)
+	}
+	if _, ok := TxFrom(withoutTx(ctx)); ok {
+		t.Fatal("expected the objects to be streamed outside the transaction")
+	}
+}
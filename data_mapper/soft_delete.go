@@ -0,0 +1,88 @@
+package data_mapper
+
+import (
+	"context"
+	"fmt"
+)
+
+// IncludingDeleted marks the source as selecting the soft deleted rows too,
+// the query objects are not narrowed to the rows not deleted. The mappers of
+// the soft deleted types only run the statements of other sources, that
+// can't be narrowed, once they are marked.
+func IncludingDeleted(source StatementSource) StatementSource {
+	return includingDeleted{source}
+}
+
+type includingDeleted struct {
+	StatementSource
+}
+
+// FindIncludingDeleted finds the object even if it was soft deleted. The
+// objects it loads don't enter the identity map, otherwise Find would return
+// them once deleted, and it never returns a ghost because loading a ghost
+// excludes the deleted rows.
+func (d PostgreSQLDataMapper[T, K]) FindIncludingDeleted(ctx context.Context, id K) (T, error) {
+	var nilT T
+	if d.FindIncludingDeletedStatement == "" {
+		return nilT, fmt.Errorf("soft delete is not enabled for type %v", d.DomainType)
+	}
+	loaded, err := d.identityMap(ctx)
+	if err != nil {
+		return nilT, err
+	}
+	obj, ok := loaded[id]
+	if ok && !obj.IsGhost() {
+		return obj, nil
+	}
+	stmt := &PreparedStatement{
+		conn:  d.executor(ctx),
+		query: d.FindIncludingDeletedStatement,
+		args:  make([]interface{}, 0),
+	}
+	stmt.Append(id)
+	err = d.appendTenant(ctx, stmt)
+	if err != nil {
+		return nilT, err
+	}
+	rows, err := stmt.ExecuteQuery(ctx)
+	if err != nil {
+		return nilT, fmt.Errorf("error at execute query %w", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if rows.Err() != nil {
+			return nilT, rows.Err()
+		}
+		return nilT, fmt.Errorf("%w: %v %v", ErrNotFound, d.DomainType, id)
+	}
+	if ok {
+		err = d.loadLine(rows, obj)
+		if err != nil {
+			return nilT, err
+		}
+		return obj, nil
+	}
+	result, err := d.DoLoad(rows)
+	if err != nil {
+		return nilT, fmt.Errorf("error at doLoad %w", err)
+	}
+	return result, nil
+}
+
+// Restore clears the soft delete mark of the row with the given id.
+func (d PostgreSQLDataMapper[T, K]) Restore(ctx context.Context, id K) error {
+	if d.RestoreStatement == "" {
+		return fmt.Errorf("soft delete is not enabled for type %v", d.DomainType)
+	}
+	stmt := &PreparedStatement{
+		conn:  d.executor(ctx),
+		query: d.RestoreStatement,
+		args:  make([]interface{}, 0),
+	}
+	stmt.Append(id)
+	err := d.appendTenant(ctx, stmt)
+	if err != nil {
+		return err
+	}
+	return d.execute(ctx, restoreOperation, func() K { return id }, stmt, id, nil, func() {})
+}
@@ -0,0 +1,54 @@
+package data_mapper
+
+import (
+	"clearly-not-a-secret-project/interfaces"
+	"clearly-not-a-secret-project/query_object"
+	"context"
+	"testing"
+)
+
+func TestPostgreSQLDataMapper_scope(t *testing.T) {
+	ctx := context.Background()
+	d := PostgreSQLDataMapper[interfaces.DomainObject[string], string]{SoftDeleteColumn: "deleted_at"}
+	q := query_object.New("aggregate", "id").Where(query_object.Equals("name", "a"))
+	scoped, err := d.scope(ctx, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scoped.Sql() != "SELECT id FROM aggregate WHERE name = $1 AND deleted_at IS NULL;" {
+		t.Fatalf("unexpected scoped statement %s", scoped.Sql())
+	}
+	if q.Sql() != "SELECT id FROM aggregate WHERE name = $1;" {
+		t.Fatalf("the scope modified the query %s", q.Sql())
+	}
+	scoped, err = d.scope(ctx, IncludingDeleted(q))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scoped.Sql() != q.Sql() {
+		t.Fatalf("expected the deleted rows to be included got %s", scoped.Sql())
+	}
+	raw := NewStatement("SELECT id FROM aggregate WHERE name = $1;", "a")
+	if _, err = d.scope(ctx, raw); err == nil {
+		t.Fatal("expected a statement that can't exclude the deleted rows to be refused")
+	}
+	scoped, err = d.scope(ctx, IncludingDeleted(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := scoped.(Statement); !ok || scoped.Sql() != raw.Sql() {
+		t.Fatalf("expected the statement marked including deleted to run as it is got %v", scoped)
+	}
+	invalid := query_object.New("aggregate", "id").Where(query_object.JSONContains("attributes", make(chan int)))
+	if _, err = d.scope(ctx, invalid); err == nil {
+		t.Fatal("expected a query that can't be written to be refused")
+	}
+	d.SoftDeleteColumn = ""
+	scoped, err = d.scope(ctx, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scoped.Sql() != q.Sql() {
+		t.Fatalf("expected the query unchanged got %s", scoped.Sql())
+	}
+}
@@ -0,0 +1,86 @@
+package data_mapper
+
+import (
+	"context"
+	"fmt"
+	"iter"
+)
+
+// Stream returns the objects of the source, narrowed like the query objects
+// of FindMany, reading one row at a time while the sequence is iterated.
+// The objects are looked up in and added to the identity map, the statements
+// of the transaction of ctx can't be run until the iteration ends.
+func (d PostgreSQLDataMapper[T, K]) Stream(ctx context.Context, source StatementSource) iter.Seq2[T, error] {
+	return d.stream(ctx, source, false)
+}
+
+// StreamDetached is Stream bypassing the identity map, a new object is built
+// for each row and none of them is kept by the mapper so memory stays flat
+// over any number of rows. The objects are not tracked, they must be found
+// again to be updated or removed.
+func (d PostgreSQLDataMapper[T, K]) StreamDetached(ctx context.Context, source StatementSource) iter.Seq2[T, error] {
+	return d.stream(ctx, source, true)
+}
+
+// FindEach calls fn with each object of the source as it's read,
+// it stops at the first error returned by fn and returns it.
+func (d PostgreSQLDataMapper[T, K]) FindEach(ctx context.Context, source StatementSource, fn func(T) error) error {
+	for obj, err := range d.Stream(ctx, source) {
+		if err != nil {
+			return err
+		}
+		err = fn(obj)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// An error ends the sequence, the rows are closed when the iteration stops.
+func (d PostgreSQLDataMapper[T, K]) stream(ctx context.Context, source StatementSource, detached bool) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var nilT T
+		source, err := d.scope(ctx, source)
+		if err != nil {
+			yield(nilT, err)
+			return
+		}
+		var loaded map[K]T
+		if !detached {
+			loaded, err = d.identityMap(ctx)
+			if err != nil {
+				yield(nilT, err)
+				return
+			}
+		}
+		rows, err := d.executor(ctx).Query(ctx, source.Sql(), source.Parameters()...)
+		if err != nil {
+			yield(nilT, err)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var obj T
+			if detached {
+				obj, err = d.DoLoad(rows)
+				if err != nil {
+					err = fmt.Errorf("error at doLoad %w", err)
+				}
+			} else {
+				obj, err = d.load(loaded, rows)
+			}
+			if err != nil {
+				yield(nilT, err)
+				return
+			}
+			if !yield(obj, nil) {
+				return
+			}
+		}
+		err = rows.Err()
+		if err != nil {
+			yield(nilT, err)
+		}
+	}
+}
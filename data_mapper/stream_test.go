@@ -0,0 +1,33 @@
+package data_mapper
+
+import (
+	"clearly-not-a-secret-project/interfaces"
+	"context"
+	"testing"
+)
+
+func TestPostgreSQLDataMapper_Stream(t *testing.T) {
+	d := PostgreSQLDataMapper[interfaces.DomainObject[string], string]{
+		TenantColumn:     "tenant_id",
+		TenantLoadedMaps: make(map[string]map[string]interfaces.DomainObject[string]),
+	}
+	ctx := WithTenant(context.Background(), "acme")
+	count := 0
+	for obj, err := range d.StreamDetached(ctx, rawSource("SELECT id FROM aggregate;")) {
+		count++
+		if err == nil || obj != nil {
+			t.Fatal("expected an error for a source that can't be scoped")
+		}
+	}
+	if count != 1 {
+		t.Fatalf("expected the sequence to end after the error got %d elements", count)
+	}
+	called := false
+	err := d.FindEach(ctx, rawSource("SELECT id FROM aggregate;"), func(interfaces.DomainObject[string]) error {
+		called = true
+		return nil
+	})
+	if err == nil || called {
+		t.Fatal("expected the error of the stream without calling fn")
+	}
+}
@@ -0,0 +1,112 @@
+package data_mapper
+
+import (
+	"clearly-not-a-secret-project/query_object"
+	"context"
+	"fmt"
+)
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of ctx carrying the tenant the statements
+// of the tenant scoped data mappers are restricted to.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFrom returns the tenant carried by ctx, if any.
+func TenantFrom(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok
+}
+
+func (d PostgreSQLDataMapper[T, K]) tenant(ctx context.Context) (string, error) {
+	tenant, ok := TenantFrom(ctx)
+	if !ok || tenant == "" {
+		return "", fmt.Errorf("the tenant scoped type %v requires a tenant in the context", d.DomainType)
+	}
+	return tenant, nil
+}
+
+// The identity map of the objects visible in ctx, the objects of the tenant
+// scoped mappers are kept in a map for each tenant.
+func (d PostgreSQLDataMapper[T, K]) identityMap(ctx context.Context) (map[K]T, error) {
+	if d.TenantColumn == "" {
+		return d.LoadedMap, nil
+	}
+	tenant, err := d.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	loaded, ok := d.TenantLoadedMaps[tenant]
+	if !ok {
+		loaded = make(map[K]T)
+		d.TenantLoadedMaps[tenant] = loaded
+	}
+	return loaded, nil
+}
+
+// Appends the tenant of ctx to the arguments of the statement, the
+// tenant condition is the last parameter of the mapper statements.
+func (d PostgreSQLDataMapper[T, K]) appendTenant(ctx context.Context, stmt *PreparedStatement) error {
+	if d.TenantColumn == "" {
+		return nil
+	}
+	tenant, err := d.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	stmt.Append(tenant)
+	return nil
+}
+
+// The context of the tenant whose identity map holds obj, ghosts are
+// loaded without a context so their tenant is found by their partition.
+func (d PostgreSQLDataMapper[T, K]) tenantContext(ctx context.Context, obj T) (context.Context, error) {
+	if d.TenantColumn == "" {
+		return ctx, nil
+	}
+	for tenant, loaded := range d.TenantLoadedMaps {
+		if v, ok := loaded[obj.Id()]; ok && any(v) == any(obj) {
+			return WithTenant(ctx, tenant), nil
+		}
+	}
+	return nil, fmt.Errorf("the object %v of the tenant scoped type %v is not in any identity map", obj.Id(), d.DomainType)
+}
+
+// Narrows the query objects to the rows visible in ctx. The statements of
+// other sources can't be narrowed, they are run as they are unless the
+// mapper is tenant scoped, or soft deletes its rows and the source isn't
+// marked IncludingDeleted.
+func (d PostgreSQLDataMapper[T, K]) scope(ctx context.Context, source StatementSource) (StatementSource, error) {
+	marked, withDeleted := source.(includingDeleted)
+	if withDeleted {
+		source = marked.StatementSource
+	}
+	q, ok := source.(*query_object.QueryObject)
+	if !ok {
+		if d.TenantColumn != "" {
+			return nil, fmt.Errorf("the tenant scoped type %v only finds many with query objects", d.DomainType)
+		}
+		if d.SoftDeleteColumn != "" && !withDeleted {
+			return nil, fmt.Errorf("the soft deleted type %v only finds many with query objects or sources marked IncludingDeleted", d.DomainType)
+		}
+		return source, nil
+	}
+	err := q.Err()
+	if err != nil {
+		return nil, err
+	}
+	q = q.Clone()
+	if d.SoftDeleteColumn != "" && !withDeleted {
+		q.Where(query_object.IsNull(d.SoftDeleteColumn))
+	}
+	if d.TenantColumn != "" {
+		tenant, err := d.tenant(ctx)
+		if err != nil {
+			return nil, err
+		}
+		q.Where(query_object.Equals(d.TenantColumn, tenant))
+	}
+	return q, nil
+}
@@ -0,0 +1,76 @@
+package data_mapper
+
+import (
+	"clearly-not-a-secret-project/interfaces"
+	"clearly-not-a-secret-project/query_object"
+	"context"
+	"reflect"
+	"testing"
+)
+
+type rawSource string
+
+func (s rawSource) Sql() string {
+	return string(s)
+}
+
+func (s rawSource) Parameters() []interface{} {
+	return nil
+}
+
+func TestPostgreSQLDataMapper_tenantScope(t *testing.T) {
+	d := PostgreSQLDataMapper[interfaces.DomainObject[string], string]{
+		TenantColumn:     "tenant_id",
+		TenantLoadedMaps: make(map[string]map[string]interfaces.DomainObject[string]),
+	}
+	q := query_object.New("aggregate", "id")
+	_, err := d.scope(context.Background(), q)
+	if err == nil {
+		t.Fatal("expected an error for a context without tenant")
+	}
+	ctx := WithTenant(context.Background(), "acme")
+	scoped, err := d.scope(ctx, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scoped.Sql() != "SELECT id FROM aggregate WHERE tenant_id = $1;" {
+		t.Fatalf("unexpected scoped statement %s", scoped.Sql())
+	}
+	if !reflect.DeepEqual(scoped.Parameters(), []interface{}{"acme"}) {
+		t.Fatalf("unexpected scoped parameters %v", scoped.Parameters())
+	}
+	_, err = d.scope(ctx, rawSource("SELECT id FROM aggregate;"))
+	if err == nil {
+		t.Fatal("expected an error for a source that can't be scoped")
+	}
+}
+
+func TestPostgreSQLDataMapper_identityMap(t *testing.T) {
+	d := PostgreSQLDataMapper[interfaces.DomainObject[string], string]{
+		TenantColumn:     "tenant_id",
+		TenantLoadedMaps: make(map[string]map[string]interfaces.DomainObject[string]),
+	}
+	_, err := d.identityMap(context.Background())
+	if err == nil {
+		t.Fatal("expected an error for a context without tenant")
+	}
+	acme, err := d.identityMap(WithTenant(context.Background(), "acme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	acme["a"] = nil
+	other, err := d.identityMap(WithTenant(context.Background(), "other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := other["a"]; ok {
+		t.Fatal("the identity map of a tenant holds the objects of another tenant")
+	}
+	again, err := d.identityMap(WithTenant(context.Background(), "acme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := again["a"]; !ok {
+		t.Fatal("the identity map of the tenant was not kept")
+	}
+}
@@ -0,0 +1,102 @@
+package data_mapper
+
+import (
+	"clearly-not-a-secret-project/interfaces"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type fakeReturningTx struct {
+	pgx.Tx
+	returned [][]any
+	queried  []string
+}
+
+func (f *fakeReturningTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	f.queried = append(f.queried, sql)
+	return &fakeReturnedRows{values: f.returned}, nil
+}
+
+type fakeReturnedRows struct {
+	pgx.Rows
+	values [][]any
+	next   int
+}
+
+func (f *fakeReturnedRows) Next() bool {
+	f.next++
+	return f.next <= len(f.values)
+}
+
+func (f *fakeReturnedRows) Scan(dest ...any) error {
+	for i, v := range f.values[f.next-1] {
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(v))
+	}
+	return nil
+}
+
+func (f *fakeReturnedRows) Close()     {}
+func (f *fakeReturnedRows) Err() error { return nil }
+
+func newUpsertMapper() PostgreSQLDataMapper[interfaces.DomainObject[int64], int64] {
+	return PostgreSQLDataMapper[interfaces.DomainObject[int64], int64]{
+		UpsertStatement: "INSERT INTO scored (id,name) VALUES ($1,$2) ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name RETURNING id;",
+		LoadedMap:       map[int64]interfaces.DomainObject[int64]{},
+		DoInsert: func(obj interfaces.DomainObject[int64], stmt *PreparedStatement) error {
+			stmt.Append(obj.Id())
+			stmt.Append(obj.(*scoredObject).name)
+			return nil
+		},
+		DoSetId: func(obj interfaces.DomainObject[int64], id int64) error {
+			obj.(*scoredObject).SetId(id)
+			return nil
+		},
+		DoUpsertReturning: func(resultSet pgx.Rows, obj interfaces.DomainObject[int64]) (int64, error) {
+			var id int64
+			err := resultSet.Scan(&id)
+			return id, err
+		},
+	}
+}
+
+func TestPostgreSQLDataMapper_Upsert(t *testing.T) {
+	d := newUpsertMapper()
+	obj := &scoredObject{id: 2, name: "a"}
+	ctx := WithTx(context.Background(), &fakeReturningTx{returned: [][]any{{int64(1)}}})
+	id, err := d.Upsert(ctx, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 1 || obj.Id() != 1 {
+		t.Fatalf("expected the id of the stored row got %d and the object %d", id, obj.Id())
+	}
+	if d.LoadedMap[1] != obj || len(d.LoadedMap) != 1 {
+		t.Fatalf("expected the object in the identity map under the stored id got %v", d.LoadedMap)
+	}
+}
+
+func TestPostgreSQLDataMapper_tenantUpsert(t *testing.T) {
+	d := newUpsertMapper()
+	d.DoUpsertReturning = nil
+	d.TenantColumn = "tenant_id"
+	d.TenantLoadedMaps = make(map[string]map[int64]interfaces.DomainObject[int64])
+	tx := &fakeReturningTx{}
+	ctx := WithTx(WithTenant(context.Background(), "acme"), tx)
+	obj := &scoredObject{id: 1, name: "a"}
+	if _, err := d.Upsert(ctx, obj); err == nil {
+		t.Fatal("expected an error upserting over the row of another tenant")
+	}
+	if len(d.TenantLoadedMaps["acme"]) != 0 {
+		t.Fatal("expected the object not to enter the identity map of the tenant")
+	}
+	tx.returned = [][]any{{int64(1)}}
+	if _, err := d.Upsert(ctx, obj); err != nil {
+		t.Fatal(err)
+	}
+	if d.TenantLoadedMaps["acme"][1] != obj {
+		t.Fatal("expected the upserted object in the identity map of the tenant")
+	}
+}
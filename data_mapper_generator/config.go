@@ -4,9 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"go/ast"
+	"go/constant"
+	"go/token"
 	"go/types"
 	"maps"
 	"os"
+	"path"
 	"path/filepath"
 	"slices"
 	"strings"
@@ -18,6 +21,7 @@ const (
 	AGGREGATE DomainObjectType = iota
 	ENTITY
 	VALUEOBJECT
+	EVENTSOURCED
 )
 
 func (t DomainObjectType) String() string {
@@ -28,6 +32,8 @@ func (t DomainObjectType) String() string {
 		return "entity"
 	case VALUEOBJECT:
 		return "valueObject"
+	case EVENTSOURCED:
+		return "eventSourced"
 	default:
 		return ""
 	}
@@ -41,41 +47,305 @@ func ParseDomainObjectType(v string) (DomainObjectType, error) {
 		return ENTITY, nil
 	case "valueObject":
 		return VALUEOBJECT, nil
+	case "eventSourced":
+		return EVENTSOURCED, nil
 	default:
 		return -1, fmt.Errorf("exhaustive check: domain object type is invalid")
 	}
 }
 
+type IdStrategy int
+
+const (
+	ASSIGNED IdStrategy = iota
+	DATABASE
+	UUIDV7
+	ULID
+	SEQUENCE
+)
+
+func (s IdStrategy) String() string {
+	switch s {
+	case ASSIGNED:
+		return "assigned"
+	case DATABASE:
+		return "database"
+	case UUIDV7:
+		return "uuidv7"
+	case ULID:
+		return "ulid"
+	case SEQUENCE:
+		return "sequence"
+	default:
+		return ""
+	}
+}
+
+// ParseIdStrategy parses the idStrategy of an object type, an empty value
+// defaults to assigned. For the sequence strategy the name of the sequence
+// follows the colon, as in sequence:aggregate_id_seq, and it is returned as
+// the second value.
+func ParseIdStrategy(v string) (IdStrategy, string, error) {
+	switch {
+	case v == "", v == "assigned":
+		return ASSIGNED, "", nil
+	case v == "database":
+		return DATABASE, "", nil
+	case v == "uuidv7":
+		return UUIDV7, "", nil
+	case v == "ulid":
+		return ULID, "", nil
+	case strings.HasPrefix(v, "sequence:"):
+		sequence := strings.TrimPrefix(v, "sequence:")
+		if sequence == "" {
+			return -1, "", fmt.Errorf("the sequence id strategy requires a sequence name")
+		}
+		return SEQUENCE, sequence, nil
+	default:
+		return -1, "", fmt.Errorf("exhaustive check: id strategy %s is invalid", v)
+	}
+}
+
 type FieldType struct {
-	Name   string `json:"name"`
-	Column string `json:"column"`
-	Update bool   `json:"update"`
+	Name      string `json:"name"`
+	Column    string `json:"column"`
+	Update    bool   `json:"update"`
+	Generated bool   `json:"generated"`
+	Nullable  bool   `json:"nullable"`
+	// ZeroIsNull writes the zero value of a field that can't hold a NULL
+	// as NULL, and reads NULL as the zero value, the field is nullable.
+	ZeroIsNull bool   `json:"zeroIsNull"`
+	Converter  string `json:"converter"`
+	JSON       bool   `json:"json"`
+	Enum       string `json:"enum"`
+	Reference  string `json:"reference"`
+	Aggregate  bool   `json:"aggregate"`
 }
 
 type ObjectType struct {
-	Name            string            `json:"name"`
-	Type            string            `json:"type"`
-	Table           string            `json:"table"`
-	Fields          []FieldType       `json:"fields"`
-	Pkg             string            `json:"pkg"`
-	Dir             string            `json:"dir"`
-	Builder         string            `json:"builder"`
-	Lazy            bool              `json:"lazy"`
-	ValidatedFields []*ValidatedField `json:"-"`
+	Name            string                `json:"name"`
+	Type            string                `json:"type"`
+	Table           string                `json:"table"`
+	Fields          []FieldType           `json:"fields"`
+	Pkg             string                `json:"pkg"`
+	Dir             string                `json:"dir"`
+	Builder         string                `json:"builder"`
+	Lazy            bool                  `json:"lazy"`
+	IdStrategy      string                `json:"idStrategy"`
+	ConflictColumns []string              `json:"conflictColumns"`
+	PartialUpdates  bool                  `json:"partialUpdates"`
+	SoftDelete      bool                  `json:"softDelete"`
+	TenantColumn    string                `json:"tenantColumn"`
+	Events          bool                  `json:"events"`
+	SnapshotEvery   int64                 `json:"snapshotEvery"`
+	Projections     []ProjectionType      `json:"projections"`
+	ManyToMany      []*ManyToManyType     `json:"manyToMany"`
+	Queries         map[string]*QueryType `json:"queries"`
+	ValidatedFields []*ValidatedField     `json:"-"`
+	kind            DomainObjectType
+	idStrategy      IdStrategy
+	sequence        string
+	// import paths of the fields data types declared outside the object package.
+	imports []string
+	enums   []*Enum
+	audited bool
+	history bool
+	// the methods applying the events of the event sourced objects.
+	appliers []*Applier
+}
+
+// ProjectionType is a read table written with the columns returned by
+// the function, declared in the package of the object, that maps the
+// object to its row, its signature is func(*Object) (map[string]any, error).
+type ProjectionType struct {
+	Table    string `json:"table"`
+	Function string `json:"function"`
 }
 
+// QueryType is a named query of the object, its statement selects the columns
+// the data mapper loads, the id first, and takes the Parameters in order as $1,
+// $2 and so on. A method named after the query, taking the parameters, is
+// generated on the data mapper. The queries are prepared against the db of
+// the config url at generation time.
+type QueryType struct {
+	Sql        string          `json:"sql"`
+	Parameters []ParameterType `json:"parameters"`
+}
+
+// ParameterType is a parameter of a named query, the Type of the packages
+// other than the builtin ones is qualified by their import path, as in
+// github.com/google/uuid.UUID, and can be prefixed by [] or *.
+type ParameterType struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// the type as written in the generated packages.
+	typeName string
+}
+
+type InheritanceStrategy int
+
+const (
+	SINGLETABLE InheritanceStrategy = iota
+	CLASSTABLE
+	CONCRETETABLE
+)
+
+func (s InheritanceStrategy) String() string {
+	switch s {
+	case SINGLETABLE:
+		return "singleTable"
+	case CLASSTABLE:
+		return "classTable"
+	case CONCRETETABLE:
+		return "concreteTable"
+	default:
+		return ""
+	}
+}
+
+func ParseInheritanceStrategy(v string) (InheritanceStrategy, error) {
+	switch v {
+	case "singleTable":
+		return SINGLETABLE, nil
+	case "classTable":
+		return CLASSTABLE, nil
+	case "concreteTable":
+		return CONCRETETABLE, nil
+	default:
+		return -1, fmt.Errorf("exhaustive check: inheritance strategy %s is invalid", v)
+	}
+}
+
+// HierarchyType maps the types implementing the interface Name to one data
+// mapper. Fields are the fields shared by all the subtypes, the id first,
+// and each subtype lists only its own fields. With singleTable all the
+// subtypes are rows of Table told apart by the Discriminator column, with
+// classTable the shared fields and the discriminator are written to Table
+// and the own fields to the table of each subtype, with concreteTable each
+// subtype table has all its fields and Table only names their union.
+type HierarchyType struct {
+	Name          string         `json:"name"`
+	Strategy      string         `json:"strategy"`
+	Table         string         `json:"table"`
+	Discriminator string         `json:"discriminator"`
+	Fields        []FieldType    `json:"fields"`
+	Pkg           string         `json:"pkg"`
+	Dir           string         `json:"dir"`
+	Subtypes      []*SubtypeType `json:"subtypes"`
+	strategy      InheritanceStrategy
+}
+
+// SubtypeType is an object type of a hierarchy, the rows with the
+// DiscriminatorValue in the discriminator column are built by it.
+type SubtypeType struct {
+	ObjectType
+	DiscriminatorValue string `json:"discriminatorValue"`
+	// the fields declared by the subtype, not shared with the hierarchy.
+	own []*ValidatedField
+}
+
+// ManyToManyType associates the object to the ids held by its field Name, of
+// type associations.Ids, through the rows of JoinTable. The owner column holds
+// the id of the object and the target column the associated id. Target is the
+// name of the lazy object type of the associated ids, when it's set the data
+// mapper also finds the associated objects as ghosts.
+type ManyToManyType struct {
+	Name         string `json:"name"`
+	JoinTable    string `json:"joinTable"`
+	OwnerColumn  string `json:"ownerColumn"`
+	TargetColumn string `json:"targetColumn"`
+	Target       string `json:"target"`
+	// the type of the associated ids as written in the generated
+	// packages and in the package of the object.
+	typeName      string
+	localTypeName string
+	target        *ObjectType
+}
+
+// Applier is an unexported method of an event sourced object named apply
+// followed by the name of the event type it takes as its only parameter.
+type Applier struct {
+	method string
+	// the event type as written in the generated packages
+	// and in the package of the object.
+	typeName      string
+	localTypeName string
+}
+
+// Enum is a PostgreSQL enum type mapped to the constants of a Go type,
+// each constant is written as the label at the same index.
+type Enum struct {
+	name      string
+	goName    string
+	typeName  string
+	pkgPath   string
+	constants []string
+	labels    []string
+}
+
+// the columns written by the audited inserts and updates.
+var (
+	auditInsertColumns = []string{"created_at", "created_by", "updated_at", "updated_by"}
+	auditUpdateColumns = []string{"updated_at", "updated_by"}
+)
+
+// the column marked by the remove statement of the soft deleted objects.
+const softDeleteColumn = "deleted_at"
+
+const dirtyColumnsField = "dirtyColumns"
+const dirtyColumnsType = "clearly-not-a-secret-project/dirty_tracking.DirtyColumns"
+
+// the table written with the events recorded by the objects, created by data_mapper.OutboxDDL.
+const outboxTable = "outbox"
+
+// the type of the fields holding the ids of the many to many associations.
+const associationType = "clearly-not-a-secret-project/associations.Ids"
+
+const eventsField = "events"
+const eventsType = "clearly-not-a-secret-project/domain_events.Events"
+
+// the tables of the event sourced objects, created by data_mapper.EventsDDL.
+const (
+	eventsTable    = "events"
+	snapshotsTable = "snapshots"
+)
+
+const versionField = "version"
+const versionType = "int64"
+
 type ValidatedField struct {
 	name       *string
 	dataType   *string
 	getterName *string
 	update     bool
 	setterName *string
+	column     string
+	generated  bool
+	nullable   bool
+	// the zero value of the field is written as NULL.
+	zeroIsNull bool
+	converter  string
+	json       bool
+	enum       string
+	// the name of the object type referenced by the id held by the field
+	// and the object type once resolved.
+	references string
+	reference  *ObjectType
+	// the sum, minimum and maximum of the column are generated.
+	aggregate bool
+	// the data type as written in the generated packages
+	// and in the package of the object.
+	typeName      string
+	localTypeName string
 }
 
 type DbConfig struct {
 	Pkg     string `json:"pkg"`
 	Dir     string `json:"dir"`
 	Builder string `json:"builder"`
+	// Url is the connection string of the db the named
+	// queries are prepared against at generation time.
+	Url string `json:"url"`
 }
 
 type PkgData struct {
@@ -84,12 +354,23 @@ type PkgData struct {
 	info  *types.Info
 }
 
+// AuditConfig enables the audit columns of every object, created_at,
+// created_by, updated_at and updated_by, with history the changes of
+// the objects are also recorded into the table history table.
+type AuditConfig struct {
+	History bool `json:"history"`
+}
+
 type Config struct {
-	Objects []*ObjectType `json:"objects"`
-	Db      *DbConfig     `json:"db"`
-	RootDir string        `json:"rootDir"`
-	RootPkg string        `json:"rootPkg"`
-	PkgData map[string]*PkgData
+	Objects     []*ObjectType    `json:"objects"`
+	Hierarchies []*HierarchyType `json:"hierarchies"`
+	Db          *DbConfig        `json:"db"`
+	RootDir     string           `json:"rootDir"`
+	RootPkg     string           `json:"rootPkg"`
+	Audit       *AuditConfig     `json:"audit"`
+	PkgData     map[string]*PkgData
+	// the enums of all the objects, each one declared once.
+	enums []*Enum
 }
 
 func (g *DataMapperGenerator) readConfig(caller string) error {
@@ -104,7 +385,14 @@ func (g *DataMapperGenerator) readConfig(caller string) error {
 	if err != nil {
 		return err
 	}
-	for _, v := range config.Objects {
+	// the subtypes are declared in the package of their hierarchy
+	for _, h := range config.Hierarchies {
+		for _, v := range h.Subtypes {
+			v.Pkg = h.Pkg
+			v.Dir = h.Dir
+		}
+	}
+	for _, v := range config.objectTypes() {
 		snake := matchFirstCap.ReplaceAllString(v.Name, "${1}_${2}")
 		snake = matchAllCap.ReplaceAllString(snake, "${1}_${2}")
 		objectfileName := strings.ToLower(snake)
@@ -125,6 +413,10 @@ func (g *DataMapperGenerator) readConfig(caller string) error {
 	if err != nil {
 		return err
 	}
+	err = config.prepareQueries()
+	if err != nil {
+		return err
+	}
 	g.config = config
 	return nil
 }
@@ -149,6 +441,51 @@ func (o *Config) valid(caller string) error {
 		if err != nil {
 			return err
 		}
+		if o.Audit != nil && v.kind != EVENTSOURCED {
+			v.audited = true
+			v.history = o.Audit.History
+			for _, field := range v.Fields {
+				if slices.Contains(auditInsertColumns, field.Column) {
+					return fmt.Errorf("the column %s of type %s is written by the audit", field.Column, v.Name)
+				}
+			}
+		}
+		err = o.addEnums(v)
+		if err != nil {
+			return err
+		}
+	}
+	for _, v := range o.Hierarchies {
+		pkg, ok := o.PkgData[v.Pkg]
+		if !ok {
+			return fmt.Errorf("the package %s is not present in PkgData", v.Pkg)
+		}
+		err = v.valid(pkg)
+		if err != nil {
+			return err
+		}
+		for _, subtype := range v.Subtypes {
+			err = o.addEnums(&subtype.ObjectType)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, v := range o.objectTypes() {
+		for _, field := range v.ValidatedFields {
+			err = field.validReference(v, o.Objects)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, v := range o.Objects {
+		for _, association := range v.ManyToMany {
+			err = association.validTarget(v, o.Objects)
+			if err != nil {
+				return err
+			}
+		}
 	}
 	if o.Db != nil {
 		pkg, ok := o.PkgData[o.Db.Pkg]
@@ -163,6 +500,23 @@ func (o *Config) valid(caller string) error {
 	return nil
 }
 
+// Adds the enums of the object to the enums of the config,
+// each enum must be mapped to the same type by all the objects.
+func (o *Config) addEnums(v *ObjectType) error {
+	for _, enum := range v.enums {
+		i := slices.IndexFunc(o.enums, func(e *Enum) bool { return e.name == enum.name })
+		if i < 0 {
+			o.enums = append(o.enums, enum)
+			continue
+		}
+		if o.enums[i].typeName != enum.typeName {
+			return fmt.Errorf("the enum %s is mapped to the types %s and %s",
+				enum.name, o.enums[i].typeName, enum.typeName)
+		}
+	}
+	return nil
+}
+
 // Checks if the dir exists.
 // Parses the dir into an ast.
 // Inspect the ast and determine if the pkg and builder method exists.
@@ -239,7 +593,12 @@ func (o *ObjectType) valid(pkgData *PkgData) error {
 	if o.Type == "" {
 		return fmt.Errorf("the domain object type is required")
 	}
-	_, err := ParseDomainObjectType(o.Type)
+	var err error
+	o.kind, err = ParseDomainObjectType(o.Type)
+	if err != nil {
+		return err
+	}
+	o.idStrategy, o.sequence, err = ParseIdStrategy(o.IdStrategy)
 	if err != nil {
 		return err
 	}
@@ -265,9 +624,32 @@ func (o *ObjectType) valid(pkgData *PkgData) error {
 
 	expectedFields := make(map[string]*ValidatedField, 0)
 	for _, v := range o.Fields {
-		expectedFields[v.Name] = &ValidatedField{update: v.Update}
+		expectedFields[v.Name] = &ValidatedField{
+			update:     v.Update,
+			column:     v.Column,
+			generated:  v.Generated,
+			nullable:   v.Nullable || v.ZeroIsNull,
+			zeroIsNull: v.ZeroIsNull,
+			converter:  v.Converter,
+			json:       v.JSON,
+			enum:       v.Enum,
+			references: v.Reference,
+			aggregate:  v.Aggregate,
+		}
+		if v.JSON && v.Converter != "" {
+			return fmt.Errorf("the field %s can't be json and have the converter %s", v.Name, v.Converter)
+		}
+		if v.Enum != "" && (v.JSON || v.Converter != "") {
+			return fmt.Errorf("the field %s mapped to the enum %s can't be json or have a converter", v.Name, v.Enum)
+		}
+		if v.ZeroIsNull && (v.JSON || v.Converter != "" || v.Enum != "") {
+			return fmt.Errorf("the field %s writing its zero value as NULL can't be json, an enum or have a converter", v.Name)
+		}
 	}
 
+	hasDirtyColumns := false
+	hasEvents := false
+	hasVersion := false
 	if ctype, ok := obj.Type().Underlying().(*types.Struct); ok {
 		for i := range ctype.NumFields() {
 			v := ctype.Field(i)
@@ -276,9 +658,76 @@ func (o *ObjectType) valid(pkgData *PkgData) error {
 				dataType := v.Type().String()
 				efield.name = &name
 				efield.dataType = &dataType
+				efield.typeName = types.TypeString(v.Type(), (*types.Package).Name)
+				efield.localTypeName = types.TypeString(v.Type(), types.RelativeTo(pkgData.pkg))
+				for _, path := range typeImports(v.Type()) {
+					if path != pkgData.pkg.Path() && !slices.Contains(o.imports, path) {
+						o.imports = append(o.imports, path)
+					}
+				}
+				if efield.enum != "" {
+					enum, err := enumOf(efield.enum, v.Type())
+					if err != nil {
+						return fmt.Errorf("the field %s of type %s: %w", name, o.Name, err)
+					}
+					// the values are written and scanned by the enum converter
+					efield.converter = enum.name
+					o.enums = append(o.enums, enum)
+				}
+				err = efield.validNullable()
+				if err != nil {
+					return fmt.Errorf("the field %s of type %s: %w", name, o.Name, err)
+				}
+			}
+			if v.Name() == dirtyColumnsField && v.Type().String() == dirtyColumnsType {
+				hasDirtyColumns = true
+			}
+			if v.Name() == eventsField && v.Type().String() == eventsType {
+				hasEvents = true
+			}
+			if v.Name() == versionField && v.Type().String() == versionType {
+				hasVersion = true
+			}
+			for _, association := range o.ManyToMany {
+				if v.Name() == association.Name {
+					err = association.validField(o, v.Type(), pkgData.pkg)
+					if err != nil {
+						return err
+					}
+				}
 			}
 		}
 	}
+	for _, v := range o.ManyToMany {
+		if v.typeName == "" {
+			return fmt.Errorf("the type %s requires a field %s of type %s for its many to many association",
+				o.Name, v.Name, associationType)
+		}
+	}
+	if o.PartialUpdates && !hasDirtyColumns {
+		return fmt.Errorf("the type %s has partial updates and requires a field %s of type %s",
+			o.Name, dirtyColumnsField, dirtyColumnsType)
+	}
+	if o.recordsEvents() && !hasEvents {
+		return fmt.Errorf("the type %s records events and requires a field %s of type %s",
+			o.Name, eventsField, eventsType)
+	}
+	for _, v := range o.Projections {
+		err = v.valid(o, obj, pkgData.pkg)
+		if err != nil {
+			return err
+		}
+	}
+	if o.kind == EVENTSOURCED {
+		if !hasVersion {
+			return fmt.Errorf("the event sourced type %s requires a field %s of type %s",
+				o.Name, versionField, versionType)
+		}
+		err = o.validEventSourced(obj, pkgData.pkg)
+		if err != nil {
+			return err
+		}
+	}
 
 	mset := types.NewMethodSet(obj.Type())
 	checkReturn := func(tuple *types.Tuple, expected *ValidatedField) bool {
@@ -301,7 +750,10 @@ func (o *ObjectType) valid(pkgData *PkgData) error {
 		}
 	}
 
-	expectedParams := slices.Collect(maps.Values(expectedFields))
+	expectedParams := make([]*ValidatedField, 0, len(o.Fields))
+	for _, v := range o.Fields {
+		expectedParams = append(expectedParams, expectedFields[v.Name])
+	}
 
 	checkBuilderSig := func(sig *types.Signature) bool {
 		params := sig.Params()
@@ -311,7 +763,7 @@ func (o *ObjectType) valid(pkgData *PkgData) error {
 		for i := range params.Len() {
 			param := params.At(i)
 			expectedParam := expectedParams[i]
-			if param.Type().String() != *expectedParam.dataType {
+			if expectedParam.dataType == nil || param.Type().String() != *expectedParam.dataType {
 				return false
 			}
 		}
@@ -392,6 +844,15 @@ func (o *ObjectType) valid(pkgData *PkgData) error {
 					k, o.Name)
 			}
 		}
+		if k == "id" && o.idStrategy != ASSIGNED && v.setterName != nil {
+			if err != nil {
+				err = fmt.Errorf("%w\nthe id of type %s is not assigned by the domain and already have a public set method",
+					err, o.Name)
+			} else {
+				err = fmt.Errorf("\nthe id of type %s is not assigned by the domain and already have a public set method",
+					o.Name)
+			}
+		}
 		if v.update && v.setterName != nil {
 			if err != nil {
 				err = fmt.Errorf("%w\nthe field %s has an update flag and already have a public set method for type %s",
@@ -412,6 +873,624 @@ func (o *ObjectType) valid(pkgData *PkgData) error {
 			o.Builder, o.Name, o.Pkg)
 	}
 
-	o.ValidatedFields = slices.Collect(maps.Values(expectedFields))
+	o.ValidatedFields = expectedParams
+	idField, ok := expectedFields["id"]
+	if !ok {
+		return fmt.Errorf("the id field is required for type %s", o.Name)
+	}
+	err = o.validIdStrategy(idField)
+	if err != nil {
+		return err
+	}
+	if o.TenantColumn != "" && slices.ContainsFunc(o.Fields, func(v FieldType) bool {
+		return v.Column == o.TenantColumn
+	}) {
+		return fmt.Errorf("the tenant column %s of type %s is written from the context and can't be a field column",
+			o.TenantColumn, o.Name)
+	}
+	for _, column := range o.ConflictColumns {
+		if !slices.ContainsFunc(o.insertFields(), func(v *ValidatedField) bool {
+			return v.column == column
+		}) {
+			return fmt.Errorf("the conflict column %s of type %s must be a column written by the insert",
+				column, o.Name)
+		}
+	}
+	if o.upsertReturnsId() && idField.setterName != nil {
+		return fmt.Errorf("the id of type %s is set from the row matching its conflict columns and already have a public set method",
+			o.Name)
+	}
+	for _, name := range slices.Sorted(maps.Keys(o.Queries)) {
+		err = o.Queries[name].valid(name, o)
+		if err != nil {
+			return err
+		}
+	}
+	for _, v := range o.ValidatedFields {
+		if !v.aggregate {
+			continue
+		}
+		if o.kind == EVENTSOURCED {
+			return fmt.Errorf("the event sourced type %s can't aggregate the field %s, its objects are not stored in rows",
+				o.Name, *v.name)
+		}
+		if !slices.Contains(o.sortFields(), v) {
+			return fmt.Errorf("the field %s of type %s can't be aggregated, it's nullable, json or converted",
+				*v.name, o.Name)
+		}
+	}
+
 	return nil
 }
+
+// The objects and the subtypes of the hierarchies.
+func (c *Config) objectTypes() []*ObjectType {
+	objects := slices.Clone(c.Objects)
+	for _, h := range c.Hierarchies {
+		for _, v := range h.Subtypes {
+			objects = append(objects, &v.ObjectType)
+		}
+	}
+	return objects
+}
+
+// The subtypes inherit the package and the fields of the hierarchy, each one
+// is validated as an object type built from the shared fields followed by its
+// own. Their statements write all the columns of a row at once so the
+// hierarchies can't have partial updates, soft delete, tenants, conflict
+// columns, generated values or events. The subtypes must be lazy to
+// implement interfaces.DomainObject.
+func (h *HierarchyType) valid(pkgData *PkgData) error {
+	if h.Name == "" {
+		return fmt.Errorf("the hierarchy name is required")
+	}
+	var err error
+	h.strategy, err = ParseInheritanceStrategy(h.Strategy)
+	if err != nil {
+		return err
+	}
+	switch {
+	case h.Table == "":
+		return fmt.Errorf("the table of the hierarchy %s is required", h.Name)
+	case h.Discriminator == "":
+		return fmt.Errorf("the discriminator of the hierarchy %s is required", h.Name)
+	case len(h.Fields) == 0 || h.Fields[0].Name != "id":
+		return fmt.Errorf("the fields of the hierarchy %s are required, the id first", h.Name)
+	case len(h.Subtypes) == 0:
+		return fmt.Errorf("the hierarchy %s has no subtypes", h.Name)
+	}
+	obj, ok := pkgData.pkg.Scope().Lookup(h.Name).(*types.TypeName)
+	if !ok || !types.IsInterface(obj.Type()) {
+		return fmt.Errorf("could not find the interface %s in %s", h.Name, pkgData.pkg.Path())
+	}
+	names := make([]string, 0)
+	columns := make([]string, 0)
+	values := make([]string, 0, len(h.Subtypes))
+	for _, v := range h.Fields {
+		names = append(names, v.Name)
+		columns = append(columns, v.Column)
+	}
+	for _, v := range h.Subtypes {
+		if v.DiscriminatorValue == "" || slices.Contains(values, v.DiscriminatorValue) {
+			return fmt.Errorf("the subtype %s of the hierarchy %s requires a unique discriminator value", v.Name, h.Name)
+		}
+		values = append(values, v.DiscriminatorValue)
+		if v.Type == "" {
+			v.Type = AGGREGATE.String()
+		}
+		if h.strategy == SINGLETABLE {
+			v.Table = h.Table
+		}
+		if v.Table == h.Table && h.strategy != SINGLETABLE {
+			return fmt.Errorf("the subtype %s of the hierarchy %s requires its own table", v.Name, h.Name)
+		}
+		own := len(v.Fields)
+		v.Fields = append(slices.Clone(h.Fields), v.Fields...)
+		for _, field := range v.Fields[len(h.Fields):] {
+			if slices.Contains(names, field.Name) || slices.Contains(columns, field.Column) {
+				return fmt.Errorf("the field %s of the subtype %s is repeated in the hierarchy %s", field.Name, v.Name, h.Name)
+			}
+			names = append(names, field.Name)
+			columns = append(columns, field.Column)
+		}
+		for _, field := range v.Fields {
+			if field.Name == discriminatorVar || field.Column == h.Discriminator {
+				return fmt.Errorf("the field %s of the subtype %s is the discriminator of the hierarchy %s", field.Name, v.Name, h.Name)
+			}
+		}
+		err = v.ObjectType.valid(pkgData)
+		if err != nil {
+			return err
+		}
+		switch {
+		case v.kind == EVENTSOURCED, v.recordsEvents(), len(v.Projections) > 0, len(v.ManyToMany) > 0:
+			return fmt.Errorf("the subtype %s of the hierarchy %s can't record events, have projections or associations", v.Name, h.Name)
+		case v.PartialUpdates, v.SoftDelete, v.TenantColumn != "", len(v.ConflictColumns) > 0:
+			return fmt.Errorf("the subtype %s of the hierarchy %s can't have partial updates, soft delete, tenant or conflict columns", v.Name, h.Name)
+		case v.idStrategy != ASSIGNED || len(v.returningFields()) > 0:
+			return fmt.Errorf("the subtype %s of the hierarchy %s can't have values generated by the database", v.Name, h.Name)
+		case !v.Lazy:
+			return fmt.Errorf("the subtype %s of the hierarchy %s must be lazy", v.Name, h.Name)
+		}
+		v.own = v.ValidatedFields[len(v.ValidatedFields)-own:]
+	}
+	return nil
+}
+
+// the names of the variables of the generated query methods.
+var queryVariables = []string{"ctx", "d", "objs", "err", "result", "v", "subject"}
+
+func (q *QueryType) valid(name string, o *ObjectType) error {
+	method := matchFirstCh.ReplaceAllStringFunc(name, strings.ToUpper)
+	switch {
+	case !token.IsIdentifier(name):
+		return fmt.Errorf("the query name %s of type %s is not a valid identifier", name, o.Name)
+	case q.Sql == "":
+		return fmt.Errorf("the query %s of type %s has no statement", name, o.Name)
+	case o.kind == EVENTSOURCED:
+		return fmt.Errorf("the event sourced type %s can't declare the query %s, its objects are found by id", o.Name, name)
+	case o.TenantColumn != "":
+		return fmt.Errorf("the tenant scoped type %s can't declare the query %s, its statements must be narrowed to the tenant", o.Name, name)
+	case slices.Contains(mapperMethods, method):
+		return fmt.Errorf("the query %s of type %s is named as the data mapper method %s", name, o.Name, method)
+	}
+	names := make([]string, 0, len(q.Parameters))
+	for i := range q.Parameters {
+		v := &q.Parameters[i]
+		if !token.IsIdentifier(v.Name) || slices.Contains(queryVariables, v.Name) || slices.Contains(names, v.Name) {
+			return fmt.Errorf("the parameter %s of the query %s of type %s must be a unique identifier other than %s",
+				v.Name, name, o.Name, strings.Join(queryVariables, ", "))
+		}
+		names = append(names, v.Name)
+		typeName, path, err := parameterType(v.Type)
+		if err != nil {
+			return fmt.Errorf("the parameter %s of the query %s of type %s: %w", v.Name, name, o.Name, err)
+		}
+		v.typeName = typeName
+		if path != "" && !slices.Contains(o.imports, path) {
+			o.imports = append(o.imports, path)
+		}
+	}
+	return nil
+}
+
+// Returns the type name of the parameter as written in the
+// generated packages and the import path of its package.
+func parameterType(t string) (string, string, error) {
+	prefix := ""
+	for {
+		if strings.HasPrefix(t, "[]") {
+			prefix += "[]"
+			t = t[2:]
+		} else if strings.HasPrefix(t, "*") {
+			prefix += "*"
+			t = t[1:]
+		} else {
+			break
+		}
+	}
+	dot := strings.LastIndex(t, ".")
+	if dot < 0 {
+		if _, ok := types.Universe.Lookup(t).(*types.TypeName); !ok {
+			return "", "", fmt.Errorf("the type %s is not a builtin type nor qualified by its import path", t)
+		}
+		return prefix + t, "", nil
+	}
+	pkgPath, name := t[:dot], t[dot+1:]
+	if pkgPath == "" || !token.IsExported(name) {
+		return "", "", fmt.Errorf("the type %s is not an exported type qualified by its import path", t)
+	}
+	return fmt.Sprintf("%s%s.%s", prefix, path.Base(pkgPath), name), pkgPath, nil
+}
+
+// The field of the association holds the associated ids in an associations.Ids,
+// it's not mapped to a column and its accessor is generated.
+func (m *ManyToManyType) validField(o *ObjectType, t types.Type, pkg *types.Package) error {
+	switch {
+	case m.Name == "" || m.JoinTable == "" || m.OwnerColumn == "" || m.TargetColumn == "":
+		return fmt.Errorf("the many to many associations of type %s require a name, a join table, an owner and a target column", o.Name)
+	case o.kind == EVENTSOURCED:
+		return fmt.Errorf("the event sourced type %s can't have many to many associations", o.Name)
+	case slices.ContainsFunc(o.Fields, func(v FieldType) bool { return v.Name == m.Name }):
+		return fmt.Errorf("the many to many association %s of type %s can't be a field mapped to a column", m.Name, o.Name)
+	}
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || named.TypeArgs().Len() != 1 ||
+		fmt.Sprintf("%s.%s", named.Obj().Pkg().Path(), named.Obj().Name()) != associationType {
+		return fmt.Errorf("the field %s of type %s must be of type %s", m.Name, o.Name, associationType)
+	}
+	id := named.TypeArgs().At(0)
+	m.typeName = types.TypeString(id, (*types.Package).Name)
+	m.localTypeName = types.TypeString(id, types.RelativeTo(pkg))
+	for _, path := range typeImports(id) {
+		if path != pkg.Path() && !slices.Contains(o.imports, path) {
+			o.imports = append(o.imports, path)
+		}
+	}
+	return nil
+}
+
+// A reference holds the id of another object, its field is named after the
+// reference followed by Id and the getter named after the reference returns
+// the ghost of the referenced object, which must be lazy.
+func (v *ValidatedField) validReference(o *ObjectType, objects []*ObjectType) error {
+	if v.references == "" {
+		return nil
+	}
+	switch {
+	case !strings.HasSuffix(*v.name, "Id") || *v.name == "Id":
+		return fmt.Errorf("the reference field %s of type %s must be named after the reference followed by Id", *v.name, o.Name)
+	case v.converter != "" || v.json:
+		return fmt.Errorf("the reference field %s of type %s can't be json or have a converter", *v.name, o.Name)
+	case slices.ContainsFunc(o.Fields, func(f FieldType) bool { return f.Name == v.referenceName() }):
+		return fmt.Errorf("the reference field %s of type %s conflicts with the field %s", *v.name, o.Name, v.referenceName())
+	}
+	i := slices.IndexFunc(objects, func(target *ObjectType) bool { return target.Name == v.references })
+	if i < 0 {
+		return fmt.Errorf("could not find the type %s referenced by the field %s of type %s", v.references, *v.name, o.Name)
+	}
+	target := objects[i]
+	if !target.Lazy {
+		return fmt.Errorf("the type %s referenced by the field %s of type %s must be lazy", target.Name, *v.name, o.Name)
+	}
+	for _, id := range target.ValidatedFields {
+		if *id.name == "id" && *id.dataType != *v.dataType {
+			return fmt.Errorf("the reference field %s of type %s is a %s and the ids of %s are %s",
+				*v.name, o.Name, *v.dataType, target.Name, *id.dataType)
+		}
+	}
+	v.reference = target
+	return nil
+}
+
+// The name of the reference, the name of its field without the Id suffix.
+func (v *ValidatedField) referenceName() string {
+	return strings.TrimSuffix(*v.name, "Id")
+}
+
+// The target of the association must be a lazy object with ids of the associated type.
+func (m *ManyToManyType) validTarget(o *ObjectType, objects []*ObjectType) error {
+	if m.Target == "" {
+		return nil
+	}
+	i := slices.IndexFunc(objects, func(v *ObjectType) bool { return v.Name == m.Target })
+	if i < 0 {
+		return fmt.Errorf("could not find the target %s of the association %s of type %s", m.Target, m.Name, o.Name)
+	}
+	target := objects[i]
+	if !target.Lazy {
+		return fmt.Errorf("the target %s of the association %s of type %s must be lazy", m.Target, m.Name, o.Name)
+	}
+	for _, v := range target.ValidatedFields {
+		if *v.name == "id" && v.typeName != m.typeName {
+			return fmt.Errorf("the association %s of type %s holds ids of type %s and the ids of %s are %s",
+				m.Name, o.Name, m.typeName, m.Target, v.typeName)
+		}
+	}
+	m.target = target
+	return nil
+}
+
+func (p ProjectionType) valid(o *ObjectType, obj types.Object, pkg *types.Package) error {
+	if p.Table == "" || p.Function == "" {
+		return fmt.Errorf("the projections of type %s require a table and a function", o.Name)
+	}
+	if o.kind == EVENTSOURCED {
+		return fmt.Errorf("the event sourced type %s can't have projections", o.Name)
+	}
+	fn, ok := pkg.Scope().Lookup(p.Function).(*types.Func)
+	if !ok {
+		return fmt.Errorf("could not find the projection function %s in %s", p.Function, pkg.Path())
+	}
+	sig := fn.Type().(*types.Signature)
+	isRow := func(t types.Type) bool {
+		m, ok := t.(*types.Map)
+		if !ok {
+			return false
+		}
+		key, ok := m.Key().(*types.Basic)
+		elem, isInterface := m.Elem().Underlying().(*types.Interface)
+		return ok && key.Kind() == types.String && isInterface && elem.Empty()
+	}
+	if sig.Params().Len() != 1 || !types.Identical(sig.Params().At(0).Type(), types.NewPointer(obj.Type())) ||
+		sig.Results().Len() != 2 || !isRow(sig.Results().At(0).Type()) ||
+		!types.Identical(sig.Results().At(1).Type(), types.Universe.Lookup("error").Type()) {
+		return fmt.Errorf("the projection function %s must have the signature func(*%s) (map[string]any, error)",
+			p.Function, o.Name)
+	}
+	return nil
+}
+
+func (o *ObjectType) references() bool {
+	return slices.ContainsFunc(o.ValidatedFields, func(v *ValidatedField) bool {
+		return v.reference != nil
+	})
+}
+
+func (o *ObjectType) recordsEvents() bool {
+	return o.Events || o.kind == EVENTSOURCED
+}
+
+// The event sourced objects are stored as the events they record, they are
+// lazy to be rebuilt from ghosts and their ids are assigned by the domain.
+// Their appliers are read from the unexported methods of the object
+// named apply followed by the name of the event type they take, the
+// event type must implement domain_events.Event with a value receiver.
+func (o *ObjectType) validEventSourced(obj types.Object, pkg *types.Package) error {
+	switch {
+	case !o.Lazy:
+		return fmt.Errorf("the event sourced type %s must be lazy", o.Name)
+	case o.idStrategy != ASSIGNED:
+		return fmt.Errorf("the id of the event sourced type %s must be assigned", o.Name)
+	case o.PartialUpdates, o.SoftDelete, o.TenantColumn != "", len(o.ConflictColumns) > 0:
+		return fmt.Errorf("the event sourced type %s can't have partial updates, soft delete, tenant or conflict columns", o.Name)
+	}
+	for _, v := range o.Fields {
+		if v.Name == versionField {
+			return fmt.Errorf("the field %s of the event sourced type %s is written by its data mapper", versionField, o.Name)
+		}
+	}
+	mset := types.NewMethodSet(types.NewPointer(obj.Type()))
+	for i := range mset.Len() {
+		meth := mset.At(i).Obj()
+		name := meth.Name()
+		if !strings.HasPrefix(name, "apply") || len(name) == len("apply") {
+			continue
+		}
+		sig := meth.Type().(*types.Signature)
+		if sig.Params().Len() != 1 || sig.Results().Len() != 0 {
+			return fmt.Errorf("the applier %s of type %s must take one event and return nothing", name, o.Name)
+		}
+		event := sig.Params().At(0).Type()
+		eventName, _, _ := types.LookupFieldOrMethod(event, false, pkg, "EventName")
+		_, isMethod := eventName.(*types.Func)
+		if _, ok := event.(*types.Named); !ok || !isMethod {
+			return fmt.Errorf("the applier %s of type %s takes %s that doesn't implement domain_events.Event",
+				name, o.Name, event.String())
+		}
+		for _, path := range typeImports(event) {
+			if path != pkg.Path() && !slices.Contains(o.imports, path) {
+				o.imports = append(o.imports, path)
+			}
+		}
+		o.appliers = append(o.appliers, &Applier{
+			method:        name,
+			typeName:      types.TypeString(event, (*types.Package).Name),
+			localTypeName: types.TypeString(event, types.RelativeTo(pkg)),
+		})
+	}
+	if len(o.appliers) == 0 {
+		return fmt.Errorf("the event sourced type %s has no appliers", o.Name)
+	}
+	return nil
+}
+
+// Reads the enum name from the constants of t declared in its package,
+// t must be a named string or integer type. String constants are labeled
+// by their value and integer constants by their snake cased name.
+func enumOf(name string, t types.Type) (*Enum, error) {
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return nil, fmt.Errorf("the enum %s must be mapped to a named type, found %s", name, t.String())
+	}
+	basic, ok := named.Underlying().(*types.Basic)
+	if !ok || basic.Info()&(types.IsString|types.IsInteger) == 0 {
+		return nil, fmt.Errorf("the enum %s must be mapped to a string or integer type, found %s", name, t.String())
+	}
+	pkg := named.Obj().Pkg()
+	enum := &Enum{
+		name:     name,
+		goName:   named.Obj().Name(),
+		typeName: types.TypeString(t, (*types.Package).Name),
+		pkgPath:  pkg.Path(),
+	}
+	scope := pkg.Scope()
+	consts := make([]*types.Const, 0)
+	for _, v := range scope.Names() {
+		c, ok := scope.Lookup(v).(*types.Const)
+		if ok && c.Exported() && types.Identical(c.Type(), t) {
+			consts = append(consts, c)
+		}
+	}
+	// the order of the labels is the order of declaration
+	slices.SortFunc(consts, func(a, b *types.Const) int { return int(a.Pos() - b.Pos()) })
+	for _, c := range consts {
+		v := c.Name()
+		label := v
+		if basic.Info()&types.IsString != 0 {
+			label = constant.StringVal(c.Val())
+		} else {
+			label = matchFirstCap.ReplaceAllString(label, "${1}_${2}")
+			label = strings.ToLower(matchAllCap.ReplaceAllString(label, "${1}_${2}"))
+		}
+		if slices.Contains(enum.labels, label) {
+			return nil, fmt.Errorf("the constants of the enum %s have the label %s repeated", name, label)
+		}
+		enum.constants = append(enum.constants, fmt.Sprintf("%s.%s", pkg.Name(), v))
+		enum.labels = append(enum.labels, label)
+	}
+	if len(enum.constants) == 0 {
+		return nil, fmt.Errorf("the enum %s has no exported constants of type %s", name, t.String())
+	}
+	return enum, nil
+}
+
+// Paths of the packages of the named types that make up t.
+func typeImports(t types.Type) []string {
+	switch t := t.(type) {
+	case *types.Named:
+		paths := make([]string, 0)
+		if t.Obj().Pkg() != nil {
+			paths = append(paths, t.Obj().Pkg().Path())
+		}
+		for i := range t.TypeArgs().Len() {
+			paths = append(paths, typeImports(t.TypeArgs().At(i))...)
+		}
+		return paths
+	case *types.Pointer:
+		return typeImports(t.Elem())
+	case *types.Slice:
+		return typeImports(t.Elem())
+	case *types.Array:
+		return typeImports(t.Elem())
+	case *types.Map:
+		return append(typeImports(t.Key()), typeImports(t.Elem())...)
+	default:
+		return nil
+	}
+}
+
+// The ids generated in Go are strings, uuidv7 ids can also be typed as
+// uuid.UUID, ids generated by the database must be integers.
+func (o *ObjectType) validIdStrategy(idField *ValidatedField) error {
+	switch o.idStrategy {
+	case UUIDV7:
+		if *idField.dataType != "string" && *idField.dataType != "github.com/google/uuid.UUID" {
+			return fmt.Errorf("the id of type %s must be a string or an uuid.UUID to use the %s id strategy",
+				o.Name, o.idStrategy)
+		}
+	case ULID:
+		if *idField.dataType != "string" {
+			return fmt.Errorf("the id of type %s must be a string to use the %s id strategy",
+				o.Name, o.idStrategy)
+		}
+	case DATABASE, SEQUENCE:
+		switch *idField.dataType {
+		case "int", "int32", "int64":
+		default:
+			return fmt.Errorf("the id of type %s must be an int, int32 or int64 to use the %s id strategy",
+				o.Name, o.idStrategy)
+		}
+	}
+	return nil
+}
+
+// Fields written by the insert statement, the generated ones and
+// the id when the database assigns it are left to the column defaults.
+func (o *ObjectType) insertFields() []*ValidatedField {
+	fields := make([]*ValidatedField, 0, len(o.ValidatedFields))
+	for _, v := range o.ValidatedFields {
+		if v.generated || (*v.name == "id" && o.idStrategy == DATABASE) {
+			continue
+		}
+		fields = append(fields, v)
+	}
+	return fields
+}
+
+// Columns written by the insert statement, the insert
+// fields followed by the audit columns.
+func (o *ObjectType) insertColumns() []string {
+	columns := make([]string, 0, len(o.ValidatedFields))
+	for _, v := range o.insertFields() {
+		columns = append(columns, v.column)
+	}
+	if o.audited {
+		columns = append(columns, auditInsertColumns...)
+	}
+	if o.TenantColumn != "" {
+		columns = append(columns, o.TenantColumn)
+	}
+	return columns
+}
+
+// Columns set by the update statement, the update
+// fields followed by the audit columns.
+func (o *ObjectType) updateColumns() []string {
+	columns := make([]string, 0, len(o.ValidatedFields))
+	for _, v := range o.updateFields() {
+		columns = append(columns, v.column)
+	}
+	if o.audited {
+		columns = append(columns, auditUpdateColumns...)
+	}
+	return columns
+}
+
+// The condition restricting a statement to the tenant given by the
+// parameter number param, empty if the object is not tenant scoped.
+func (o *ObjectType) tenantCondition(param int) string {
+	if o.TenantColumn == "" {
+		return ""
+	}
+	return fmt.Sprintf(" AND %s = $%d", o.TenantColumn, param)
+}
+
+func (o *ObjectType) historyTable() string {
+	return o.Table + "_history"
+}
+
+// Columns of the on conflict target of the upsert statement, the
+// configured ones or the id column when it's written by the insert.
+func (o *ObjectType) conflictColumns() []string {
+	if len(o.ConflictColumns) > 0 {
+		return o.ConflictColumns
+	}
+	for _, v := range o.insertFields() {
+		if *v.name == "id" {
+			return []string{v.column}
+		}
+	}
+	return nil
+}
+
+func (o *ObjectType) updateFields() []*ValidatedField {
+	fields := make([]*ValidatedField, 0, len(o.ValidatedFields))
+	for _, v := range o.ValidatedFields {
+		if v.update {
+			fields = append(fields, v)
+		}
+	}
+	return fields
+}
+
+// Fields read back from the insert statement returning clause
+// and written into the domain object through its setters.
+func (o *ObjectType) returningFields() []*ValidatedField {
+	fields := make([]*ValidatedField, 0)
+	for _, v := range o.ValidatedFields {
+		if v.generated || (*v.name == "id" && o.idStrategy == DATABASE) {
+			fields = append(fields, v)
+		}
+	}
+	return fields
+}
+
+// Reports whether the upsert returns the id of the stored row, the
+// conflict columns other than the id match the rows stored with an
+// id that is not the one of the object.
+func (o *ObjectType) upsertReturnsId() bool {
+	conflictColumns := o.conflictColumns()
+	if len(conflictColumns) == 0 {
+		return false
+	}
+	for _, v := range o.ValidatedFields {
+		if *v.name == "id" {
+			return !slices.Equal(conflictColumns, []string{v.column})
+		}
+	}
+	return false
+}
+
+// Fields read back from the upsert statement returning clause, the id
+// first when the upsert returns it followed by the other returning fields.
+// The upserts of the tenant scoped types return at least the id, no row
+// is returned when the conflicting row belongs to another tenant.
+func (o *ObjectType) upsertReturningFields() []*ValidatedField {
+	returning := o.returningFields()
+	if !o.upsertReturnsId() && (o.TenantColumn == "" || len(returning) > 0) {
+		return returning
+	}
+	fields := make([]*ValidatedField, 0)
+	for _, v := range o.ValidatedFields {
+		if *v.name == "id" {
+			fields = append(fields, v)
+		}
+	}
+	for _, v := range o.returningFields() {
+		if *v.name != "id" {
+			fields = append(fields, v)
+		}
+	}
+	return fields
+}
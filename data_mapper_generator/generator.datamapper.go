@@ -3,24 +3,38 @@ package data_mapper_generator
 import (
 	"fmt"
 	"path/filepath"
+	"slices"
 	"strings"
 )
 
 func (g *DataMapperGenerator) generateImports(o *ObjectType) {
 	requiredImports := []string{
+		"context",
+		"encoding/json",
 		"fmt",
+		"github.com/google/uuid",
 		"github.com/jackc/pgx/v5",
 		"github.com/jackc/pgx/v5/pgxpool",
 		"clearly-not-a-secret-project/data_mapper",
+		"clearly-not-a-secret-project/domain_events",
 		"clearly-not-a-secret-project/interfaces",
+		"clearly-not-a-secret-project/converter",
+		"clearly-not-a-secret-project/query_object",
+		"clearly-not-a-secret-project/specification",
 	}
 	if o.Lazy {
 		requiredImports = append(requiredImports, "reflect")
 	}
+	for _, v := range o.ManyToMany {
+		if v.target != nil {
+			requiredImports = append(requiredImports, fmt.Sprintf("%s/%s", filepath.Base(g.caller), v.target.Dir))
+		}
+	}
 	objPkgPath := fmt.Sprintf("%s/%s", filepath.Base(g.caller), o.Dir)
 	allImports := make([]string, 0)
 	allImports = append(allImports, objPkgPath)
 	allImports = append(allImports, requiredImports...)
+	allImports = append(allImports, o.imports...)
 	g.wln("import (")
 	for _, v := range allImports {
 		g.wln(fmt.Sprintf("\"%s\"", v))
@@ -29,50 +43,151 @@ func (g *DataMapperGenerator) generateImports(o *ObjectType) {
 }
 
 func (g *DataMapperGenerator) findStmt(o *ObjectType) string {
+	if o.SoftDelete {
+		return fmt.Sprintf("%s AND %s IS NULL;", g.selectById(o), softDeleteColumn)
+	}
+	return g.selectById(o) + ";"
+}
+
+func (g *DataMapperGenerator) existsStmt(o *ObjectType) string {
+	deleted := ""
+	if o.SoftDelete {
+		deleted = fmt.Sprintf(" AND %s IS NULL", softDeleteColumn)
+	}
+	return fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE ID = $1%s%s);", o.Table, o.tenantCondition(2), deleted)
+}
+
+func (g *DataMapperGenerator) findIncludingDeletedStmt(o *ObjectType) string {
+	return g.selectById(o) + ";"
+}
+
+func (g *DataMapperGenerator) selectById(o *ObjectType) string {
 	columns := make([]string, 0, len(o.Fields))
 	for i := range o.Fields {
 		columns = append(columns, o.Fields[i].Column)
 	}
 	columnNames := strings.Join(columns, ", ")
-	stmt := fmt.Sprintf(`SELECT %v FROM %s WHERE ID = $1;`, columnNames, o.Table)
-	return stmt
+	return fmt.Sprintf(`SELECT %v FROM %s WHERE ID = $1%s`, columnNames, o.Table, o.tenantCondition(2))
 }
 
 func (g *DataMapperGenerator) insertStmt(o *ObjectType) string {
-	columns := make([]string, 0, len(o.Fields))
-	params := make([]string, 0, len(o.Fields))
-	for i := range o.Fields {
+	return g.insertInto(o) + g.returningClause(o.returningFields()) + ";"
+}
+
+// The upsert statement writes the same columns of the insert statement,
+// on conflict only the insert columns flagged for update are overwritten.
+// When none of them is, the conflict target is set to itself so the returning
+// clause still reads the existing row back. It returns an empty statement if
+// the object has no conflict target.
+func (g *DataMapperGenerator) upsertStmt(o *ObjectType) string {
+	conflictColumns := o.conflictColumns()
+	if len(conflictColumns) == 0 {
+		return ""
+	}
+	updates := make([]string, 0, len(o.Fields))
+	for _, v := range o.insertFields() {
+		if v.update && !slices.Contains(conflictColumns, v.column) {
+			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", v.column, v.column))
+		}
+	}
+	if o.audited {
+		for _, v := range auditUpdateColumns {
+			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", v, v))
+		}
+	}
+	if o.SoftDelete {
+		// the soft deleted row is restored by the upsert
+		updates = append(updates, fmt.Sprintf("%s = NULL", softDeleteColumn))
+	}
+	returning := o.upsertReturningFields()
+	action := "DO NOTHING"
+	if len(updates) > 0 {
+		action = fmt.Sprintf("DO UPDATE SET %s", strings.Join(updates, ","))
+	} else if len(returning) > 0 {
+		action = fmt.Sprintf("DO UPDATE SET %s = EXCLUDED.%s", conflictColumns[0], conflictColumns[0])
+	}
+	if action != "DO NOTHING" && o.TenantColumn != "" {
+		// the rows of other tenants are never overwritten
+		action += fmt.Sprintf(" WHERE %s.%s = EXCLUDED.%s", o.Table, o.TenantColumn, o.TenantColumn)
+	}
+	return fmt.Sprintf(`%s ON CONFLICT (%s) %s%s;`,
+		g.insertInto(o), strings.Join(conflictColumns, ","), action, g.returningClause(returning),
+	)
+}
+
+func (g *DataMapperGenerator) insertInto(o *ObjectType) string {
+	columns := o.insertColumns()
+	params := make([]string, 0, len(columns))
+	for i := range columns {
 		params = append(params, fmt.Sprintf("$%d", i+1))
-		columns = append(columns, o.Fields[i].Column)
 	}
 	columnNames := strings.Join(columns, ",")
 	paramNames := strings.Join(params, ",")
-	stmt := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s);`,
+	stmt := fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)`,
 		o.Table, columnNames, paramNames,
 	)
+	if len(columns) == 0 {
+		stmt = fmt.Sprintf(`INSERT INTO %s DEFAULT VALUES`, o.Table)
+	}
 	return stmt
 }
 
+func (g *DataMapperGenerator) returningClause(returning []*ValidatedField) string {
+	if len(returning) == 0 {
+		return ""
+	}
+	returningColumns := make([]string, 0, len(returning))
+	for _, v := range returning {
+		returningColumns = append(returningColumns, v.column)
+	}
+	return fmt.Sprintf(` RETURNING %s`, strings.Join(returningColumns, ","))
+}
+
+// The id is the first parameter followed by the fields flagged for update,
+// in the same order DoUpdate appends them.
 func (g *DataMapperGenerator) updateStmt(o *ObjectType) string {
 	columns := make([]string, 0, len(o.Fields))
-	for i := range o.Fields {
-		if o.Fields[i].Update {
-			columns = append(columns,
-				fmt.Sprintf("%s = $%d", o.Fields[i].Column, i+1),
-			)
-		}
+	for _, v := range o.updateColumns() {
+		columns = append(columns,
+			fmt.Sprintf("%s = $%d", v, len(columns)+2),
+		)
 	}
 	columnNames := strings.Join(columns, ",")
-	stmt := fmt.Sprintf(`UPDATE %s SET %s WHERE ID = $1`,
-		o.Table, columnNames)
+	stmt := fmt.Sprintf(`UPDATE %s SET %s WHERE ID = $1%s`,
+		o.Table, columnNames, o.tenantCondition(len(columns)+2))
 	return stmt
 }
 
+func (g *DataMapperGenerator) columnsLiteral(columns []string) string {
+	quoted := make([]string, 0, len(columns))
+	for _, v := range columns {
+		quoted = append(quoted, fmt.Sprintf("\"%s\"", v))
+	}
+	return fmt.Sprintf("[]string{%s}", strings.Join(quoted, ","))
+}
+
+func columnsOf(fields []*ValidatedField) []string {
+	columns := make([]string, 0, len(fields))
+	for _, v := range fields {
+		columns = append(columns, v.column)
+	}
+	return columns
+}
+
+// With soft delete the row is kept and marked with the time it was removed.
 func (g *DataMapperGenerator) removeStmt(o *ObjectType) string {
-	stmt := fmt.Sprintf(`DELETE FROM %s WHERE ID = $1;`, o.Table)
+	if o.SoftDelete {
+		return fmt.Sprintf(`UPDATE %s SET %s = now() WHERE ID = $1%s AND %s IS NULL;`,
+			o.Table, softDeleteColumn, o.tenantCondition(2), softDeleteColumn)
+	}
+	stmt := fmt.Sprintf(`DELETE FROM %s WHERE ID = $1%s;`, o.Table, o.tenantCondition(2))
 	return stmt
 }
 
+func (g *DataMapperGenerator) restoreStmt(o *ObjectType) string {
+	return fmt.Sprintf(`UPDATE %s SET %s = NULL WHERE ID = $1%s;`, o.Table, softDeleteColumn, o.tenantCondition(2))
+}
+
 func (g *DataMapperGenerator) generateDataMapperStructType(o *ObjectType) {
 	index := -1
 	for i := range o.ValidatedFields {
@@ -86,7 +201,7 @@ func (g *DataMapperGenerator) generateDataMapperStructType(o *ObjectType) {
 	idField := o.ValidatedFields[index]
 	g.wln(fmt.Sprintf("type %sDataMapper struct {", o.Name))
 	g.wln(fmt.Sprintf("%s.PostgreSQLDataMapper[%s.DomainObject[%s],%s]",
-		dataMapperPkg, interfacesPkg, *idField.dataType, *idField.dataType,
+		dataMapperPkg, interfacesPkg, idField.typeName, idField.typeName,
 	))
 	g.wln("}")
 }
@@ -106,7 +221,7 @@ func (g *DataMapperGenerator) generateDataMapperLazy(o *ObjectType) {
 	g.wln(fmt.Sprintf("CreateGhost: %s.Create%sGhost,", o.Pkg, o.Name))
 	g.wln(fmt.Sprintf(`
 	DoLoadLine: func(resultSet pgx.Rows, obj %s.DomainObject[%s]) error {
-	`, interfacesPkg, *idField.dataType))
+	`, interfacesPkg, idField.typeName))
 	g.wln(fmt.Sprintf(`
 	subject,ok := obj.(*%s.%s)
 	`, o.Pkg, o.Name))
@@ -115,21 +230,7 @@ func (g *DataMapperGenerator) generateDataMapperLazy(o *ObjectType) {
 		return fmt.Errorf("wrong type assertion")
 	}
 	`)
-	g.wln("var (")
-	for _, v := range o.ValidatedFields {
-		g.wln(fmt.Sprintf("%s %s", *v.name, *v.dataType))
-	}
-	g.wln(")")
-	g.wln("err := resultSet.Scan(")
-	for _, v := range o.ValidatedFields {
-		g.wln(fmt.Sprintf("&%s,", *v.name))
-	}
-	g.wln(")")
-	g.wln(fmt.Sprintf(`
-	if err != nil {
-	return fmt.Errorf("error at doLoadLine %%w\n",err)
-	}
-	`))
+	g.generateScan(o.ValidatedFields, `return fmt.Errorf("error at doLoadLine %%w", %s)`)
 	for _, v := range o.ValidatedFields {
 		if *v.name != "id" {
 			n := matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper)
@@ -153,7 +254,7 @@ func (g *DataMapperGenerator) generateDataMapperCBuilder(o *ObjectType) {
 	idField := o.ValidatedFields[index]
 	g.wln(fmt.Sprintf(
 		`func New%sDataMapper(pool *pgxpool.Pool,loadedMap map[%s]%s.DomainObject[%s],) *%sDataMapper {`,
-		o.Name, *idField.dataType, interfacesPkg, *idField.dataType, o.Name,
+		o.Name, idField.typeName, interfacesPkg, idField.typeName, o.Name,
 	))
 	g.wln(fmt.Sprintf(
 		"return &%sDataMapper{",
@@ -161,17 +262,57 @@ func (g *DataMapperGenerator) generateDataMapperCBuilder(o *ObjectType) {
 	))
 	g.wln(fmt.Sprintf(
 		"PostgreSQLDataMapper: %s.PostgreSQLDataMapper[%s.DomainObject[%s],%s]{",
-		dataMapperPkg, interfacesPkg, *idField.dataType, *idField.dataType,
+		dataMapperPkg, interfacesPkg, idField.typeName, idField.typeName,
 	))
 	g.wln("Db: pool,")
 	g.wln("LoadedMap: loadedMap,")
+	g.wln(fmt.Sprintf("Table: \"%s\",", o.Table))
+	g.wln(fmt.Sprintf("InsertColumns: %s,", g.columnsLiteral(o.insertColumns())))
+	if o.PartialUpdates {
+		g.wln(fmt.Sprintf("UpdateColumns: %s,", g.columnsLiteral(o.updateColumns())))
+	}
+	if returning := o.returningFields(); len(returning) > 0 {
+		g.wln(fmt.Sprintf("ReturningColumns: %s,", g.columnsLiteral(columnsOf(returning))))
+	}
 	g.wln(fmt.Sprintf("FindStatement: \"%s\",", g.findStmt(o)))
 	g.wln(fmt.Sprintf("InsertStatement: \"%s\",", g.insertStmt(o)))
 	g.wln(fmt.Sprintf("UpdateStatement: \"%s\",", g.updateStmt(o)))
 	g.wln(fmt.Sprintf("RemoveStatement: \"%s\",", g.removeStmt(o)))
+	g.wln(fmt.Sprintf("ExistsStatement: \"%s\",", g.existsStmt(o)))
+	if upsert := g.upsertStmt(o); upsert != "" {
+		g.wln(fmt.Sprintf("UpsertStatement: \"%s\",", upsert))
+	}
+	if o.audited {
+		g.wln("Audited: true,")
+	}
+	if o.history {
+		g.wln(fmt.Sprintf("HistoryTable: \"%s\",", o.historyTable()))
+	}
+	if o.TenantColumn != "" {
+		g.wln(fmt.Sprintf("TenantColumn: \"%s\",", o.TenantColumn))
+		g.wln(fmt.Sprintf("TenantLoadedMaps: make(map[string]map[%s]%s.DomainObject[%s]),",
+			idField.typeName, interfacesPkg, idField.typeName))
+	}
+	if o.Events {
+		g.wln(fmt.Sprintf("OutboxTable: \"%s\",", outboxTable))
+	}
+	g.generateProjections(o, idField)
+	g.generateManyToMany(o, idField)
+	g.wln(fmt.Sprintf("Cursors: %sCursor{},", o.Name))
+	sortColumns := make([]string, 0, len(o.ValidatedFields))
+	for _, v := range o.sortFields() {
+		sortColumns = append(sortColumns, fmt.Sprintf("\"%s\"", v.column))
+	}
+	g.wln(fmt.Sprintf("SortColumns: []string{%s},", strings.Join(sortColumns, ", ")))
+	if o.SoftDelete {
+		g.wln(fmt.Sprintf("SoftDeleteColumn: \"%s\",", softDeleteColumn))
+		g.wln(fmt.Sprintf("FindIncludingDeletedStatement: \"%s\",", g.findIncludingDeletedStmt(o)))
+		g.wln(fmt.Sprintf("RestoreStatement: \"%s\",", g.restoreStmt(o)))
+	}
 	g.generateDoLoadFn(o)
 	g.generateDoInsertFn(o)
 	g.generateDoUpdateFn(o)
+	g.generateIdStrategy(o)
 	g.wln(fmt.Sprintf("DomainType: reflect.TypeOf(&%s.%s{}),", o.Pkg, o.Name))
 	if o.Lazy {
 		g.generateDataMapperLazy(o)
@@ -194,18 +335,16 @@ func (g *DataMapperGenerator) generateDoUpdateFn(o *ObjectType) {
 	idField := o.ValidatedFields[index]
 	g.wln(fmt.Sprintf(
 		"DoUpdate: func(obj %s.DomainObject[%s], stmt *%s.PreparedStatement) error {",
-		interfacesPkg, *idField.dataType, dataMapperPkg,
+		interfacesPkg, idField.typeName, dataMapperPkg,
 	))
 	g.wln(fmt.Sprintf(
 		"subject, ok := obj.(*%s.%s)", o.Pkg, o.Name,
 	))
 	g.wln("if !ok { return fmt.Errorf(\"wrong type assertion\")}")
 	g.wln("stmt.Append(subject.Id())")
-	for _, v := range o.ValidatedFields {
-		if v.update {
-			n := matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper)
-			g.wln(fmt.Sprintf("stmt.Append(subject.%s())", n))
-		}
+	for _, v := range o.updateFields() {
+		n := matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper)
+		g.generateAppend(v, fmt.Sprintf("subject.%s()", n))
 	}
 	g.wln("return nil },")
 }
@@ -223,56 +362,409 @@ func (g *DataMapperGenerator) generateDoInsertFn(o *ObjectType) {
 	idField := o.ValidatedFields[index]
 	g.wln(fmt.Sprintf(
 		"DoInsert: func(obj %s.DomainObject[%s], stmt *%s.PreparedStatement) error {",
-		interfacesPkg, *idField.dataType, dataMapperPkg,
+		interfacesPkg, idField.typeName, dataMapperPkg,
 	))
 	g.wln(fmt.Sprintf(
 		"subject, ok := obj.(*%s.%s)",
 		o.Pkg, o.Name,
 	))
 	g.wln("if !ok { return fmt.Errorf(\"wrong type assertion \") }")
-	for _, v := range o.ValidatedFields {
+	for _, v := range o.insertFields() {
 		n := matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper)
-		g.wln(fmt.Sprintf("stmt.Append(subject.%s())", n))
+		g.generateAppend(v, fmt.Sprintf("subject.%s()", n))
 	}
 	g.wln("return nil },")
 }
 
-func (g *DataMapperGenerator) generateDoLoadFn(o *ObjectType) {
+// Writes NextId and DoSetId when the id is not assigned by the domain,
+// DoReturning when the insert statement has a returning clause and
+// DoUpsertReturning when the upsert returns the id of the stored row.
+func (g *DataMapperGenerator) generateIdStrategy(o *ObjectType) {
 	index := -1
-	variables := make(map[string]string, 0)
 	for i := range o.ValidatedFields {
 		if *o.ValidatedFields[i].name == "id" {
 			index = i
 		}
-		variables[*o.ValidatedFields[i].name] = *o.ValidatedFields[i].dataType
 	}
 	if index < 0 {
 		panic(fmt.Errorf("could not find id field in the validated fields"))
 	}
 	idField := o.ValidatedFields[index]
+	switch o.idStrategy {
+	case UUIDV7:
+		g.wln(fmt.Sprintf(
+			"NextId: func(ctx context.Context, db %s.Executor) (%s, error) {",
+			dataMapperPkg, idField.typeName,
+		))
+		if *idField.dataType == "string" {
+			g.wln(fmt.Sprintf("return %s.NewUUIDv7()", dataMapperPkg))
+		} else {
+			g.wln("return uuid.NewV7()")
+		}
+		g.wln("},")
+	case ULID:
+		g.wln(fmt.Sprintf(
+			"NextId: func(ctx context.Context, db %s.Executor) (%s, error) {",
+			dataMapperPkg, idField.typeName,
+		))
+		g.wln(fmt.Sprintf("return %s.NewULID()", dataMapperPkg))
+		g.wln("},")
+	case SEQUENCE:
+		g.wln(fmt.Sprintf(
+			"NextId: func(ctx context.Context, db %s.Executor) (%s, error) {",
+			dataMapperPkg, idField.typeName,
+		))
+		g.wln(fmt.Sprintf("return %s.NextVal[%s](ctx, db, \"%s\")",
+			dataMapperPkg, idField.typeName, o.sequence))
+		g.wln("},")
+	}
+	if o.idStrategy == UUIDV7 || o.idStrategy == ULID || o.idStrategy == SEQUENCE || o.upsertReturnsId() {
+		g.wln(fmt.Sprintf(
+			"DoSetId: func(obj %s.DomainObject[%s], id %s) error {",
+			interfacesPkg, idField.typeName, idField.typeName,
+		))
+		g.wln(fmt.Sprintf("subject, ok := obj.(*%s.%s)", o.Pkg, o.Name))
+		g.wln("if !ok { return fmt.Errorf(\"wrong type assertion\") }")
+		g.wln("subject.SetId(id)")
+		g.wln("return nil },")
+	}
+	if o.upsertReturnsId() {
+		g.generateDoUpsertReturning(o, idField)
+	}
+	returning := o.returningFields()
+	if len(returning) == 0 {
+		return
+	}
 	g.wln(fmt.Sprintf(
-		"DoLoad: func (resultSet pgx.Rows) (%s.DomainObject[%s],error){",
-		interfacesPkg, *idField.dataType,
+		"DoReturning: func(resultSet pgx.Rows, obj %s.DomainObject[%s]) error {",
+		interfacesPkg, idField.typeName,
 	))
-	g.wln("var (")
-	for k, v := range variables {
-		g.wln(fmt.Sprintf("%s %s", k, v))
+	g.wln(fmt.Sprintf("subject, ok := obj.(*%s.%s)", o.Pkg, o.Name))
+	g.wln("if !ok { return fmt.Errorf(\"wrong type assertion\") }")
+	g.generateScan(returning, "return %s")
+	for _, v := range returning {
+		n := matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper)
+		g.wln(fmt.Sprintf("subject.Set%s(%s)", n, *v.name))
 	}
-	g.wln(")")
-	g.wln("err := resultSet.Scan(")
-	for k := range variables {
-		g.wln(fmt.Sprintf("&%s,", k))
+	g.wln("return nil },")
+}
+
+// The id read back from the upsert is returned for the data mapper
+// to set it with DoSetId, the other returning fields are set here.
+func (g *DataMapperGenerator) generateDoUpsertReturning(o *ObjectType, idField *ValidatedField) {
+	g.wln(fmt.Sprintf(
+		"DoUpsertReturning: func(resultSet pgx.Rows, obj %s.DomainObject[%s]) (%s, error) {",
+		interfacesPkg, idField.typeName, idField.typeName,
+	))
+	g.wln(fmt.Sprintf("var zero %s", idField.typeName))
+	returning := o.upsertReturningFields()
+	if len(returning) > 1 {
+		g.wln(fmt.Sprintf("subject, ok := obj.(*%s.%s)", o.Pkg, o.Name))
+		g.wln("if !ok { return zero, fmt.Errorf(\"wrong type assertion\") }")
 	}
-	g.wln(")")
-	g.wln("if err != nil {return nil, err}")
+	g.generateScan(returning, "return zero, %s")
+	for _, v := range returning[1:] {
+		n := matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper)
+		g.wln(fmt.Sprintf("subject.Set%s(%s)", n, *v.name))
+	}
+	g.wln("return id, nil },")
+}
+
+func (g *DataMapperGenerator) generateDoLoadFn(o *ObjectType) {
+	index := -1
+	for i := range o.ValidatedFields {
+		if *o.ValidatedFields[i].name == "id" {
+			index = i
+		}
+	}
+	if index < 0 {
+		panic(fmt.Errorf("could not find id field in the validated fields"))
+	}
+	idField := o.ValidatedFields[index]
+	g.wln(fmt.Sprintf(
+		"DoLoad: func (resultSet pgx.Rows) (%s.DomainObject[%s],error){",
+		interfacesPkg, idField.typeName,
+	))
+	g.generateScan(o.ValidatedFields, "return nil, %s")
 	g.wln(fmt.Sprintf("return %s.%s(", o.Pkg, o.Builder))
-	for k := range variables {
-		g.wln(fmt.Sprintf("%s,", k))
+	for _, v := range o.ValidatedFields {
+		g.wln(fmt.Sprintf("%s,", *v.name))
 	}
 	g.wln("), nil")
 	g.wln("},")
 }
 
+// PostgreSQL type of the columns holding ids of the data type.
+func idColumnType(dataType string) string {
+	switch dataType {
+	case "int", "int64":
+		return "bigint"
+	case "int32":
+		return "integer"
+	case "github.com/google/uuid.UUID":
+		return "uuid"
+	default:
+		return "text"
+	}
+}
+
+func (g *DataMapperGenerator) auditDDL(o *ObjectType) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS created_at timestamptz, "+
+		"ADD COLUMN IF NOT EXISTS created_by text, "+
+		"ADD COLUMN IF NOT EXISTS updated_at timestamptz, "+
+		"ADD COLUMN IF NOT EXISTS updated_by text;", o.Table)
+}
+
+func (g *DataMapperGenerator) historyDDL(o *ObjectType, idField *ValidatedField) string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (history_id bigserial PRIMARY KEY, "+
+		"row_id %s NOT NULL, operation text NOT NULL, changed_at timestamptz NOT NULL, "+
+		"changed_by text NOT NULL, before jsonb, after jsonb);", o.historyTable(), idColumnType(*idField.dataType))
+}
+
+// Writes the DDL of the audit columns of the object table and of its history table.
+func (g *DataMapperGenerator) generateAuditDDL(o *ObjectType) {
+	if !o.audited {
+		return
+	}
+	g.wln(fmt.Sprintf("const %sAuditDDL = \"%s\"", o.Name, g.auditDDL(o)))
+	if !o.history {
+		return
+	}
+	for _, v := range o.ValidatedFields {
+		if *v.name == "id" {
+			g.wln(fmt.Sprintf("const %sHistoryDDL = \"%s\"", o.Name, g.historyDDL(o, v)))
+		}
+	}
+}
+
+// Writes the projections calling their functions with the object.
+func (g *DataMapperGenerator) generateProjections(o *ObjectType, idField *ValidatedField) {
+	if len(o.Projections) == 0 {
+		return
+	}
+	g.wln(fmt.Sprintf("Projections: []%s.Projection[%s.DomainObject[%s]]{", dataMapperPkg, interfacesPkg, idField.typeName))
+	for _, v := range o.Projections {
+		g.wln("{")
+		g.wln(fmt.Sprintf("Table: \"%s\",", v.Table))
+		g.wln(fmt.Sprintf("Project: func(obj %s.DomainObject[%s]) (map[string]any, error) {", interfacesPkg, idField.typeName))
+		g.wln(fmt.Sprintf("subject, ok := obj.(*%s.%s)", o.Pkg, o.Name))
+		g.wln("if !ok {")
+		g.wln("return nil, fmt.Errorf(\"wrong type assertion\")")
+		g.wln("}")
+		g.wln(fmt.Sprintf("return %s.%s(subject)", o.Pkg, v.Function))
+		g.wln("},")
+		g.wln("},")
+	}
+	g.wln("},")
+}
+
+// Writes the associations reading the ids from their fields.
+func (g *DataMapperGenerator) generateManyToMany(o *ObjectType, idField *ValidatedField) {
+	if len(o.ManyToMany) == 0 {
+		return
+	}
+	g.wln(fmt.Sprintf("ManyToMany: []%s.ManyToMany[%s.DomainObject[%s]]{", dataMapperPkg, interfacesPkg, idField.typeName))
+	for _, v := range o.ManyToMany {
+		g.wln("{")
+		g.wln(fmt.Sprintf("JoinTable: \"%s\",", v.JoinTable))
+		g.wln(fmt.Sprintf("OwnerColumn: \"%s\",", v.OwnerColumn))
+		g.wln(fmt.Sprintf("TargetColumn: \"%s\",", v.TargetColumn))
+		g.wln(fmt.Sprintf("Association: func(obj %s.DomainObject[%s]) %s.Association {", interfacesPkg, idField.typeName, interfacesPkg))
+		g.wln(fmt.Sprintf("subject, ok := obj.(*%s.%s)", o.Pkg, o.Name))
+		g.wln("if !ok {")
+		g.wln("return nil")
+		g.wln("}")
+		g.wln(fmt.Sprintf("return subject.%s()", matchFirstCh.ReplaceAllStringFunc(v.Name, strings.ToUpper)))
+		g.wln("},")
+		g.wln("},")
+	}
+	g.wln("},")
+}
+
+// Writes the methods of the data mapper loading the associated ids
+// into the object and, for the associations with a target, finding
+// the associated objects as ghosts.
+func (g *DataMapperGenerator) generateManyToManyMethods(o *ObjectType) {
+	for i, v := range o.ManyToMany {
+		n := matchFirstCh.ReplaceAllStringFunc(v.Name, strings.ToUpper)
+		g.wln(fmt.Sprintf("// Load%s reads the ids associated to the object through %s.", n, v.JoinTable))
+		g.wln(fmt.Sprintf("func (d *%sDataMapper) Load%s(ctx context.Context, obj *%s.%s) ([]%s, error) {",
+			o.Name, n, o.Pkg, o.Name, v.typeName))
+		g.wln(fmt.Sprintf("return %s.LoadAssociation(ctx, d.PostgreSQLDataMapper, d.ManyToMany[%d], obj.Id(), obj.%s())",
+			dataMapperPkg, i, n))
+		g.wln("}")
+		if v.target == nil {
+			continue
+		}
+		g.wln(fmt.Sprintf("// Find%s returns the ghosts of the objects associated to the object through %s.", n, v.JoinTable))
+		g.wln(fmt.Sprintf("func (d *%sDataMapper) Find%s(ctx context.Context, obj *%s.%s) ([]%s.DomainObject[%s], error) {",
+			o.Name, n, o.Pkg, o.Name, interfacesPkg, v.typeName))
+		g.wln(fmt.Sprintf("ids, err := d.Load%s(ctx, obj)", n))
+		g.wln("if err != nil {")
+		g.wln("return nil, err")
+		g.wln("}")
+		g.wln(fmt.Sprintf("ghosts := make([]%s.DomainObject[%s], 0, len(ids))", interfacesPkg, v.typeName))
+		g.wln("for _, id := range ids {")
+		g.wln(fmt.Sprintf("ghosts = append(ghosts, %s.Create%sGhost(id))", v.target.Pkg, v.target.Name))
+		g.wln("}")
+		g.wln("return ghosts, nil")
+		g.wln("}")
+	}
+}
+
+// Writes the column names of the object and the constructor of
+// the query objects selecting the columns its data mapper loads.
+func (g *DataMapperGenerator) generateQuery(o *ObjectType) {
+	g.wln(fmt.Sprintf("var %sColumns = struct {", o.Name))
+	for _, v := range o.ValidatedFields {
+		g.wln(fmt.Sprintf("%s string", matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper)))
+	}
+	g.wln("}{")
+	for _, v := range o.ValidatedFields {
+		g.wln(fmt.Sprintf("%s: \"%s\",", matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper), v.column))
+	}
+	g.wln("}")
+	columns := make([]string, 0, len(o.ValidatedFields))
+	for _, v := range o.ValidatedFields {
+		columns = append(columns, fmt.Sprintf("\"%s\"", v.column))
+	}
+	g.wln(fmt.Sprintf("func New%sQuery() *query_object.QueryObject {", o.Name))
+	g.wln(fmt.Sprintf("return query_object.New(\"%s\", %s)", o.Table, strings.Join(columns, ", ")))
+	g.wln("}")
+}
+
+// The sums are generated for the aggregated fields of numeric types.
+func (v *ValidatedField) summable() bool {
+	return v.ordered() && *v.dataType != "string"
+}
+
+// The data types satisfying cmp.Ordered.
+func (v *ValidatedField) ordered() bool {
+	switch *v.dataType {
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "float32", "float64", "string":
+		return true
+	}
+	return false
+}
+
+// Writes the fields the specifications of the object are built from, the
+// ones written without a conversion, ordered when their data type is.
+func (g *DataMapperGenerator) generateSpecificationFields(o *ObjectType) {
+	fields := o.sortFields()
+	fieldType := func(v *ValidatedField) string {
+		if v.ordered() {
+			return "OrderedField"
+		}
+		return "Field"
+	}
+	g.wln(fmt.Sprintf("// %sFields build the specifications of the %s objects.", o.Name, o.Name))
+	g.wln(fmt.Sprintf("var %sFields = struct {", o.Name))
+	for _, v := range fields {
+		g.wln(fmt.Sprintf("%s specification.%s[*%s.%s, %s]", matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper),
+			fieldType(v), o.Pkg, o.Name, v.typeName))
+	}
+	g.wln("}{")
+	for _, v := range fields {
+		n := matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper)
+		g.wln(fmt.Sprintf("%s: specification.New%s(\"%s\", (*%s.%s).%s),", n, fieldType(v), v.column, o.Pkg, o.Name, n))
+	}
+	g.wln("}")
+}
+
+// Writes the methods of the data mapper computing the sum, for the numeric
+// ones, the minimum and the maximum of the aggregated fields over the rows
+// of a source, the minimum and maximum of no rows are the zero value.
+func (g *DataMapperGenerator) generateAggregates(name string, fields []*ValidatedField) {
+	for _, v := range fields {
+		if !v.aggregate {
+			continue
+		}
+		n := matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper)
+		if v.summable() {
+			g.wln(fmt.Sprintf("// Sum%s adds up the %s of the rows of the source.", n, v.column))
+			g.wln(fmt.Sprintf("func (d *%sDataMapper) Sum%s(ctx context.Context, source %s.StatementSource) (%s, error) {",
+				name, n, dataMapperPkg, v.typeName))
+			g.wln(fmt.Sprintf("var value %s", v.typeName))
+			g.wln(fmt.Sprintf("err := d.Aggregate(ctx, source, query_object.Sum(\"%s\"), &value)", v.column))
+			g.wln("return value, err")
+			g.wln("}")
+		}
+		for _, function := range []string{"Min", "Max"} {
+			description := "minimum"
+			if function == "Max" {
+				description = "maximum"
+			}
+			g.wln(fmt.Sprintf("// %s%s returns the %s %s of the rows of the source, the zero value without rows.",
+				function, n, description, v.column))
+			g.wln(fmt.Sprintf("func (d *%sDataMapper) %s%s(ctx context.Context, source %s.StatementSource) (%s, error) {",
+				name, function, n, dataMapperPkg, v.typeName))
+			g.wln(fmt.Sprintf("var value *%s", v.typeName))
+			g.wln(fmt.Sprintf("err := d.Aggregate(ctx, source, query_object.%s(\"%s\"), &value)", function, v.column))
+			g.wln(fmt.Sprintf("return %s.FromNullable(value), err", dataMapperPkg))
+			g.wln("}")
+		}
+	}
+}
+
+// Writes the cursor encoder of the pages of the object, the cursors
+// hold the values of the sort columns typed as their fields.
+func (g *DataMapperGenerator) generateCursor(o *ObjectType) {
+	var idField *ValidatedField
+	for _, v := range o.ValidatedFields {
+		if *v.name == "id" {
+			idField = v
+		}
+	}
+	if idField == nil {
+		panic(fmt.Errorf("could not find id field in the validated fields"))
+	}
+	fields := o.sortFields()
+	g.wln(fmt.Sprintf("// %sCursor encodes the sort keys of the %s objects in opaque cursors.", o.Name, o.Name))
+	g.wln(fmt.Sprintf("type %sCursor struct{}", o.Name))
+	g.wln(fmt.Sprintf("func (%sCursor) Encode(obj %s.DomainObject[%s], columns []string) (string, error) {",
+		o.Name, interfacesPkg, idField.typeName))
+	g.wln(fmt.Sprintf("subject, ok := obj.(*%s.%s)", o.Pkg, o.Name))
+	g.wln("if !ok {")
+	g.wln("return \"\", fmt.Errorf(\"wrong type assertion\")")
+	g.wln("}")
+	g.wln("values := make([]any, 0, len(columns))")
+	g.wln("for _, v := range columns {")
+	g.wln("switch v {")
+	for _, v := range fields {
+		g.wln(fmt.Sprintf("case \"%s\":", v.column))
+		g.wln(fmt.Sprintf("values = append(values, subject.%s())", matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper)))
+	}
+	g.wln("default:")
+	g.wln(fmt.Sprintf("return \"\", fmt.Errorf(\"the column %%s can't sort the pages of %s\", v)", o.Name))
+	g.wln("}")
+	g.wln("}")
+	g.wln(fmt.Sprintf("return %s.EncodeCursor(values)", dataMapperPkg))
+	g.wln("}")
+	g.wln(fmt.Sprintf("func (%sCursor) Decode(cursor string, columns []string) ([]any, error) {", o.Name))
+	g.wln(fmt.Sprintf("raw, err := %s.DecodeCursor(cursor, len(columns))", dataMapperPkg))
+	g.wln("if err != nil {")
+	g.wln("return nil, err")
+	g.wln("}")
+	g.wln("values := make([]any, 0, len(columns))")
+	g.wln("for i, v := range columns {")
+	g.wln("switch v {")
+	for _, v := range fields {
+		g.wln(fmt.Sprintf("case \"%s\":", v.column))
+		g.wln(fmt.Sprintf("var value %s", v.typeName))
+		g.wln("err = json.Unmarshal(raw[i], &value)")
+		g.wln("values = append(values, value)")
+	}
+	g.wln("default:")
+	g.wln(fmt.Sprintf("return nil, fmt.Errorf(\"the column %%s can't sort the pages of %s\", v)", o.Name))
+	g.wln("}")
+	g.wln("if err != nil {")
+	g.wln("return nil, fmt.Errorf(\"invalid cursor %w\", err)")
+	g.wln("}")
+	g.wln("}")
+	g.wln("return values, nil")
+	g.wln("}")
+}
+
 func (g *DataMapperGenerator) generateDataMapper(o *ObjectType) error {
 	defer func() {
 		if r := recover(); r != nil {
@@ -282,8 +774,20 @@ func (g *DataMapperGenerator) generateDataMapper(o *ObjectType) error {
 	g.buff.Reset()
 	newPkgPath := g.generateNewPkg(generatedPkgName, generatedPkgName)
 	g.generateImports(o)
-	g.generateDataMapperStructType(o)
-	g.generateDataMapperCBuilder(o)
+	if o.kind == EVENTSOURCED {
+		g.generateEventSourcedDataMapper(o)
+	} else {
+		g.generateDataMapperStructType(o)
+		g.generateDataMapperCBuilder(o)
+		g.generateManyToManyMethods(o)
+		g.generateQueries(o)
+		g.generateQuery(o)
+		g.generateCursor(o)
+		g.generateSpecificationFields(o)
+		g.generateAggregates(o.Name, o.ValidatedFields)
+		g.generateInMemoryDataMapper(o)
+		g.generateAuditDDL(o)
+	}
 	err := g.writeFile(newPkgPath, o.Name, "data_mapper", "")
 	if err != nil {
 		return err
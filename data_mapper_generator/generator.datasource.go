@@ -8,6 +8,7 @@ func (g *DataMapperGenerator) generateDataSourceImports() {
 	requiredImports := []string{
 		fmt.Sprintf("clearly-not-a-secret-project/%s", generatedRegistryPkg),
 		"clearly-not-a-secret-project/interfaces",
+		"context",
 		"fmt",
 	}
 	g.wln("import (")
@@ -33,7 +34,21 @@ func (g *DataMapperGenerator) generateDataSource() error {
 		}
 		return nil
 	}
-	`, generatedRegistryPkg))
+
+	// Reference finds the object of the type with the qualified name, the
+	// import path of its package followed by the name of the type, and the id.
+	func Reference[K comparable](ctx context.Context, name string, id K) (interfaces.DomainObject[K], error) {
+		instance,err := %s.Instance[K]()
+		if err != nil {
+		return nil, fmt.Errorf("error in concrete datasource %%w", err)
+		}
+		mapper, err := instance.Named(name)
+		if err != nil {
+		return nil, fmt.Errorf("error in concrete datasource %%w", err)
+		}
+		return mapper.Find(ctx, id)
+	}
+	`, generatedRegistryPkg, generatedRegistryPkg))
 
 	err := g.writeFile(path, "generated", "", "datasource")
 	if err != nil {
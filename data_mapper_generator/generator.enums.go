@@ -0,0 +1,85 @@
+package data_mapper_generator
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+func (e *Enum) ddl() string {
+	labels := make([]string, 0, len(e.labels))
+	for _, v := range e.labels {
+		labels = append(labels, fmt.Sprintf("'%s'", strings.ReplaceAll(v, "'", "''")))
+	}
+	return fmt.Sprintf("CREATE TYPE %s AS ENUM (%s);", e.name, strings.Join(labels, ", "))
+}
+
+// Returns the last constant of the enum of the field, the
+// first one is often the zero value of the type.
+func (o *ObjectType) enumConstant(v *ValidatedField) string {
+	for _, e := range o.enums {
+		if e.name == v.enum {
+			return e.constants[len(e.constants)-1]
+		}
+	}
+	return ""
+}
+
+// Writes for each enum its DDL, the registration of the converter of its
+// Go type under the enum name and a function validating the database enums
+// have a label for every constant.
+func (g *DataMapperGenerator) generateEnums() error {
+	g.buff.Reset()
+	newPkgPath := g.generateNewPkg(generatedPkgName, generatedPkgName)
+	allImports := []string{
+		"context",
+		"clearly-not-a-secret-project/converter",
+		"clearly-not-a-secret-project/data_mapper",
+	}
+	for _, v := range g.config.enums {
+		if !slices.Contains(allImports, v.pkgPath) {
+			allImports = append(allImports, v.pkgPath)
+		}
+	}
+	g.wln("import (")
+	for _, v := range allImports {
+		if filepath.IsAbs(v) {
+			// the packages type checked from source are identified by their dir
+			rel, err := filepath.Rel(g.caller, v)
+			if err != nil {
+				return err
+			}
+			v = fmt.Sprintf("%s/%s", filepath.Base(g.caller), filepath.ToSlash(rel))
+		}
+		g.wln(fmt.Sprintf("\"%s\"", v))
+	}
+	g.wln(")")
+	for _, v := range g.config.enums {
+		g.wln(fmt.Sprintf("const %sEnumDDL = `%s`", v.goName, v.ddl()))
+	}
+	g.wln("func init() {")
+	for _, v := range g.config.enums {
+		g.wln(fmt.Sprintf("%s.Register(%q, %s.Enum(map[%s]string{", converterPkg, v.name, converterPkg, v.typeName))
+		for i := range v.constants {
+			g.wln(fmt.Sprintf("%s: %q,", v.constants[i], v.labels[i]))
+		}
+		g.wln("}))")
+	}
+	g.wln("}")
+	g.wln("// ValidateEnums checks the database enums have a label for every constant of their Go types.")
+	g.wln(fmt.Sprintf("func ValidateEnums(ctx context.Context, db %s.Executor) error {", dataMapperPkg))
+	for _, v := range g.config.enums {
+		labels := make([]string, 0, len(v.labels))
+		for _, l := range v.labels {
+			labels = append(labels, fmt.Sprintf("%q", l))
+		}
+		g.wln(fmt.Sprintf("if err := %s.ValidateEnum(ctx, db, %q, []string{%s}); err != nil {",
+			dataMapperPkg, v.name, strings.Join(labels, ", ")))
+		g.wln("return err")
+		g.wln("}")
+	}
+	g.wln("return nil")
+	g.wln("}")
+	return g.writeFile(newPkgPath, "enums", "", "")
+}
@@ -0,0 +1,104 @@
+package data_mapper_generator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The fields of the snapshots of the event sourced objects,
+// all the fields of the object except its id.
+func (o *ObjectType) snapshotFields() []*ValidatedField {
+	fields := make([]*ValidatedField, 0, len(o.ValidatedFields))
+	for _, v := range o.ValidatedFields {
+		if *v.name != "id" {
+			fields = append(fields, v)
+		}
+	}
+	return fields
+}
+
+// Writes the type holding the state of the snapshots, its fields are
+// encoded with the column of the object fields as their json name.
+func (g *DataMapperGenerator) generateSnapshotType(o *ObjectType) {
+	g.wln(fmt.Sprintf("type %sSnapshot struct {", matchFirstCh.ReplaceAllStringFunc(o.Name, strings.ToLower)))
+	for _, v := range o.snapshotFields() {
+		n := matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper)
+		g.wln(fmt.Sprintf("%s %s `json:\"%s\"`", n, v.typeName, v.column))
+	}
+	g.wln("}")
+}
+
+func (g *DataMapperGenerator) generateEventSourcedDataMapper(o *ObjectType) {
+	index := -1
+	for i := range o.ValidatedFields {
+		if *o.ValidatedFields[i].name == "id" {
+			index = i
+		}
+	}
+	if index < 0 {
+		panic(fmt.Errorf("could not find id field in the validated fields"))
+	}
+	idField := o.ValidatedFields[index]
+	snapshotType := fmt.Sprintf("%sSnapshot", matchFirstCh.ReplaceAllStringFunc(o.Name, strings.ToLower))
+	g.wln(fmt.Sprintf("type %sDataMapper struct {", o.Name))
+	g.wln(fmt.Sprintf("%s.EventSourcedDataMapper[%s.DomainObject[%s],%s]",
+		dataMapperPkg, interfacesPkg, idField.typeName, idField.typeName,
+	))
+	g.wln("}")
+	g.generateSnapshotType(o)
+	g.wln(fmt.Sprintf(
+		`func New%sDataMapper(pool *pgxpool.Pool,loadedMap map[%s]%s.DomainObject[%s],) *%sDataMapper {`,
+		o.Name, idField.typeName, interfacesPkg, idField.typeName, o.Name,
+	))
+	g.wln(fmt.Sprintf("return &%sDataMapper{", o.Name))
+	g.wln(fmt.Sprintf(
+		"EventSourcedDataMapper: %s.EventSourcedDataMapper[%s.DomainObject[%s],%s]{",
+		dataMapperPkg, interfacesPkg, idField.typeName, idField.typeName,
+	))
+	g.wln("Db: pool,")
+	g.wln("LoadedMap: loadedMap,")
+	g.wln(fmt.Sprintf("StreamType: \"%s\",", o.Table))
+	g.wln(fmt.Sprintf("EventsTable: \"%s\",", eventsTable))
+	g.wln(fmt.Sprintf("SnapshotsTable: \"%s\",", snapshotsTable))
+	if o.SnapshotEvery > 0 {
+		g.wln(fmt.Sprintf("SnapshotEvery: %d,", o.SnapshotEvery))
+	}
+	g.wln("Decoder: domain_events.NewDecoder(")
+	for _, v := range o.appliers {
+		g.wln(fmt.Sprintf("domain_events.DecodingOf[%s](),", v.typeName))
+	}
+	g.wln("),")
+	g.wln(fmt.Sprintf("DomainType: reflect.TypeOf(&%s.%s{}),", o.Pkg, o.Name))
+	g.wln(fmt.Sprintf("CreateGhost: %s.Create%sGhost,", o.Pkg, o.Name))
+	g.wln(fmt.Sprintf("DoSnapshot: func(obj %s.DomainObject[%s]) ([]byte, error) {", interfacesPkg, idField.typeName))
+	g.wln(fmt.Sprintf("subject, ok := obj.(*%s.%s)", o.Pkg, o.Name))
+	g.wln("if !ok {")
+	g.wln("return nil, fmt.Errorf(\"wrong type assertion\")")
+	g.wln("}")
+	g.wln(fmt.Sprintf("return json.Marshal(%s{", snapshotType))
+	for _, v := range o.snapshotFields() {
+		n := matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper)
+		g.wln(fmt.Sprintf("%s: subject.%s(),", n, n))
+	}
+	g.wln("})")
+	g.wln("},")
+	g.wln(fmt.Sprintf("DoRestore: func(obj %s.DomainObject[%s], state []byte) error {", interfacesPkg, idField.typeName))
+	g.wln(fmt.Sprintf("subject, ok := obj.(*%s.%s)", o.Pkg, o.Name))
+	g.wln("if !ok {")
+	g.wln("return fmt.Errorf(\"wrong type assertion\")")
+	g.wln("}")
+	g.wln(fmt.Sprintf("var snapshot %s", snapshotType))
+	g.wln("err := json.Unmarshal(state, &snapshot)")
+	g.wln("if err != nil {")
+	g.wln("return err")
+	g.wln("}")
+	for _, v := range o.snapshotFields() {
+		n := matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper)
+		g.wln(fmt.Sprintf("subject.Set%s(snapshot.%s)", n, n))
+	}
+	g.wln("return nil")
+	g.wln("},")
+	g.wln("},")
+	g.wln("}")
+	g.wln("}")
+}
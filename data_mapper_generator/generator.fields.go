@@ -0,0 +1,124 @@
+package data_mapper_generator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Types that already represent a NULL by themselves, pgx scans into
+// and encodes them without any conversion. Slices are mapped to array
+// columns, a nil slice is a NULL array.
+func isNullableType(dataType string) bool {
+	return strings.HasPrefix(dataType, "*") ||
+		strings.HasPrefix(dataType, "[]") ||
+		strings.HasPrefix(dataType, "database/sql.Null") ||
+		strings.HasPrefix(dataType, "github.com/jackc/pgx/v5/pgtype.")
+}
+
+// A nullable field holds the NULL of its column by its type, a pointer, a
+// slice, a sql.Null or a pgtype type, or writes its zero value as NULL once
+// opted in with zeroIsNull. The converters and the json fields write their
+// own NULLs.
+func (v *ValidatedField) validNullable() error {
+	switch {
+	case v.zeroIsNull && isNullableType(*v.dataType):
+		return fmt.Errorf("the type %s holds NULL by itself, its zero value can't be written as NULL", v.typeName)
+	case v.nullable && !v.zeroIsNull && v.converter == "" && !v.json && !isNullableType(*v.dataType):
+		return fmt.Errorf("the nullable column %s requires a pointer, a sql.Null or a pgtype type instead of %s, "+
+			"or zeroIsNull to write the zero value as NULL", v.column, v.typeName)
+	}
+	return nil
+}
+
+// A field needs a conversion when the value scanned from its column
+// is not of the field data type.
+func (v *ValidatedField) converted() bool {
+	return v.converter != "" || v.json || v.zeroIsNull
+}
+
+// The columns of the fields written without a conversion and never NULL
+// sort the pages of the object, their values are held by the cursors.
+func (o *ObjectType) sortFields() []*ValidatedField {
+	fields := make([]*ValidatedField, 0, len(o.ValidatedFields))
+	for _, v := range o.ValidatedFields {
+		if !v.converted() && !v.nullable && !isNullableType(*v.dataType) {
+			fields = append(fields, v)
+		}
+	}
+	return fields
+}
+
+// Name of the variable the column is scanned into, after the conversion
+// the field value is held by a variable named after the field.
+func (v *ValidatedField) scanVar() string {
+	if v.converted() {
+		return *v.name + "Src"
+	}
+	return *v.name
+}
+
+func (v *ValidatedField) scanType() string {
+	switch {
+	case v.converter != "":
+		return "any"
+	case v.json:
+		return "[]byte"
+	case v.converted():
+		return "*" + v.typeName
+	default:
+		return v.typeName
+	}
+}
+
+// Writes the declaration of the scan variables, the scan of the current row
+// and the conversions to the fields data types, errReturn is the return
+// statement format for a single error operand.
+func (g *DataMapperGenerator) generateScan(fields []*ValidatedField, errReturn string) {
+	g.wln("var (")
+	for _, v := range fields {
+		g.wln(fmt.Sprintf("%s %s", v.scanVar(), v.scanType()))
+	}
+	g.wln(")")
+	g.wln("err := resultSet.Scan(")
+	for _, v := range fields {
+		g.wln(fmt.Sprintf("&%s,", v.scanVar()))
+	}
+	g.wln(")")
+	g.wln(fmt.Sprintf("if err != nil { %s }", fmt.Sprintf(errReturn, "err")))
+	for _, v := range fields {
+		switch {
+		case v.converter != "":
+			g.wln(fmt.Sprintf("%s, err := %s.FromDB[%s](\"%s\", %s)",
+				*v.name, converterPkg, v.typeName, v.converter, v.scanVar()))
+			g.wln(fmt.Sprintf("if err != nil { %s }", fmt.Sprintf(errReturn, "err")))
+		case v.json:
+			// a NULL column leaves the field zero valued
+			g.wln(fmt.Sprintf("var %s %s", *v.name, v.typeName))
+			g.wln(fmt.Sprintf("if %s != nil {", v.scanVar()))
+			g.wln(fmt.Sprintf("err = json.Unmarshal(%s, &%s)", v.scanVar(), *v.name))
+			g.wln(fmt.Sprintf("if err != nil { %s }", fmt.Sprintf(errReturn, "err")))
+			g.wln("}")
+		case v.converted():
+			g.wln(fmt.Sprintf("%s := %s.FromNullable(%s)", *v.name, dataMapperPkg, v.scanVar()))
+		}
+	}
+}
+
+// Writes the conversion of the field value expr to the value of its column
+// and appends it to the statement, it's written inside a func returning an error.
+func (g *DataMapperGenerator) generateAppend(v *ValidatedField, expr string) {
+	switch {
+	case v.converter != "":
+		g.wln(fmt.Sprintf("%sValue, err := %s.ToDB(\"%s\", %s)", *v.name, converterPkg, v.converter, expr))
+		g.wln("if err != nil { return err }")
+		g.wln(fmt.Sprintf("stmt.Append(%sValue)", *v.name))
+	case v.json:
+		g.wln(fmt.Sprintf("%sValue, err := json.Marshal(%s)", *v.name, expr))
+		g.wln("if err != nil { return err }")
+		g.wln(fmt.Sprintf("stmt.Append(%sValue)", *v.name))
+	case v.converted():
+		g.wln(fmt.Sprintf("stmt.Append(%s.ToNullable(%s))", dataMapperPkg, expr))
+	default:
+		g.wln(fmt.Sprintf("stmt.Append(%s)", expr))
+	}
+}
@@ -25,6 +25,7 @@ import (
 const configFileName = "config.json"
 const dataMapperPkg = "data_mapper"
 const interfacesPkg = "interfaces"
+const converterPkg = "converter"
 const generatedPkgName = "generated"
 const generatedTestPkgName = "generated_tests"
 const generatedRegistryPkg = "generated_registry"
@@ -85,6 +86,36 @@ func (g *DataMapperGenerator) GenerateAll() error {
 			return err
 		}
 		log.Println("done")
+		log.Printf("generating repository for object: %s...\n", g.config.Objects[i].Name)
+		err = g.generateRepository(g.config.Objects[i])
+		if err != nil {
+			return err
+		}
+		log.Println("done")
+	}
+	for _, h := range g.config.Hierarchies {
+		for _, v := range h.Subtypes {
+			log.Printf("generating object methods for object: %s...\n", v.Name)
+			err := g.generateObjectMethods(&v.ObjectType)
+			if err != nil {
+				return err
+			}
+			log.Println("done")
+		}
+		log.Printf("generating data mapper for hierarchy: %s...\n", h.Name)
+		err := g.generateHierarchyDataMapper(h)
+		if err != nil {
+			return err
+		}
+		log.Println("done")
+	}
+	if len(g.config.enums) > 0 {
+		log.Println("generating the enums...")
+		err := g.generateEnums()
+		if err != nil {
+			return err
+		}
+		log.Println("done")
 	}
 	if g.config.Db == nil {
 		return fmt.Errorf("the db config is not defined")
@@ -114,6 +145,15 @@ func (g *DataMapperGenerator) GenerateAll() error {
 		log.Println("done")
 	}
 
+	for _, h := range g.config.Hierarchies {
+		log.Printf("generating the %s data mapper test...", h.Name)
+		err := g.generateHierarchyTest(h)
+		if err != nil {
+			return err
+		}
+		log.Println("done")
+	}
+
 	p, err := filepath.Abs(g.caller)
 	if err != nil {
 		return err
@@ -150,6 +190,9 @@ func (c *Config) pkgData(caller string) error {
 	for _, v := range c.Objects {
 		fromConfig[v.Pkg] = v.Dir
 	}
+	for _, v := range c.Hierarchies {
+		fromConfig[v.Pkg] = v.Dir
+	}
 	fromConfig[c.Db.Pkg] = c.Db.Dir
 	for k, v := range fromConfig {
 		dir := filepath.Join(caller, v)
@@ -0,0 +1,388 @@
+package data_mapper_generator
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// the variable the discriminator of the rows of a hierarchy is scanned into.
+const discriminatorVar = "discriminator"
+
+// The fields shared by all the subtypes, the id first.
+func (h *HierarchyType) commonFields() []*ValidatedField {
+	return h.Subtypes[0].ValidatedFields[:len(h.Fields)]
+}
+
+// The fields read from the rows of the hierarchy, the shared ones followed by
+// the own fields of each subtype, these are NULL in the rows of the others.
+func (h *HierarchyType) loadFields() []*ValidatedField {
+	fields := slices.Clone(h.commonFields())
+	for _, s := range h.Subtypes {
+		for _, v := range s.own {
+			nullable := *v
+			nullable.nullable = true
+			nullable.zeroIsNull = v.converter == "" && !v.json && !isNullableType(*v.dataType)
+			fields = append(fields, &nullable)
+		}
+	}
+	return fields
+}
+
+// The discriminator value of the subtype as a SQL literal.
+func (s *SubtypeType) discriminatorLiteral() string {
+	return fmt.Sprintf("'%s'", strings.ReplaceAll(s.DiscriminatorValue, "'", "''"))
+}
+
+// The relation the rows of the hierarchy are selected from, the table of
+// the single table hierarchies, the root table joined to the tables of the
+// subtypes for class table and the union of the subtypes tables for concrete
+// table. The columns of the subqueries are named as the columns of the table.
+func (g *DataMapperGenerator) hierarchySource(h *HierarchyType) string {
+	switch h.strategy {
+	case CLASSTABLE:
+		columns := make([]string, 0)
+		for _, v := range h.commonFields() {
+			columns = append(columns, fmt.Sprintf("%s.%s", h.Table, v.column))
+		}
+		joins := make([]string, 0, len(h.Subtypes))
+		for _, s := range h.Subtypes {
+			for _, v := range s.own {
+				columns = append(columns, fmt.Sprintf("%s.%s", s.Table, v.column))
+			}
+			joins = append(joins, fmt.Sprintf("LEFT JOIN %s ON %s.%s = %s.%s",
+				s.Table, s.Table, h.idField().column, h.Table, h.idField().column))
+		}
+		columns = append(columns, fmt.Sprintf("%s.%s", h.Table, h.Discriminator))
+		return fmt.Sprintf("(SELECT %s FROM %s %s) AS %s",
+			strings.Join(columns, ", "), h.Table, strings.Join(joins, " "), h.Table)
+	case CONCRETETABLE:
+		selects := make([]string, 0, len(h.Subtypes))
+		for _, s := range h.Subtypes {
+			columns := columnsOf(h.commonFields())
+			for _, other := range h.Subtypes {
+				for _, v := range other.own {
+					if other == s {
+						columns = append(columns, v.column)
+					} else {
+						columns = append(columns, fmt.Sprintf("NULL AS %s", v.column))
+					}
+				}
+			}
+			columns = append(columns, fmt.Sprintf("%s AS %s", s.discriminatorLiteral(), h.Discriminator))
+			selects = append(selects, fmt.Sprintf("SELECT %s FROM %s", strings.Join(columns, ", "), s.Table))
+		}
+		return fmt.Sprintf("(%s) AS %s", strings.Join(selects, " UNION ALL "), h.Table)
+	default:
+		return h.Table
+	}
+}
+
+// The columns read from the source, the load fields followed by the discriminator.
+func (h *HierarchyType) columns() []string {
+	return append(columnsOf(h.loadFields()), h.Discriminator)
+}
+
+func (h *HierarchyType) idField() *ValidatedField {
+	return h.commonFields()[0]
+}
+
+func (g *DataMapperGenerator) hierarchyFindStmt(h *HierarchyType) string {
+	return fmt.Sprintf("SELECT %s FROM %s WHERE ID = $1;", strings.Join(h.columns(), ", "), g.hierarchySource(h))
+}
+
+func (g *DataMapperGenerator) hierarchyExistsStmt(h *HierarchyType) string {
+	return fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE ID = $1);", g.hierarchySource(h))
+}
+
+// The values of the fields are the parameters from the first one, in the
+// order DoInsert appends them, the shared fields followed by the own ones.
+func (g *DataMapperGenerator) subtypeInsertStmt(h *HierarchyType, s *SubtypeType) string {
+	insertInto := func(table string, columns []string, params []string) string {
+		return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ","), strings.Join(params, ","))
+	}
+	params := func(from, n int) []string {
+		params := make([]string, 0, n)
+		for i := range n {
+			params = append(params, fmt.Sprintf("$%d", from+i))
+		}
+		return params
+	}
+	common := columnsOf(h.commonFields())
+	own := columnsOf(s.own)
+	switch h.strategy {
+	case CLASSTABLE:
+		root := insertInto(h.Table, append([]string{h.Discriminator}, common...),
+			append([]string{s.discriminatorLiteral()}, params(1, len(common))...))
+		sub := insertInto(s.Table, append([]string{h.idField().column}, own...),
+			append([]string{"$1"}, params(len(common)+1, len(own))...))
+		return fmt.Sprintf("WITH root AS (%s) %s;", root, sub)
+	case CONCRETETABLE:
+		columns := append(common, own...)
+		return insertInto(s.Table, columns, params(1, len(columns))) + ";"
+	default:
+		columns := append(common, own...)
+		return insertInto(h.Table, append([]string{h.Discriminator}, columns...),
+			append([]string{s.discriminatorLiteral()}, params(1, len(columns))...)) + ";"
+	}
+}
+
+// The id is the first parameter followed by the fields flagged for update,
+// in the same order DoUpdate appends them. The subtypes without fields to
+// update rewrite their discriminator.
+func (g *DataMapperGenerator) subtypeUpdateStmt(h *HierarchyType, s *SubtypeType) string {
+	param := 2
+	sets := func(fields []*ValidatedField) []string {
+		sets := make([]string, 0, len(fields))
+		for _, v := range fields {
+			if v.update {
+				sets = append(sets, fmt.Sprintf("%s = $%d", v.column, param))
+				param++
+			}
+		}
+		return sets
+	}
+	update := func(table string, sets []string) string {
+		return fmt.Sprintf("UPDATE %s SET %s WHERE ID = $1", table, strings.Join(sets, ","))
+	}
+	switch h.strategy {
+	case CLASSTABLE:
+		root := sets(h.commonFields())
+		own := sets(s.own)
+		switch {
+		case len(root) > 0 && len(own) > 0:
+			return fmt.Sprintf("WITH root AS (%s) %s", update(h.Table, root), update(s.Table, own))
+		case len(own) > 0:
+			return update(s.Table, own)
+		case len(root) > 0:
+			return update(h.Table, root)
+		default:
+			return update(h.Table, []string{fmt.Sprintf("%s = %s", h.Discriminator, s.discriminatorLiteral())})
+		}
+	case CONCRETETABLE:
+		all := sets(s.ValidatedFields)
+		if len(all) == 0 {
+			return update(s.Table, []string{fmt.Sprintf("%s = %s", h.idField().column, h.idField().column)})
+		}
+		return update(s.Table, all)
+	default:
+		all := sets(s.ValidatedFields)
+		if len(all) == 0 {
+			all = []string{fmt.Sprintf("%s = %s", h.Discriminator, s.discriminatorLiteral())}
+		}
+		return update(h.Table, all)
+	}
+}
+
+// The class table hierarchies delete the rows of the subtypes tables before
+// the root row and the concrete table hierarchies delete from all the tables.
+func (g *DataMapperGenerator) hierarchyRemoveStmt(h *HierarchyType) string {
+	tables := make([]string, 0, len(h.Subtypes)+1)
+	switch h.strategy {
+	case CLASSTABLE:
+		for _, s := range h.Subtypes {
+			tables = append(tables, s.Table)
+		}
+		tables = append(tables, h.Table)
+	case CONCRETETABLE:
+		for _, s := range h.Subtypes {
+			tables = append(tables, s.Table)
+		}
+	default:
+		tables = append(tables, h.Table)
+	}
+	deletes := make([]string, 0, len(tables)-1)
+	for i, v := range tables[:len(tables)-1] {
+		deletes = append(deletes, fmt.Sprintf("sub%d AS (DELETE FROM %s WHERE ID = $1)", i, v))
+	}
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE ID = $1;", tables[len(tables)-1])
+	if len(deletes) > 0 {
+		stmt = fmt.Sprintf("WITH %s %s", strings.Join(deletes, ", "), stmt)
+	}
+	return stmt
+}
+
+// The object type the imports of the hierarchy data mapper are written for.
+func (h *HierarchyType) importsOf() *ObjectType {
+	o := &ObjectType{Dir: h.Dir, Lazy: true}
+	for _, s := range h.Subtypes {
+		for _, path := range s.imports {
+			if !slices.Contains(o.imports, path) {
+				o.imports = append(o.imports, path)
+			}
+		}
+	}
+	return o
+}
+
+// Writes the scan of a row of the hierarchy, its own fields are
+// scanned for all the subtypes and the discriminator last.
+func (g *DataMapperGenerator) generateHierarchyScan(h *HierarchyType, errReturn string) {
+	discriminator := &ValidatedField{name: new(string), dataType: new(string), typeName: "string"}
+	*discriminator.name = discriminatorVar
+	*discriminator.dataType = "string"
+	g.generateScan(append(h.loadFields(), discriminator), errReturn)
+}
+
+// DoLoad builds each row with the builder of the subtype of its
+// discriminator, the other write functions switch on the type of the object.
+func (g *DataMapperGenerator) generateHierarchyLoadFns(h *HierarchyType) {
+	idField := h.idField()
+	g.wln(fmt.Sprintf("DoLoad: func (resultSet pgx.Rows) (%s.DomainObject[%s],error){", interfacesPkg, idField.typeName))
+	g.generateHierarchyScan(h, "return nil, %s")
+	g.wln(fmt.Sprintf("switch %s {", discriminatorVar))
+	for _, s := range h.Subtypes {
+		g.wln(fmt.Sprintf("case \"%s\":", s.DiscriminatorValue))
+		g.wln(fmt.Sprintf("return %s.%s(", h.Pkg, s.Builder))
+		for _, v := range s.ValidatedFields {
+			g.wln(fmt.Sprintf("%s,", *v.name))
+		}
+		g.wln("), nil")
+	}
+	g.wln("}")
+	g.wln(fmt.Sprintf("return nil, fmt.Errorf(\"unknown %s %%s of the hierarchy %s\", %s)", h.Discriminator, h.Name, discriminatorVar))
+	g.wln("},")
+	g.wln(fmt.Sprintf("DoLoadLine: func(resultSet pgx.Rows, obj %s.DomainObject[%s]) error {", interfacesPkg, idField.typeName))
+	g.generateHierarchyScan(h, `return fmt.Errorf("error at doLoadLine %%w", %s)`)
+	g.wln("switch subject := obj.(type) {")
+	for _, s := range h.Subtypes {
+		g.wln(fmt.Sprintf("case *%s.%s:", h.Pkg, s.Name))
+		for _, v := range s.ValidatedFields {
+			if *v.name != "id" {
+				g.wln(fmt.Sprintf("subject.Set%s(%s)", matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper), *v.name))
+			}
+		}
+		g.wln("return nil")
+	}
+	g.wln("}")
+	g.wln("return fmt.Errorf(\"wrong type assertion\")")
+	g.wln("},")
+	g.wln(fmt.Sprintf("DoInsert: func(obj %s.DomainObject[%s], stmt *%s.PreparedStatement) error {",
+		interfacesPkg, idField.typeName, dataMapperPkg))
+	g.wln("switch subject := obj.(type) {")
+	for _, s := range h.Subtypes {
+		g.wln(fmt.Sprintf("case *%s.%s:", h.Pkg, s.Name))
+		for _, v := range s.insertFields() {
+			g.generateAppend(v, fmt.Sprintf("subject.%s()", matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper)))
+		}
+		g.wln("return nil")
+	}
+	g.wln("}")
+	g.wln("return fmt.Errorf(\"wrong type assertion\")")
+	g.wln("},")
+	g.wln(fmt.Sprintf("DoUpdate: func(obj %s.DomainObject[%s], stmt *%s.PreparedStatement) error {",
+		interfacesPkg, idField.typeName, dataMapperPkg))
+	g.wln("switch subject := obj.(type) {")
+	for _, s := range h.Subtypes {
+		g.wln(fmt.Sprintf("case *%s.%s:", h.Pkg, s.Name))
+		g.wln("stmt.Append(subject.Id())")
+		for _, v := range s.updateFields() {
+			g.generateAppend(v, fmt.Sprintf("subject.%s()", matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper)))
+		}
+		g.wln("return nil")
+	}
+	g.wln("}")
+	g.wln("return fmt.Errorf(\"wrong type assertion\")")
+	g.wln("},")
+}
+
+func (g *DataMapperGenerator) generateHierarchyDataMapper(h *HierarchyType) error {
+	g.buff.Reset()
+	newPkgPath := g.generateNewPkg(generatedPkgName, generatedPkgName)
+	g.generateImports(h.importsOf())
+	idField := h.idField()
+	g.wln(fmt.Sprintf("type %sDataMapper struct {", h.Name))
+	g.wln(fmt.Sprintf("%s.PostgreSQLDataMapper[%s.DomainObject[%s],%s]",
+		dataMapperPkg, interfacesPkg, idField.typeName, idField.typeName))
+	g.wln("}")
+	g.wln(fmt.Sprintf(
+		`func New%sDataMapper(pool *pgxpool.Pool,loadedMap map[%s]%s.DomainObject[%s],) *%sDataMapper {`,
+		h.Name, idField.typeName, interfacesPkg, idField.typeName, h.Name,
+	))
+	g.wln(fmt.Sprintf("return &%sDataMapper{", h.Name))
+	g.wln(fmt.Sprintf(
+		"PostgreSQLDataMapper: %s.PostgreSQLDataMapper[%s.DomainObject[%s],%s]{",
+		dataMapperPkg, interfacesPkg, idField.typeName, idField.typeName,
+	))
+	g.wln("Db: pool,")
+	g.wln("LoadedMap: loadedMap,")
+	g.wln(fmt.Sprintf("Table: \"%s\",", h.Table))
+	g.wln(fmt.Sprintf("FindStatement: \"%s\",", g.hierarchyFindStmt(h)))
+	g.wln(fmt.Sprintf("RemoveStatement: \"%s\",", g.hierarchyRemoveStmt(h)))
+	g.wln(fmt.Sprintf("ExistsStatement: \"%s\",", g.hierarchyExistsStmt(h)))
+	g.wln(fmt.Sprintf("SubtypeStatements: map[reflect.Type]%s.SubtypeStatements{", dataMapperPkg))
+	for _, s := range h.Subtypes {
+		g.wln(fmt.Sprintf("reflect.TypeOf(&%s.%s{}): {", h.Pkg, s.Name))
+		g.wln(fmt.Sprintf("InsertStatement: \"%s\",", g.subtypeInsertStmt(h, s)))
+		g.wln(fmt.Sprintf("UpdateStatement: \"%s\",", g.subtypeUpdateStmt(h, s)))
+		g.wln("},")
+	}
+	g.wln("},")
+	g.generateHierarchyLoadFns(h)
+	g.wln(fmt.Sprintf("DomainType: reflect.TypeOf((*%s.%s)(nil)).Elem(),", h.Pkg, h.Name))
+	g.wln("},")
+	g.wln("}")
+	g.wln("}")
+	columns := make([]string, 0)
+	for _, v := range h.columns() {
+		columns = append(columns, fmt.Sprintf("\"%s\"", v))
+	}
+	g.wln(fmt.Sprintf("func New%sQuery() *query_object.QueryObject {", h.Name))
+	g.wln(fmt.Sprintf("return query_object.New(\"%s\", %s)", g.hierarchySource(h), strings.Join(columns, ", ")))
+	g.wln("}")
+	g.generateAggregates(h.Name, h.commonFields())
+	return g.writeFile(newPkgPath, h.Name, "data_mapper", "")
+}
+
+// Each subtype is inserted, found by a new data mapper that
+// builds it from its discriminator and removed, all of them
+// through the data mapper the registry resolves for their type.
+func (g *DataMapperGenerator) generateHierarchyTest(h *HierarchyType) error {
+	g.buff.Reset()
+	newPkgPath := g.generateNewPkg(generatedTestPkgName, generatedTestPkgName)
+	g.generateTestImports(h.importsOf())
+	idField := h.idField()
+	g.wln(fmt.Sprintf("func Test%sDataMapper(t *testing.T) {", h.Name))
+	g.wln("ctx := context.Background()")
+	g.wln(fmt.Sprintf("pool, err := %s.%s()", g.config.Db.Pkg, g.config.Db.Builder))
+	g.wln("if err != nil { t.Fatal(err) }")
+	g.wln(fmt.Sprintf("reg, err := %s.Instance[%s]()", generatedRegistryPkg, idField.typeName))
+	g.wln("if err != nil { t.Fatal(err) }")
+	g.wln(fmt.Sprintf("reg.Register(%s.New%sDataMapper(pool, make(map[%s]%s.DomainObject[%s],0)))",
+		generatedPkgName, h.Name, idField.typeName, interfacesPkg, idField.typeName))
+	for _, s := range h.Subtypes {
+		g.wln(fmt.Sprintf("t.Run(\"%s\", func(t *testing.T) {", s.Name))
+		g.wln(fmt.Sprintf("dataMapper, err := reg.Mapper(reflect.TypeOf(&%s.%s{}))", h.Pkg, s.Name))
+		g.wln("if err != nil { t.Fatal(err) }")
+		g.wln(fmt.Sprintf("aggregate := %s.%s(", h.Pkg, s.Builder))
+		for _, v := range s.ValidatedFields {
+			value, ok := s.testValue(v)
+			if !ok {
+				value = fmt.Sprintf("*new(%s)", v.typeName)
+			}
+			g.wln(fmt.Sprintf("%v,", value))
+		}
+		g.wln(")")
+		g.wln("id, err := dataMapper.Insert(ctx, aggregate)")
+		g.wln("if err != nil { t.Fatal(err) }")
+		g.wln(fmt.Sprintf("found, err := %s.New%sDataMapper(pool, make(map[%s]%s.DomainObject[%s],0)).Find(ctx, id)",
+			generatedPkgName, h.Name, idField.typeName, interfacesPkg, idField.typeName))
+		g.wln("if err != nil { t.Fatal(err) }")
+		g.wln(fmt.Sprintf("subject, ok := found.(*%s.%s)", h.Pkg, s.Name))
+		g.wln("if !ok { t.Fatal(AssertionError{name: \"type\", expected:reflect.TypeOf(aggregate), found:reflect.TypeOf(found)}.Error()) }")
+		for _, v := range s.ValidatedFields {
+			n := matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper)
+			g.wln(fmt.Sprintf(
+				`if !reflect.DeepEqual(subject.%s(), aggregate.%s()) {
+		t.Fatal(AssertionError{name: "%s", expected:aggregate.%s(), found:subject.%s()}.Error())
+			}`,
+				n, n, *v.name, n, n,
+			))
+		}
+		g.wln("err = dataMapper.Remove(ctx, id)")
+		g.wln("if err != nil { t.Fatal(err) }")
+		g.wln("})")
+	}
+	g.wln("}")
+	return g.writeFile(newPkgPath, h.Name, "data_mapper_test", "")
+}
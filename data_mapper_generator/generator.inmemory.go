@@ -0,0 +1,140 @@
+package data_mapper_generator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The fields whose column values are read in memory by the criteria
+// of the query objects, the values of the converters are not known.
+func (o *ObjectType) inMemoryFields() []*ValidatedField {
+	fields := make([]*ValidatedField, 0, len(o.ValidatedFields))
+	for _, v := range o.ValidatedFields {
+		if v.converter == "" {
+			fields = append(fields, v)
+		}
+	}
+	return fields
+}
+
+// The expression copying the value expr of the field as its column holds
+// it, the json fields are copied by an encoding that can fail.
+func (v *ValidatedField) copyExpr(expr string) (string, bool) {
+	switch {
+	case v.json:
+		return fmt.Sprintf("%s.CopyJSON(%s)", dataMapperPkg, expr), true
+	case strings.HasPrefix(*v.dataType, "*"):
+		return fmt.Sprintf("%s.ClonePointer(%s)", dataMapperPkg, expr), false
+	case strings.HasPrefix(*v.dataType, "[]"):
+		return fmt.Sprintf("slices.Clone(%s)", expr), false
+	default:
+		return expr, false
+	}
+}
+
+// Declares a variable named after each field holding a copy of the value
+// of the field of the object src, the id is skipped unless withId.
+func (g *DataMapperGenerator) generateCopies(o *ObjectType, src string, withId bool, errReturn string) {
+	for _, v := range o.ValidatedFields {
+		if *v.name == "id" && !withId {
+			continue
+		}
+		getter := fmt.Sprintf("%s.%s()", src, matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper))
+		expr, fails := v.copyExpr(getter)
+		if !fails {
+			g.wln(fmt.Sprintf("%s := %s", *v.name, expr))
+			continue
+		}
+		g.wln(fmt.Sprintf("%s, err := %s", *v.name, expr))
+		g.wln(fmt.Sprintf("if err != nil { %s }", fmt.Sprintf(errReturn, "err")))
+	}
+}
+
+// Writes the constructor of the in-memory data mapper of the object, it
+// replaces the data mapper in the tests of the domain without database.
+// The ids generated by the database are counted in memory. The rows are
+// copies of the objects built by the builder and the ghosts are loaded
+// by the setters, as the data mapper loads them.
+func (g *DataMapperGenerator) generateInMemoryDataMapper(o *ObjectType) {
+	var idField *ValidatedField
+	for _, v := range o.ValidatedFields {
+		if *v.name == "id" {
+			idField = v
+		}
+	}
+	if idField == nil {
+		panic(fmt.Errorf("could not find id field in the validated fields"))
+	}
+	mapperType := fmt.Sprintf("%s.InMemoryDataMapper[%s.DomainObject[%s], %s]",
+		dataMapperPkg, interfacesPkg, idField.typeName, idField.typeName)
+	g.wln(fmt.Sprintf("// New%sInMemoryDataMapper returns the data mapper keeping the %s objects", o.Name, o.Name))
+	g.wln("// in memory, to be registered in place of the data mapper in the tests.")
+	g.wln(fmt.Sprintf("func New%sInMemoryDataMapper(loadedMap map[%s]%s.DomainObject[%s]) *%s {",
+		o.Name, idField.typeName, interfacesPkg, idField.typeName, mapperType))
+	g.wln(fmt.Sprintf("return &%s{", mapperType))
+	g.wln("LoadedMap: loadedMap,")
+	g.wln(fmt.Sprintf("Table: \"%s\",", o.Table))
+	g.wln(fmt.Sprintf("DomainType: reflect.TypeOf(&%s.%s{}),", o.Pkg, o.Name))
+	g.wln(fmt.Sprintf("Columns: map[string]func(obj %s.DomainObject[%s]) any{", interfacesPkg, idField.typeName))
+	for _, v := range o.inMemoryFields() {
+		getter := fmt.Sprintf("obj.(*%s.%s).%s()", o.Pkg, o.Name, matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper))
+		if v.zeroIsNull {
+			getter = fmt.Sprintf("%s.ToNullable(%s)", dataMapperPkg, getter)
+		}
+		g.wln(fmt.Sprintf("\"%s\": func(obj %s.DomainObject[%s]) any { return %s },",
+			v.column, interfacesPkg, idField.typeName, getter))
+	}
+	g.wln("},")
+	domainObject := fmt.Sprintf("%s.DomainObject[%s]", interfacesPkg, idField.typeName)
+	g.wln(fmt.Sprintf("DoCopy: func(obj %s) (%s, error) {", domainObject, domainObject))
+	g.wln(fmt.Sprintf("subject, ok := obj.(*%s.%s)", o.Pkg, o.Name))
+	g.wln("if !ok { return nil, fmt.Errorf(\"expected %T, got %T\", subject, obj) }")
+	g.generateCopies(o, "subject", true, "return nil, %s")
+	g.wln(fmt.Sprintf("return %s.%s(", o.Pkg, o.Builder))
+	for _, v := range o.ValidatedFields {
+		g.wln(fmt.Sprintf("%s,", *v.name))
+	}
+	g.wln("), nil")
+	g.wln("},")
+	if o.Lazy {
+		g.wln("LazyLoading: true,")
+		g.wln(fmt.Sprintf("CreateGhost: %s.Create%sGhost,", o.Pkg, o.Name))
+		g.wln(fmt.Sprintf("DoLoadLine: func(row %s, obj %s) error {", domainObject, domainObject))
+		g.wln(fmt.Sprintf("source, ok := row.(*%s.%s)", o.Pkg, o.Name))
+		g.wln("if !ok { return fmt.Errorf(\"expected %T, got %T\", source, row) }")
+		g.wln(fmt.Sprintf("subject, ok := obj.(*%s.%s)", o.Pkg, o.Name))
+		g.wln("if !ok { return fmt.Errorf(\"expected %T, got %T\", subject, obj) }")
+		g.generateCopies(o, "source", false, "return %s")
+		for _, v := range o.ValidatedFields {
+			if *v.name != "id" {
+				g.wln(fmt.Sprintf("subject.Set%s(%s)", matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper), *v.name))
+			}
+		}
+		g.wln("return nil")
+		g.wln("},")
+	}
+	if o.idStrategy != ASSIGNED {
+		if o.idStrategy == DATABASE || o.idStrategy == SEQUENCE {
+			g.wln(fmt.Sprintf("NextId: %s.Sequence[%s](),", dataMapperPkg, idField.typeName))
+		} else {
+			g.wln(fmt.Sprintf("NextId: func(ctx context.Context) (%s, error) {", idField.typeName))
+			switch {
+			case o.idStrategy == UUIDV7 && *idField.dataType == "string":
+				g.wln(fmt.Sprintf("return %s.NewUUIDv7()", dataMapperPkg))
+			case o.idStrategy == UUIDV7:
+				g.wln("return uuid.NewV7()")
+			default:
+				g.wln(fmt.Sprintf("return %s.NewULID()", dataMapperPkg))
+			}
+			g.wln("},")
+		}
+		g.wln(fmt.Sprintf("DoSetId: func(obj %s.DomainObject[%s], id %s) error {",
+			interfacesPkg, idField.typeName, idField.typeName))
+		g.wln(fmt.Sprintf("subject, ok := obj.(*%s.%s)", o.Pkg, o.Name))
+		g.wln("if !ok { return fmt.Errorf(\"wrong type assertion\") }")
+		g.wln("subject.SetId(id)")
+		g.wln("return nil },")
+	}
+	g.wln("}")
+	g.wln("}")
+}
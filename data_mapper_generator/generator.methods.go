@@ -8,15 +8,21 @@ import (
 
 func (g *DataMapperGenerator) generateObjectMethodsImports(o *ObjectType) {
 	requiredImports := []string{
+		"clearly-not-a-secret-project/associations",
 		"clearly-not-a-secret-project/interfaces",
 		"clearly-not-a-secret-project/lazy_loading",
+		"context",
 		"fmt",
 		"reflect",
 	}
-	if o.Lazy && o.Pkg != g.config.RootPkg {
+	if o.recordsEvents() {
+		requiredImports = append(requiredImports, "clearly-not-a-secret-project/domain_events")
+	}
+	if (o.Lazy || o.references()) && o.Pkg != g.config.RootPkg {
 		dsPkgPath := fmt.Sprintf("%s/%s", filepath.Base(g.caller), g.config.RootDir)
 		requiredImports = append(requiredImports, dsPkgPath)
 	}
+	requiredImports = append(requiredImports, o.imports...)
 	g.wln("import (")
 	for _, v := range requiredImports {
 		g.wln(fmt.Sprintf("\"%s\"", v))
@@ -42,7 +48,7 @@ func (g *DataMapperGenerator) generateGhostImpl(o *ObjectType) {
 				loadStatus: lazy_loading.GHOST,
 			}
 		}
-		`, o.Name, *idField.dataType, *idField.dataType, o.Name))
+		`, o.Name, idField.localTypeName, idField.localTypeName, o.Name))
 	g.wln(fmt.Sprintf(`
 		func (o *%s) load() {
 			if o.IsGhost() {
@@ -87,6 +93,87 @@ func (g *DataMapperGenerator) generateGhostImpl(o *ObjectType) {
 
 }
 
+func (g *DataMapperGenerator) generateDirtyTracker(o *ObjectType) {
+	g.wln(fmt.Sprintf(`
+		func (o %s) DirtyColumns() []string {
+			return o.%s.Columns()
+		}
+		`, o.Name, dirtyColumnsField))
+	g.wln(fmt.Sprintf(`
+		func (o *%s) ClearDirtyColumns() {
+			o.%s.Clear()
+		}
+		`, o.Name, dirtyColumnsField))
+}
+
+func (g *DataMapperGenerator) generateEventRecorder(o *ObjectType) {
+	g.wln(fmt.Sprintf(`
+		func (o *%s) RecordEvent(event domain_events.Event) {
+			o.%s.Record(event)
+		}
+		`, o.Name, eventsField))
+	g.wln(fmt.Sprintf(`
+		func (o %s) PendingEvents() []domain_events.Event {
+			return o.%s.Pending()
+		}
+		`, o.Name, eventsField))
+	g.wln(fmt.Sprintf(`
+		func (o *%s) PullEvents() []domain_events.Event {
+			return o.%s.Pull()
+		}
+		`, o.Name, eventsField))
+}
+
+func (g *DataMapperGenerator) generateEventSourced(o *ObjectType) {
+	g.wln(fmt.Sprintf(`
+		func (o %s) Version() int64 {
+			return o.%s
+		}
+		`, o.Name, versionField))
+	g.wln(fmt.Sprintf(`
+		func (o *%s) SetVersion(version int64) {
+			o.%s = version
+		}
+		`, o.Name, versionField))
+	g.wln(fmt.Sprintf("func (o *%s) Apply(event domain_events.Event) error {", o.Name))
+	g.wln("switch e := event.(type) {")
+	for _, v := range o.appliers {
+		g.wln(fmt.Sprintf("case %s:", v.localTypeName))
+		g.wln(fmt.Sprintf("o.%s(e)", v.method))
+	}
+	g.wln("default:")
+	g.wln(fmt.Sprintf("return fmt.Errorf(\"the type %s can't apply the event %%s\", event.EventName())", o.Name))
+	g.wln("}")
+	g.wln("return nil")
+	g.wln("}")
+	g.wln(fmt.Sprintf(`
+		// Raise applies the event to the object and records it to be stored.
+		func (o *%s) Raise(event domain_events.Event) error {
+			err := o.Apply(event)
+			if err != nil {
+				return err
+			}
+			o.RecordEvent(event)
+			return nil
+		}
+		`, o.Name))
+}
+
+// Writes the getter of the ghost of the object referenced by the field,
+// found by the mapper registered for its type, nil if the id is zero.
+func (g *DataMapperGenerator) generateReference(o *ObjectType, v *ValidatedField) {
+	target := v.reference
+	g.wln(fmt.Sprintf("func (o *%s) %s(ctx context.Context) (interfaces.DomainObject[%s], error) {",
+		o.Name, matchFirstCh.ReplaceAllStringFunc(v.referenceName(), strings.ToUpper), v.localTypeName))
+	g.wln(fmt.Sprintf("id := o.%s()", matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper)))
+	g.wln(fmt.Sprintf("var zero %s", v.localTypeName))
+	g.wln("if id == zero {")
+	g.wln("return nil, nil")
+	g.wln("}")
+	g.wln(fmt.Sprintf("return %s.Reference(ctx, \"%s/%s.%s\", id)", g.config.RootPkg, filepath.Base(g.caller), target.Dir, target.Name))
+	g.wln("}")
+}
+
 func (g *DataMapperGenerator) generateObjectMethods(o *ObjectType) error {
 	g.buff.Reset()
 	pkg := g.generateNewPkg(o.Dir, o.Pkg)
@@ -98,21 +185,46 @@ func (g *DataMapperGenerator) generateObjectMethods(o *ObjectType) error {
 		n := matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper)
 		g.wln(fmt.Sprintf(`
 			func (o %s) %s()%s {
-		`, o.Name, n, *v.dataType))
+		`, o.Name, n, v.localTypeName))
 		if *v.name != "id" && o.Lazy {
 			g.wln("o.load()")
 		}
 		g.wln(fmt.Sprintf(`
 			return o.%s
 		}`, *v.name))
-		if *v.name != "id" {
+		if *v.name != "id" || o.idStrategy != ASSIGNED || o.upsertReturnsId() {
 			g.wln(fmt.Sprintf(`
 			func (o *%s) Set%s(%s %s) {
-				o.%s = %s
+				o.%s = %s`, o.Name, n, *v.name, v.localTypeName, *v.name, *v.name))
+			if o.PartialUpdates && v.update {
+				g.wln(fmt.Sprintf(`o.%s.Mark("%s")`, dirtyColumnsField, v.column))
 			}
-		`, o.Name, n, *v.name, *v.dataType, *v.name, *v.name))
+			g.wln("}")
 		}
 	}
+	for _, v := range o.ValidatedFields {
+		if v.reference != nil {
+			g.generateReference(o, v)
+		}
+	}
+	for _, v := range o.ManyToMany {
+		g.wln(fmt.Sprintf("func (o *%s) %s() *associations.Ids[%s] {",
+			o.Name, matchFirstCh.ReplaceAllStringFunc(v.Name, strings.ToUpper), v.localTypeName))
+		if o.Lazy {
+			g.wln("o.load()")
+		}
+		g.wln(fmt.Sprintf("return &o.%s", v.Name))
+		g.wln("}")
+	}
+	if o.PartialUpdates {
+		g.generateDirtyTracker(o)
+	}
+	if o.recordsEvents() {
+		g.generateEventRecorder(o)
+	}
+	if o.kind == EVENTSOURCED {
+		g.generateEventSourced(o)
+	}
 	err := g.writeFile(pkg, o.Name, "", "generated")
 	if err != nil {
 		return err
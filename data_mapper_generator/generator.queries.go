@@ -0,0 +1,123 @@
+package data_mapper_generator
+
+import (
+	"clearly-not-a-secret-project/data_mapper"
+	"clearly-not-a-secret-project/interfaces"
+	"context"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// the methods the generated data mappers promote from data_mapper.PostgreSQLDataMapper.
+var mapperMethods = func() []string {
+	t := reflect.TypeOf(&data_mapper.PostgreSQLDataMapper[interfaces.DomainObject[string], string]{})
+	methods := make([]string, 0, t.NumMethod())
+	for i := range t.NumMethod() {
+		methods = append(methods, t.Method(i).Name)
+	}
+	return methods
+}()
+
+func (c *Config) hasQueries() bool {
+	return slices.ContainsFunc(c.Objects, func(v *ObjectType) bool {
+		return len(v.Queries) > 0
+	})
+}
+
+// Prepares the named queries of the objects against the db of the
+// url of the config, it must be reachable when any object has queries.
+func (c *Config) prepareQueries() error {
+	if !c.hasQueries() {
+		return nil
+	}
+	if c.Db == nil || c.Db.Url == "" {
+		return fmt.Errorf("the named queries are prepared at generation time and require the url of the db")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	conn, err := pgx.Connect(ctx, c.Db.Url)
+	if err != nil {
+		return fmt.Errorf("error connecting to the db to prepare the named queries %w", err)
+	}
+	defer conn.Close(ctx)
+	for _, o := range c.Objects {
+		for _, name := range slices.Sorted(maps.Keys(o.Queries)) {
+			sd, err := conn.PgConn().Prepare(ctx, "", o.Queries[name].Sql, nil)
+			if err != nil {
+				return fmt.Errorf("error preparing the query %s of type %s %w", name, o.Name, err)
+			}
+			err = o.Queries[name].check(name, o, sd)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// The prepared query must take the declared parameters and
+// return the columns the data mapper loads in their order.
+func (q *QueryType) check(name string, o *ObjectType, sd *pgconn.StatementDescription) error {
+	if len(sd.ParamOIDs) != len(q.Parameters) {
+		return fmt.Errorf("the query %s of type %s takes %d parameters and declares %d",
+			name, o.Name, len(sd.ParamOIDs), len(q.Parameters))
+	}
+	columns := make([]string, 0, len(sd.Fields))
+	for _, v := range sd.Fields {
+		columns = append(columns, strings.ToLower(v.Name))
+	}
+	expected := make([]string, 0, len(o.ValidatedFields))
+	for _, v := range columnsOf(o.ValidatedFields) {
+		expected = append(expected, strings.ToLower(v))
+	}
+	if !slices.Equal(columns, expected) {
+		return fmt.Errorf("the query %s of type %s returns the columns %s instead of %s",
+			name, o.Name, strings.Join(columns, ", "), strings.Join(expected, ", "))
+	}
+	return nil
+}
+
+// Writes a method of the data mapper for each named query, it finds
+// the objects of the rows of the query as FindMany does, the query
+// is run as it's declared without being narrowed to the visible rows,
+// the query of a soft deleted type excludes the deleted rows itself.
+func (g *DataMapperGenerator) generateQueries(o *ObjectType) {
+	for _, name := range slices.Sorted(maps.Keys(o.Queries)) {
+		q := o.Queries[name]
+		params := make([]string, 0, len(q.Parameters))
+		args := make([]string, 0, len(q.Parameters))
+		for _, v := range q.Parameters {
+			params = append(params, fmt.Sprintf("%s %s", v.Name, v.typeName))
+			args = append(args, v.Name)
+		}
+		method := matchFirstCh.ReplaceAllStringFunc(name, strings.ToUpper)
+		g.wln(fmt.Sprintf("// %s finds the objects selected by the named query %s.", method, name))
+		g.wln(fmt.Sprintf("func (d *%sDataMapper) %s(%s) ([]*%s.%s, error) {",
+			o.Name, method, strings.Join(append([]string{"ctx context.Context"}, params...), ", "), o.Pkg, o.Name))
+		source := fmt.Sprintf("%s.NewStatement(%q%s)", dataMapperPkg, q.Sql, strings.Join(append([]string{""}, args...), ", "))
+		if o.SoftDelete {
+			source = fmt.Sprintf("%s.IncludingDeleted(%s)", dataMapperPkg, source)
+		}
+		g.wln(fmt.Sprintf("objs, err := d.FindMany(ctx, %s)", source))
+		g.wln("if err != nil {")
+		g.wln("return nil, err")
+		g.wln("}")
+		g.wln(fmt.Sprintf("result := make([]*%s.%s, 0, len(objs))", o.Pkg, o.Name))
+		g.wln("for _, v := range objs {")
+		g.wln(fmt.Sprintf("subject, ok := v.(*%s.%s)", o.Pkg, o.Name))
+		g.wln("if !ok {")
+		g.wln("return nil, fmt.Errorf(\"wrong type assertion\")")
+		g.wln("}")
+		g.wln("result = append(result, subject)")
+		g.wln("}")
+		g.wln("return result, nil")
+		g.wln("}")
+	}
+}
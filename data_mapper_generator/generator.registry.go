@@ -23,7 +23,7 @@ func (g *DataMapperGenerator) generateDataMapperRegistry() error {
 	pkg := g.generateNewPkg(generatedRegistryPkg, generatedRegistryPkg)
 	g.generateDataMapperRegistryImports()
 	instances := make(map[string]bool, 0)
-	for _, v := range g.config.Objects {
+	for _, v := range g.config.objectTypes() {
 		index := -1
 		for i := range v.ValidatedFields {
 			if *v.ValidatedFields[i].name == "id" {
@@ -0,0 +1,198 @@
+package data_mapper_generator
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// The signatures of the named queries and the arguments they are called with.
+func (o *ObjectType) querySignatures() ([]string, []string) {
+	signatures := make([]string, 0, len(o.Queries))
+	calls := make([]string, 0, len(o.Queries))
+	for _, name := range slices.Sorted(maps.Keys(o.Queries)) {
+		params := []string{"ctx context.Context"}
+		args := []string{"ctx"}
+		for _, v := range o.Queries[name].Parameters {
+			params = append(params, fmt.Sprintf("%s %s", v.Name, v.typeName))
+			args = append(args, v.Name)
+		}
+		method := matchFirstCh.ReplaceAllStringFunc(name, strings.ToUpper)
+		signatures = append(signatures, fmt.Sprintf("%s(%s) ([]*%s.%s, error)", method, strings.Join(params, ", "), o.Pkg, o.Name))
+		calls = append(calls, fmt.Sprintf("%s(%s)", method, strings.Join(args, ", ")))
+	}
+	return signatures, calls
+}
+
+// Writes the repository interface of the object, typed with the object and
+// its id, and its implementation on top of the mapper interface satisfied by
+// the generated data mapper and the in-memory data mapper. The pages and the
+// named queries are only found by the generated data mapper. The event
+// sourced objects are only found by id.
+func (g *DataMapperGenerator) generateRepository(o *ObjectType) error {
+	var idField *ValidatedField
+	for _, v := range o.ValidatedFields {
+		if *v.name == "id" {
+			idField = v
+		}
+	}
+	if idField == nil {
+		return fmt.Errorf("could not find id field in the validated fields")
+	}
+	g.buff.Reset()
+	newPkgPath := g.generateNewPkg(generatedPkgName, generatedPkgName)
+	g.generateImports(o)
+	objType := fmt.Sprintf("*%s.%s", o.Pkg, o.Name)
+	implName := matchFirstCh.ReplaceAllStringFunc(o.Name, strings.ToLower) + "Repository"
+	queries := o.kind != EVENTSOURCED
+	signatures, calls := o.querySignatures()
+
+	g.wln(fmt.Sprintf("// %sRepository is the collection of the %s objects used by the", o.Name, o.Name))
+	g.wln("// application services, a mock of it replaces the database in their tests.")
+	g.wln(fmt.Sprintf("type %sRepository interface {", o.Name))
+	g.wln(fmt.Sprintf("Get(ctx context.Context, id %s) (%s, error)", idField.typeName, objType))
+	g.wln(fmt.Sprintf("Add(ctx context.Context, obj %s) (%s, error)", objType, idField.typeName))
+	g.wln(fmt.Sprintf("Update(ctx context.Context, obj %s) error", objType))
+	g.wln(fmt.Sprintf("Remove(ctx context.Context, id %s) error", idField.typeName))
+	g.wln(fmt.Sprintf("Exists(ctx context.Context, id %s) (bool, error)", idField.typeName))
+	if queries {
+		g.wln(fmt.Sprintf("Find(ctx context.Context, source %s.StatementSource) ([]%s, error)", dataMapperPkg, objType))
+		g.wln(fmt.Sprintf("Matching(ctx context.Context, spec specification.Specification[%s]) ([]%s, error)", objType, objType))
+		g.wln(fmt.Sprintf("FindPage(ctx context.Context, page %s.Page) (%s.PageResult[%s], error)", dataMapperPkg, dataMapperPkg, objType))
+		g.wln(fmt.Sprintf("Count(ctx context.Context, source %s.StatementSource) (int64, error)", dataMapperPkg))
+		for _, v := range signatures {
+			g.wln(v)
+		}
+	}
+	g.wln("}")
+
+	domainObject := fmt.Sprintf("%s.DomainObject[%s]", interfacesPkg, idField.typeName)
+	g.wln(fmt.Sprintf("// %sMapper stores the %s objects of the repository, it's satisfied", o.Name, o.Name))
+	if queries {
+		g.wln(fmt.Sprintf("// by the %sDataMapper and the in-memory data mapper of the tests.", o.Name))
+	} else {
+		g.wln(fmt.Sprintf("// by the %sDataMapper.", o.Name))
+	}
+	g.wln(fmt.Sprintf("type %sMapper interface {", o.Name))
+	g.wln(fmt.Sprintf("Find(ctx context.Context, id %s) (%s, error)", idField.typeName, domainObject))
+	g.wln(fmt.Sprintf("Insert(ctx context.Context, obj %s) (%s, error)", domainObject, idField.typeName))
+	g.wln(fmt.Sprintf("Update(ctx context.Context, obj %s) error", domainObject))
+	g.wln(fmt.Sprintf("Remove(ctx context.Context, id %s) error", idField.typeName))
+	g.wln(fmt.Sprintf("Exists(ctx context.Context, id %s) (bool, error)", idField.typeName))
+	if queries {
+		g.wln(fmt.Sprintf("FindMany(ctx context.Context, source %s.StatementSource) ([]%s, error)", dataMapperPkg, domainObject))
+		g.wln(fmt.Sprintf("Count(ctx context.Context, source %s.StatementSource) (int64, error)", dataMapperPkg))
+	}
+	g.wln("}")
+	if queries {
+		g.wln("// The pages and the named queries are only found by the data mapper.")
+		g.wln(fmt.Sprintf("type %sQueries interface {", implName))
+		g.wln(fmt.Sprintf("FindPage(ctx context.Context, page %s.Page) (%s.PageResult[%s], error)",
+			dataMapperPkg, dataMapperPkg, domainObject))
+		for _, v := range signatures {
+			g.wln(v)
+		}
+		g.wln("}")
+	}
+
+	g.wln(fmt.Sprintf("type %s struct {", implName))
+	g.wln(fmt.Sprintf("mapper %sMapper", o.Name))
+	g.wln("}")
+	g.wln(fmt.Sprintf("func New%sRepository(mapper %sMapper) %sRepository {", o.Name, o.Name, o.Name))
+	g.wln(fmt.Sprintf("return &%s{mapper: mapper}", implName))
+	g.wln("}")
+	if queries {
+		g.wln(fmt.Sprintf("func (r *%s) queries() (%sQueries, error) {", implName, implName))
+		g.wln(fmt.Sprintf("mapper, ok := r.mapper.(%sQueries)", implName))
+		g.wln("if !ok {")
+		g.wln(fmt.Sprintf("return nil, fmt.Errorf(\"the mapper %%T of %s can't find pages nor run named queries\", r.mapper)", o.Name))
+		g.wln("}")
+		g.wln("return mapper, nil")
+		g.wln("}")
+	}
+
+	g.wln(fmt.Sprintf("func (r *%s) Get(ctx context.Context, id %s) (%s, error) {", implName, idField.typeName, objType))
+	g.wln("obj, err := r.mapper.Find(ctx, id)")
+	g.wln("if err != nil {")
+	g.wln("return nil, err")
+	g.wln("}")
+	g.wln(fmt.Sprintf("subject, ok := obj.(%s)", objType))
+	g.wln("if !ok {")
+	g.wln("return nil, fmt.Errorf(\"expected %T, got %T\", subject, obj)")
+	g.wln("}")
+	g.wln("return subject, nil")
+	g.wln("}")
+	g.wln(fmt.Sprintf("func (r *%s) Add(ctx context.Context, obj %s) (%s, error) {", implName, objType, idField.typeName))
+	g.wln("return r.mapper.Insert(ctx, obj)")
+	g.wln("}")
+	g.wln(fmt.Sprintf("func (r *%s) Update(ctx context.Context, obj %s) error {", implName, objType))
+	g.wln("return r.mapper.Update(ctx, obj)")
+	g.wln("}")
+	g.wln(fmt.Sprintf("func (r *%s) Remove(ctx context.Context, id %s) error {", implName, idField.typeName))
+	g.wln("return r.mapper.Remove(ctx, id)")
+	g.wln("}")
+	g.wln(fmt.Sprintf("func (r *%s) Exists(ctx context.Context, id %s) (bool, error) {", implName, idField.typeName))
+	g.wln("return r.mapper.Exists(ctx, id)")
+	g.wln("}")
+	if !queries {
+		return g.writeFile(newPkgPath, o.Name, "repository", "")
+	}
+
+	g.wln(fmt.Sprintf("func (r *%s) Find(ctx context.Context, source %s.StatementSource) ([]%s, error) {",
+		implName, dataMapperPkg, objType))
+	g.wln("objs, err := r.mapper.FindMany(ctx, source)")
+	g.wln("if err != nil {")
+	g.wln("return nil, err")
+	g.wln("}")
+	g.wln(fmt.Sprintf("return %sObjects(objs)", matchFirstCh.ReplaceAllStringFunc(o.Name, strings.ToLower)))
+	g.wln("}")
+	g.wln(fmt.Sprintf("// Matching finds the objects satisfying spec among the rows of %s.", o.Table))
+	g.wln(fmt.Sprintf("func (r *%s) Matching(ctx context.Context, spec specification.Specification[%s]) ([]%s, error) {",
+		implName, objType, objType))
+	g.wln(fmt.Sprintf("return r.Find(ctx, specification.Query(New%sQuery(), spec))", o.Name))
+	g.wln("}")
+	g.wln(fmt.Sprintf("func (r *%s) FindPage(ctx context.Context, page %s.Page) (%s.PageResult[%s], error) {",
+		implName, dataMapperPkg, dataMapperPkg, objType))
+	g.wln(fmt.Sprintf("var result %s.PageResult[%s]", dataMapperPkg, objType))
+	g.wln("mapper, err := r.queries()")
+	g.wln("if err != nil {")
+	g.wln("return result, err")
+	g.wln("}")
+	g.wln("found, err := mapper.FindPage(ctx, page)")
+	g.wln("if err != nil {")
+	g.wln("return result, err")
+	g.wln("}")
+	g.wln(fmt.Sprintf("result.Items, err = %sObjects(found.Items)", matchFirstCh.ReplaceAllStringFunc(o.Name, strings.ToLower)))
+	g.wln("if err != nil {")
+	g.wln("return result, err")
+	g.wln("}")
+	g.wln("result.Next = found.Next")
+	g.wln("return result, nil")
+	g.wln("}")
+	g.wln(fmt.Sprintf("func (r *%s) Count(ctx context.Context, source %s.StatementSource) (int64, error) {", implName, dataMapperPkg))
+	g.wln("return r.mapper.Count(ctx, source)")
+	g.wln("}")
+	for i, v := range signatures {
+		g.wln(fmt.Sprintf("func (r *%s) %s {", implName, v))
+		g.wln("mapper, err := r.queries()")
+		g.wln("if err != nil {")
+		g.wln("return nil, err")
+		g.wln("}")
+		g.wln(fmt.Sprintf("return mapper.%s", calls[i]))
+		g.wln("}")
+	}
+	g.wln(fmt.Sprintf("func %sObjects(objs []%s.DomainObject[%s]) ([]%s, error) {",
+		matchFirstCh.ReplaceAllStringFunc(o.Name, strings.ToLower), interfacesPkg, idField.typeName, objType))
+	g.wln(fmt.Sprintf("result := make([]%s, 0, len(objs))", objType))
+	g.wln("for _, v := range objs {")
+	g.wln(fmt.Sprintf("subject, ok := v.(%s)", objType))
+	g.wln("if !ok {")
+	g.wln("return nil, fmt.Errorf(\"expected %T, got %T\", subject, v)")
+	g.wln("}")
+	g.wln("result = append(result, subject)")
+	g.wln("}")
+	g.wln("return result, nil")
+	g.wln("}")
+	return g.writeFile(newPkgPath, o.Name, "repository", "")
+}
@@ -9,9 +9,13 @@ import (
 func (g *DataMapperGenerator) generateTestImports(o *ObjectType) {
 	requiredImports := []string{
 		"clearly-not-a-secret-project/interfaces",
+		"clearly-not-a-secret-project/data_mapper",
 		"testing",
 		"context",
 		"reflect",
+		"slices",
+		"clearly-not-a-secret-project/query_object",
+		"clearly-not-a-secret-project/specification",
 	}
 	registryPkg := fmt.Sprintf("%s/%s", filepath.Base(g.caller), generatedRegistryPkg)
 	dbPkg := fmt.Sprintf("%s/%s", filepath.Base(g.caller), g.config.Db.Dir)
@@ -25,6 +29,7 @@ func (g *DataMapperGenerator) generateTestImports(o *ObjectType) {
 	allImports = append(allImports, registryPkg)
 	allImports = append(allImports, generatedPkg)
 	allImports = append(allImports, requiredImports...)
+	allImports = append(allImports, o.imports...)
 	g.wln("import (")
 	for _, v := range allImports {
 		g.wln(fmt.Sprintf("\"%s\"", v))
@@ -67,7 +72,7 @@ func (g *DataMapperGenerator) generateTestFindFunc(o *ObjectType) {
 	for _, v := range o.ValidatedFields {
 		n := matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper)
 		g.wln(fmt.Sprintf(
-			`if aggregate.%s() != v.%s {
+			`if !reflect.DeepEqual(aggregate.%s(), v.%s) {
 		t.Fatal(AssertionError{name: "%s", expected:v.%s, found:aggregate.%s()}.Error())
 			}`,
 			n, n, *v.name, n, n,
@@ -110,7 +115,7 @@ func (g *DataMapperGenerator) generateTestUpdateFunc(o *ObjectType) {
 		if v.update {
 			n := matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper)
 			g.wln(fmt.Sprintf(
-				"if aggregate.%s() != v1.%s {",
+				"if !reflect.DeepEqual(aggregate.%s(), v1.%s) {",
 				n, n,
 			))
 			g.wln(fmt.Sprintf(
@@ -124,6 +129,135 @@ func (g *DataMapperGenerator) generateTestUpdateFunc(o *ObjectType) {
 	g.wln("}}}})")
 }
 
+func (g *DataMapperGenerator) generateTestUpsertFunc() {
+	g.wln("t.Run(\"Upsert\", func(t *testing.T) {")
+	g.wln("for _,v := range testData {")
+	g.wln("dbAggregate, err := dataMapper.Find(ctx, v.Id)")
+	g.wln("if err != nil { t.Fatal(err) }")
+	g.wln("id, err := dataMapper.Upsert(ctx, dbAggregate)")
+	g.wln("if err != nil { t.Fatal(err) }")
+	g.wln("if id != dbAggregate.Id() { t.Fatal(AssertionError{name: \"id\", expected:dbAggregate.Id(), found:id}.Error())}")
+	g.wln("}})")
+}
+
+// A random id is added to each association with ids of a type with test
+// values, it must be read back from the join table after the update.
+func (g *DataMapperGenerator) generateTestManyToManyFunc(o *ObjectType) {
+	g.wln("t.Run(\"ManyToMany\", func(t *testing.T) {")
+	g.wln("for _, v := range testData {")
+	g.wln("dbAggregate, err := dataMapper.Find(ctx, v.Id)")
+	g.wln("if err != nil { t.Fatal(err) }")
+	g.wln(fmt.Sprintf("aggregate, ok := dbAggregate.(*%s.%s)", o.Pkg, o.Name))
+	g.wln("if !ok { t.Fatal(\"wrong type assertion\") }")
+	for _, v := range o.ManyToMany {
+		dataType := v.typeName
+		value, ok := o.testValue(&ValidatedField{dataType: &dataType})
+		if !ok {
+			continue
+		}
+		n := matchFirstCh.ReplaceAllStringFunc(v.Name, strings.ToUpper)
+		g.wln(fmt.Sprintf("aggregate.%s().Add(%v)", n, value))
+		g.wln("err = dataMapper.Update(ctx, aggregate)")
+		g.wln("if err != nil { t.Fatal(err) }")
+		g.wln(fmt.Sprintf("%sIds, err := newMapper.Load%s(ctx, aggregate)", v.Name, n))
+		g.wln("if err != nil { t.Fatal(err) }")
+		g.wln(fmt.Sprintf("if !slices.Contains(%sIds, %v) { t.Fatal(AssertionError{name: \"%s\", expected:%v, found:%sIds}.Error()) }",
+			v.Name, value, v.Name, value, v.Name))
+	}
+	g.wln("}})")
+}
+
+// Declares the ids of the test objects and the query selecting them.
+func (g *DataMapperGenerator) generateTestQuery(o *ObjectType, idField *ValidatedField) {
+	g.wln(fmt.Sprintf("ids := make([]%s, 0, len(testData))", idField.typeName))
+	g.wln("for _, v := range testData {")
+	g.wln("ids = append(ids, v.Id)")
+	g.wln("}")
+	g.wln(fmt.Sprintf("query := %s.New%sQuery().Where(query_object.In(\"%s\", ids))", generatedPkgName, o.Name, idField.column))
+}
+
+// The test objects are read one per page following the cursors,
+// each of them must be found.
+func (g *DataMapperGenerator) generateTestFindPageFunc(idField *ValidatedField) {
+	g.wln("t.Run(\"FindPage\", func(t *testing.T) {")
+	g.wln(fmt.Sprintf("page := %s.Page{", dataMapperPkg))
+	g.wln("Source: query,")
+	g.wln("Limit: 1,")
+	g.wln(fmt.Sprintf("Sort: []query_object.Order{query_object.Asc(\"%s\")},", idField.column))
+	g.wln("}")
+	g.wln(fmt.Sprintf("found := make([]%s, 0, len(ids))", idField.typeName))
+	g.wln("for {")
+	g.wln("result, err := newMapper.FindPage(ctx, page)")
+	g.wln("if err != nil { t.Fatal(err) }")
+	g.wln("for _, v := range result.Items {")
+	g.wln("found = append(found, v.Id())")
+	g.wln("}")
+	g.wln("if result.Next == \"\" { break }")
+	g.wln("page.Cursor = result.Next")
+	g.wln("}")
+	g.wln("for _, v := range ids {")
+	g.wln("if !slices.Contains(found, v) { t.Fatal(AssertionError{name: \"id\", expected:v, found:found}.Error()) }")
+	g.wln("}")
+	g.wln("})")
+}
+
+// Each of the test objects must be streamed once.
+func (g *DataMapperGenerator) generateTestStreamFunc() {
+	g.wln("t.Run(\"Stream\", func(t *testing.T) {")
+	g.wln("count := 0")
+	g.wln("for obj, err := range newMapper.StreamDetached(ctx, query) {")
+	g.wln("if err != nil { t.Fatal(err) }")
+	g.wln("if !slices.Contains(ids, obj.Id()) { t.Fatal(AssertionError{name: \"id\", expected:ids, found:obj.Id()}.Error()) }")
+	g.wln("count++")
+	g.wln("}")
+	g.wln("if count != len(ids) { t.Fatal(AssertionError{name: \"count\", expected:len(ids), found:count}.Error()) }")
+	g.wln("})")
+}
+
+// The test objects exist and are counted without being loaded.
+func (g *DataMapperGenerator) generateTestCountFunc() {
+	g.wln("t.Run(\"Count\", func(t *testing.T) {")
+	g.wln("count, err := dataMapper.Count(ctx, query)")
+	g.wln("if err != nil { t.Fatal(err) }")
+	g.wln("if count != int64(len(ids)) { t.Fatal(AssertionError{name: \"count\", expected:len(ids), found:count}.Error()) }")
+	g.wln("for _, v := range ids {")
+	g.wln("exists, err := dataMapper.Exists(ctx, v)")
+	g.wln("if err != nil { t.Fatal(err) }")
+	g.wln("if !exists { t.Fatal(AssertionError{name: \"exists\", expected:true, found:exists}.Error()) }")
+	g.wln("}")
+	g.wln("})")
+}
+
+// The objects found by the specification of the test ids must satisfy it.
+func (g *DataMapperGenerator) generateTestSpecificationFunc(o *ObjectType) {
+	g.wln("t.Run(\"Specification\", func(t *testing.T) {")
+	g.wln(fmt.Sprintf("spec := %s.%sFields.Id.In(ids...)", generatedPkgName, o.Name))
+	g.wln(fmt.Sprintf("found, err := dataMapper.FindMany(ctx, specification.Query(%s.New%sQuery(), spec))", generatedPkgName, o.Name))
+	g.wln("if err != nil { t.Fatal(err) }")
+	g.wln("if len(found) != len(ids) { t.Fatal(AssertionError{name: \"found\", expected:len(ids), found:len(found)}.Error()) }")
+	g.wln("for _, v := range found {")
+	g.wln(fmt.Sprintf("aggregate, ok := v.(*%s.%s)", o.Pkg, o.Name))
+	g.wln("if !ok { t.Fatal(\"wrong type assertion\") }")
+	g.wln("if !spec.IsSatisfiedBy(aggregate) { t.Fatal(AssertionError{name: \"satisfied\", expected:true, found:false}.Error()) }")
+	g.wln("}")
+	g.wln("})")
+}
+
+// The repository gets the test objects and finds them by the specification of their ids.
+func (g *DataMapperGenerator) generateTestRepositoryFunc(o *ObjectType, mapper string) {
+	g.wln("t.Run(\"Repository\", func(t *testing.T) {")
+	g.wln(fmt.Sprintf("repository := %s.New%sRepository(%s)", generatedPkgName, o.Name, mapper))
+	g.wln("for _, v := range ids {")
+	g.wln("aggregate, err := repository.Get(ctx, v)")
+	g.wln("if err != nil { t.Fatal(err) }")
+	g.wln("if aggregate.Id() != v { t.Fatal(AssertionError{name: \"id\", expected:v, found:aggregate.Id()}.Error()) }")
+	g.wln("}")
+	g.wln(fmt.Sprintf("found, err := repository.Matching(ctx, %s.%sFields.Id.In(ids...))", generatedPkgName, o.Name))
+	g.wln("if err != nil { t.Fatal(err) }")
+	g.wln("if len(found) != len(ids) { t.Fatal(AssertionError{name: \"found\", expected:len(ids), found:len(found)}.Error()) }")
+	g.wln("})")
+}
+
 func (g *DataMapperGenerator) generateTestRemoveFunc() {
 	g.wln("t.Run(\"Remove\", func(t *testing.T) {")
 	g.wln("for _,v := range testData {")
@@ -132,6 +266,65 @@ func (g *DataMapperGenerator) generateTestRemoveFunc() {
 	g.wln("}})")
 }
 
+func (g *DataMapperGenerator) generateTestProjectionsFunc(o *ObjectType) {
+	g.wln("t.Run(\"RebuildProjections\", func(t *testing.T) {")
+	g.wln(fmt.Sprintf("err := newMapper.RebuildProjections(ctx, %s.New%sQuery())", generatedPkgName, o.Name))
+	g.wln("if err != nil { t.Fatal(err) }")
+	g.wln("})")
+}
+
+// The soft deleted objects are still found including the deleted
+// ones, restored and removed again.
+func (g *DataMapperGenerator) generateTestSoftDeleteFunc() {
+	g.wln("t.Run(\"SoftDelete\", func(t *testing.T) {")
+	g.wln("for _,v := range testData {")
+	g.wln("deleted, err := newMapper.FindIncludingDeleted(ctx, v.Id)")
+	g.wln("if err != nil { t.Fatal(err) }")
+	g.wln("if deleted.Id() != v.Id { t.Fatal(AssertionError{name: \"id\", expected:v.Id, found:deleted.Id()}.Error())}")
+	g.wln("err = newMapper.Restore(ctx, v.Id)")
+	g.wln("if err != nil { t.Fatal(err) }")
+	g.wln("err = dataMapper.Remove(ctx, v.Id)")
+	g.wln("if err != nil { t.Fatal(err) }")
+	g.wln("}})")
+}
+
+// The event sourced objects raise the zero value of each of their
+// events, are stored and then rebuilt by a new data mapper.
+func (g *DataMapperGenerator) generateTestEventSourcedFunc(o *ObjectType, idField *ValidatedField) {
+	g.wln("t.Run(\"Insert\", func(t *testing.T) {")
+	g.wln("for _, v := range testData {")
+	g.wln(fmt.Sprintf("aggregate := %s.%s(", o.Pkg, o.Builder))
+	for _, v := range o.ValidatedFields {
+		g.wln(fmt.Sprintf("v.%s,", matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper)))
+	}
+	g.wln(")")
+	for _, v := range o.appliers {
+		g.wln(fmt.Sprintf("if err := aggregate.Raise(%s{}); err != nil { t.Fatal(err) }", v.typeName))
+	}
+	g.wln("id, err := dataMapper.Insert(ctx, aggregate)")
+	g.wln("if err != nil { t.Fatal(err) }")
+	g.wln("if id != aggregate.Id() { t.Fatal(AssertionError{name: \"id\", expected:aggregate.Id(), found:id}.Error())}")
+	g.wln(fmt.Sprintf("if aggregate.Version() != %d { t.Fatal(AssertionError{name: \"version\", expected:%d, found:aggregate.Version()}.Error())}",
+		len(o.appliers), len(o.appliers)))
+	g.wln("}})")
+	g.wln("t.Run(\"Find\", func(t *testing.T) {")
+	g.wln(fmt.Sprintf("replaying := %s.New%sDataMapper(pool, make(map[%s]%s.DomainObject[%s],0))",
+		generatedPkgName, o.Name, idField.typeName, interfacesPkg, idField.typeName))
+	g.wln("for _, v := range testData {")
+	g.wln("dbAggregate, err := replaying.Find(ctx, v.Id)")
+	g.wln("if err != nil { t.Fatal(err) }")
+	g.wln(fmt.Sprintf("aggregate, ok := dbAggregate.(*%s.%s)", o.Pkg, o.Name))
+	g.wln("if !ok { t.Fatal(\"wrong type assertion\") }")
+	g.wln(fmt.Sprintf("if aggregate.Version() != %d { t.Fatal(AssertionError{name: \"version\", expected:%d, found:aggregate.Version()}.Error())}",
+		len(o.appliers), len(o.appliers)))
+	g.wln("}})")
+	g.wln("t.Run(\"Remove\", func(t *testing.T) {")
+	g.wln("for _, v := range testData {")
+	g.wln("err := dataMapper.Remove(ctx, v.Id)")
+	g.wln("if err == nil { t.Fatal(\"the streams of events are append-only\") }")
+	g.wln("}})")
+}
+
 func (g *DataMapperGenerator) generateTestFn(o *ObjectType) {
 	index := -1
 	variables := make(map[string]string, 0)
@@ -150,11 +343,17 @@ func (g *DataMapperGenerator) generateTestFn(o *ObjectType) {
 		o.Name,
 	))
 	g.wln("ctx := context.Background()")
+	if o.audited {
+		g.wln(fmt.Sprintf("ctx = %s.WithActor(ctx, \"%s\")", dataMapperPkg, generatedTestPkgName))
+	}
+	if o.TenantColumn != "" {
+		g.wln(fmt.Sprintf("ctx = %s.WithTenant(ctx, \"%s\")", dataMapperPkg, generatedTestPkgName))
+	}
 	g.wln(fmt.Sprintf("pool, err := %s.%s()", g.config.Db.Pkg, g.config.Db.Builder))
 	g.wln("if err != nil { t.Fatal(err) }")
 	g.wln(fmt.Sprintf(
 		"loadedMap := make(map[%s]%s.DomainObject[%s],0)",
-		*idField.dataType, interfacesPkg, *idField.dataType,
+		idField.typeName, interfacesPkg, idField.typeName,
 	))
 	g.wln(fmt.Sprintf(
 		"newMapper := %s.New%sDataMapper(pool, loadedMap)",
@@ -162,15 +361,71 @@ func (g *DataMapperGenerator) generateTestFn(o *ObjectType) {
 	))
 	g.wln(fmt.Sprintf(
 		"reg, err := %s.Instance[%s]()",
-		generatedRegistryPkg, *idField.dataType,
+		generatedRegistryPkg, idField.typeName,
 	))
 	g.wln("if err != nil { t.Fatal(err) }")
 	g.wln("reg.Register(newMapper)")
 	g.wln(fmt.Sprintf("dataMapper, err := reg.Mapper(reflect.TypeOf(&%s.%s{}))", o.Pkg, o.Name))
 	g.wln("if err != nil { t.Fatal(err) }")
+	if len(o.enums) > 0 {
+		g.wln("t.Run(\"Enums\", func(t *testing.T) {")
+		g.wln(fmt.Sprintf("err := %s.ValidateEnums(ctx, pool)", generatedPkgName))
+		g.wln("if err != nil { t.Fatal(err) }")
+		g.wln("})")
+	}
+	if o.kind == EVENTSOURCED {
+		g.generateTestEventSourcedFunc(o, idField)
+		g.wln("}")
+		return
+	}
 	g.generateTestInsertFunc(o)
 	g.generateTestFindFunc(o)
 	g.generateTestUpdateFunc(o)
+	g.generateTestQuery(o, idField)
+	g.generateTestFindPageFunc(idField)
+	g.generateTestStreamFunc()
+	g.generateTestCountFunc()
+	g.generateTestSpecificationFunc(o)
+	g.generateTestRepositoryFunc(o, "newMapper")
+	if len(o.ManyToMany) > 0 {
+		g.generateTestManyToManyFunc(o)
+	}
+	if g.upsertStmt(o) != "" {
+		g.generateTestUpsertFunc()
+	}
+	if len(o.Projections) > 0 {
+		g.generateTestProjectionsFunc(o)
+	}
+	g.generateTestRemoveFunc()
+	if o.SoftDelete {
+		g.generateTestSoftDeleteFunc()
+	}
+	g.wln("}")
+}
+
+// The subtests of the data mapper run against the in-memory
+// data mapper of the object, they need no database.
+func (g *DataMapperGenerator) generateTestInMemoryFn(o *ObjectType) {
+	var idField *ValidatedField
+	for _, v := range o.ValidatedFields {
+		if *v.name == "id" {
+			idField = v
+		}
+	}
+	if idField == nil {
+		panic(fmt.Errorf("could not find id field in the validated fields"))
+	}
+	g.wln(fmt.Sprintf("func Test%sInMemoryDataMapper(t *testing.T) {", o.Name))
+	g.wln("ctx := context.Background()")
+	g.wln(fmt.Sprintf("dataMapper := %s.New%sInMemoryDataMapper(make(map[%s]%s.DomainObject[%s],0))",
+		generatedPkgName, o.Name, idField.typeName, interfacesPkg, idField.typeName))
+	g.generateTestInsertFunc(o)
+	g.generateTestFindFunc(o)
+	g.generateTestUpdateFunc(o)
+	g.generateTestQuery(o, idField)
+	g.generateTestCountFunc()
+	g.generateTestSpecificationFunc(o)
+	g.generateTestRepositoryFunc(o, "dataMapper")
 	g.generateTestRemoveFunc()
 	g.wln("}")
 }
@@ -179,17 +434,15 @@ func (g *DataMapperGenerator) generateTestData(o *ObjectType) {
 	g.wln("var testData = map[string] struct{")
 	for _, v := range o.ValidatedFields {
 		n := matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper)
-		g.wln(fmt.Sprintf("%s %s", n, *v.dataType))
+		g.wln(fmt.Sprintf("%s %s", n, v.typeName))
 	}
 	g.wln("}{")
 	g.wln("\"valid\": {")
 	for _, v := range o.ValidatedFields {
-		var randomValue any
-		switch *v.dataType {
-		case "string":
-			randomValue = fmt.Sprintf("\"%s\"", randString(10))
-		case "int":
-			randomValue = randInt()
+		randomValue, ok := o.testValue(v)
+		if !ok {
+			// the other types are left to their zero value
+			continue
 		}
 		n := matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper)
 		g.wln(fmt.Sprintf(
@@ -203,19 +456,16 @@ func (g *DataMapperGenerator) generateTestData(o *ObjectType) {
 	for _, v := range o.ValidatedFields {
 		if v.update {
 			n := matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper)
-			g.wln(fmt.Sprintf("%s %s", n, *v.dataType))
+			g.wln(fmt.Sprintf("%s %s", n, v.typeName))
 		}
 	}
 	g.wln("}{")
 	g.wln("\"valid\": {")
 	for _, v := range o.ValidatedFields {
 		if v.update {
-			var randomValue any
-			switch *v.dataType {
-			case "string":
-				randomValue = fmt.Sprintf("\"%s\"", randString(10))
-			case "int":
-				randomValue = randInt()
+			randomValue, ok := o.testValue(v)
+			if !ok {
+				continue
 			}
 			n := matchFirstCh.ReplaceAllStringFunc(*v.name, strings.ToUpper)
 			g.wln(fmt.Sprintf(
@@ -228,14 +478,28 @@ func (g *DataMapperGenerator) generateTestData(o *ObjectType) {
 
 }
 
+// A random literal for the field, false for the types left to their zero value.
+func (o *ObjectType) testValue(v *ValidatedField) (any, bool) {
+	switch {
+	case v.enum != "":
+		return o.enumConstant(v), true
+	case *v.dataType == "string":
+		return fmt.Sprintf("\"%s\"", randString(10)), true
+	case *v.dataType == "int":
+		return randInt(), true
+	default:
+		return nil, false
+	}
+}
+
 func (g *DataMapperGenerator) generateAssertionErrorType() {
 	g.wln("type AssertionError struct {")
 	g.wln("name string")
-	g.wln("expected string")
-	g.wln("found string")
+	g.wln("expected any")
+	g.wln("found any")
 	g.wln("}")
 	g.wln("func (e AssertionError) Error() string {")
-	g.wln("return fmt.Errorf(\"err field %s: expectd %s found %s\",e.name,e.expected,e.found).Error()")
+	g.wln("return fmt.Errorf(\"err field %s: expectd %v found %v\",e.name,e.expected,e.found).Error()")
 	g.wln("}")
 }
 
@@ -259,6 +523,9 @@ func (g *DataMapperGenerator) generateTest(o *ObjectType) error {
 	g.generateTestImports(o)
 	g.generateTestData(o)
 	g.generateTestFn(o)
+	if o.kind != EVENTSOURCED {
+		g.generateTestInMemoryFn(o)
+	}
 	err := g.writeFile(newPkgPath, o.Name, "data_mapper_test", "")
 	if err != nil {
 		return err
@@ -1,9 +1,20 @@
 package data_mapper_generator
 
 import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 func TestReadConfig(t *testing.T) {
@@ -129,3 +140,916 @@ func TestDataMapperGenerator_GenerateAllTests(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func newTestObjectType(idStrategy string, fields ...FieldType) *ObjectType {
+	o := &ObjectType{
+		Name:       "DomainAggregate",
+		Type:       "aggregate",
+		Table:      "aggregate",
+		Fields:     fields,
+		Pkg:        "example_subdomain",
+		IdStrategy: idStrategy,
+	}
+	o.idStrategy, o.sequence, _ = ParseIdStrategy(idStrategy)
+	for _, v := range fields {
+		name := v.Name
+		dataType := "string"
+		if name == "id" && (o.idStrategy == DATABASE || o.idStrategy == SEQUENCE) {
+			dataType = "int64"
+		}
+		o.ValidatedFields = append(o.ValidatedFields, &ValidatedField{
+			name:       &name,
+			dataType:   &dataType,
+			typeName:   dataType,
+			update:     v.Update,
+			column:     v.Column,
+			generated:  v.Generated,
+			nullable:   v.Nullable || v.ZeroIsNull,
+			zeroIsNull: v.ZeroIsNull,
+		})
+	}
+	return o
+}
+
+func TestParseIdStrategy(t *testing.T) {
+	tests := map[string]struct {
+		strategy IdStrategy
+		sequence string
+		valid    bool
+	}{
+		"":                          {strategy: ASSIGNED, valid: true},
+		"assigned":                  {strategy: ASSIGNED, valid: true},
+		"database":                  {strategy: DATABASE, valid: true},
+		"uuidv7":                    {strategy: UUIDV7, valid: true},
+		"ulid":                      {strategy: ULID, valid: true},
+		"sequence:aggregate_id_seq": {strategy: SEQUENCE, sequence: "aggregate_id_seq", valid: true},
+		"sequence:":                 {valid: false},
+		"serial":                    {valid: false},
+	}
+	for k, v := range tests {
+		strategy, sequence, err := ParseIdStrategy(k)
+		if !v.valid {
+			if err == nil {
+				t.Fatalf("expected an error for id strategy %s", k)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strategy != v.strategy || sequence != v.sequence {
+			t.Fatalf("expected %s %s got %s %s", v.strategy, v.sequence, strategy, sequence)
+		}
+	}
+}
+
+func TestDataMapperGenerator_insertStmt(t *testing.T) {
+	g := new(DataMapperGenerator)
+	tests := map[string]struct {
+		object   *ObjectType
+		expected string
+	}{
+		"assigned": {
+			object: newTestObjectType("",
+				FieldType{Name: "id", Column: "id"},
+				FieldType{Name: "name", Column: "name", Update: true},
+			),
+			expected: "INSERT INTO aggregate (id,name) VALUES ($1,$2);",
+		},
+		"database": {
+			object: newTestObjectType("database",
+				FieldType{Name: "id", Column: "id"},
+				FieldType{Name: "name", Column: "name", Update: true},
+				FieldType{Name: "createdAt", Column: "created_at", Generated: true},
+			),
+			expected: "INSERT INTO aggregate (name) VALUES ($1) RETURNING id,created_at;",
+		},
+		"sequence": {
+			object: newTestObjectType("sequence:aggregate_id_seq",
+				FieldType{Name: "id", Column: "id"},
+				FieldType{Name: "name", Column: "name", Update: true},
+			),
+			expected: "INSERT INTO aggregate (id,name) VALUES ($1,$2);",
+		},
+	}
+	for k, v := range tests {
+		stmt := g.insertStmt(v.object)
+		if stmt != v.expected {
+			t.Fatalf("%s: expected %s got %s", k, v.expected, stmt)
+		}
+	}
+}
+
+func TestDataMapperGenerator_upsertStmt(t *testing.T) {
+	g := new(DataMapperGenerator)
+	tests := map[string]struct {
+		object   *ObjectType
+		expected string
+	}{
+		"id conflict": {
+			object: newTestObjectType("",
+				FieldType{Name: "id", Column: "id"},
+				FieldType{Name: "name", Column: "name", Update: true},
+				FieldType{Name: "kind", Column: "kind"},
+			),
+			expected: "INSERT INTO aggregate (id,name,kind) VALUES ($1,$2,$3) ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name;",
+		},
+		"nothing to update": {
+			object: newTestObjectType("",
+				FieldType{Name: "id", Column: "id"},
+				FieldType{Name: "kind", Column: "kind"},
+			),
+			expected: "INSERT INTO aggregate (id,kind) VALUES ($1,$2) ON CONFLICT (id) DO NOTHING;",
+		},
+		"database id without conflict columns": {
+			object: newTestObjectType("database",
+				FieldType{Name: "id", Column: "id"},
+				FieldType{Name: "name", Column: "name", Update: true},
+			),
+			expected: "",
+		},
+	}
+	for k, v := range tests {
+		stmt := g.upsertStmt(v.object)
+		if stmt != v.expected {
+			t.Fatalf("%s: expected %s got %s", k, v.expected, stmt)
+		}
+	}
+	o := newTestObjectType("database",
+		FieldType{Name: "id", Column: "id"},
+		FieldType{Name: "email", Column: "email"},
+		FieldType{Name: "name", Column: "name", Update: true},
+	)
+	o.ConflictColumns = []string{"email"}
+	expected := "INSERT INTO aggregate (email,name) VALUES ($1,$2) ON CONFLICT (email) DO UPDATE SET name = EXCLUDED.name RETURNING id;"
+	if stmt := g.upsertStmt(o); stmt != expected {
+		t.Fatalf("expected %s got %s", expected, stmt)
+	}
+}
+
+func TestDataMapperGenerator_upsertReturningId(t *testing.T) {
+	for _, strategy := range []string{"assigned", "uuidv7", "ulid"} {
+		t.Run(strategy, func(t *testing.T) {
+			g := &DataMapperGenerator{buff: bytes.NewBuffer(make([]byte, 0))}
+			o := newTestObjectType(strategy,
+				FieldType{Name: "id", Column: "id"},
+				FieldType{Name: "email", Column: "email"},
+				FieldType{Name: "name", Column: "name", Update: true},
+			)
+			o.ConflictColumns = []string{"email"}
+			expected := "INSERT INTO aggregate (id,email,name) VALUES ($1,$2,$3) ON CONFLICT (email) DO UPDATE SET name = EXCLUDED.name RETURNING id;"
+			if stmt := g.upsertStmt(o); stmt != expected {
+				t.Fatalf("expected %s got %s", expected, stmt)
+			}
+			g.generateIdStrategy(o)
+			code := g.buff.String()
+			for _, v := range []string{
+				"DoSetId: func(obj interfaces.DomainObject[string], id string) error {",
+				"DoUpsertReturning: func(resultSet pgx.Rows, obj interfaces.DomainObject[string]) (string, error) {",
+				"return id, nil },",
+			} {
+				if !strings.Contains(code, v) {
+					t.Fatalf("expected the generated code to contain %s got\n%s", v, code)
+				}
+			}
+		})
+	}
+	o := newTestObjectType("",
+		FieldType{Name: "id", Column: "id"},
+		FieldType{Name: "name", Column: "name", Update: true},
+	)
+	if o.upsertReturnsId() {
+		t.Fatal("expected the upsert on the id not to return it")
+	}
+}
+
+func TestDataMapperGenerator_updateStmt(t *testing.T) {
+	g := new(DataMapperGenerator)
+	o := newTestObjectType("",
+		FieldType{Name: "id", Column: "id"},
+		FieldType{Name: "kind", Column: "kind"},
+		FieldType{Name: "name", Column: "name", Update: true},
+		FieldType{Name: "status", Column: "status", Update: true},
+	)
+	expected := "UPDATE aggregate SET name = $2,status = $3 WHERE ID = $1"
+	if stmt := g.updateStmt(o); stmt != expected {
+		t.Fatalf("expected %s got %s", expected, stmt)
+	}
+}
+
+func TestDataMapperGenerator_softDeleteStmts(t *testing.T) {
+	g := new(DataMapperGenerator)
+	o := newTestObjectType("",
+		FieldType{Name: "id", Column: "id"},
+		FieldType{Name: "name", Column: "name", Update: true},
+	)
+	o.SoftDelete = true
+	tests := map[string]struct {
+		stmt     string
+		expected string
+	}{
+		"find":                   {g.findStmt(o), "SELECT id, name FROM aggregate WHERE ID = $1 AND deleted_at IS NULL;"},
+		"find including deleted": {g.findIncludingDeletedStmt(o), "SELECT id, name FROM aggregate WHERE ID = $1;"},
+		"remove":                 {g.removeStmt(o), "UPDATE aggregate SET deleted_at = now() WHERE ID = $1 AND deleted_at IS NULL;"},
+		"restore":                {g.restoreStmt(o), "UPDATE aggregate SET deleted_at = NULL WHERE ID = $1;"},
+		"upsert": {g.upsertStmt(o), "INSERT INTO aggregate (id,name) VALUES ($1,$2) " +
+			"ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name,deleted_at = NULL;"},
+		"exists": {g.existsStmt(o), "SELECT EXISTS (SELECT 1 FROM aggregate WHERE ID = $1 AND deleted_at IS NULL);"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tt.stmt != tt.expected {
+				t.Fatalf("expected %s got %s", tt.expected, tt.stmt)
+			}
+		})
+	}
+}
+
+func TestDataMapperGenerator_auditStmts(t *testing.T) {
+	g := new(DataMapperGenerator)
+	o := newTestObjectType("",
+		FieldType{Name: "id", Column: "id"},
+		FieldType{Name: "name", Column: "name", Update: true},
+	)
+	o.audited = true
+	tests := map[string]struct {
+		stmt     string
+		expected string
+	}{
+		"insert": {g.insertStmt(o), "INSERT INTO aggregate (id,name,created_at,created_by,updated_at,updated_by) VALUES ($1,$2,$3,$4,$5,$6);"},
+		"update": {g.updateStmt(o), "UPDATE aggregate SET name = $2,updated_at = $3,updated_by = $4 WHERE ID = $1"},
+		"upsert": {g.upsertStmt(o), "INSERT INTO aggregate (id,name,created_at,created_by,updated_at,updated_by) VALUES ($1,$2,$3,$4,$5,$6) " +
+			"ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name,updated_at = EXCLUDED.updated_at,updated_by = EXCLUDED.updated_by;"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tt.stmt != tt.expected {
+				t.Fatalf("expected %s got %s", tt.expected, tt.stmt)
+			}
+		})
+	}
+}
+
+func TestDataMapperGenerator_tenantStmts(t *testing.T) {
+	g := new(DataMapperGenerator)
+	o := newTestObjectType("",
+		FieldType{Name: "id", Column: "id"},
+		FieldType{Name: "name", Column: "name", Update: true},
+	)
+	o.TenantColumn = "tenant_id"
+	tests := map[string]struct {
+		stmt     string
+		expected string
+	}{
+		"find":   {g.findStmt(o), "SELECT id, name FROM aggregate WHERE ID = $1 AND tenant_id = $2;"},
+		"insert": {g.insertStmt(o), "INSERT INTO aggregate (id,name,tenant_id) VALUES ($1,$2,$3);"},
+		"update": {g.updateStmt(o), "UPDATE aggregate SET name = $2 WHERE ID = $1 AND tenant_id = $3"},
+		"remove": {g.removeStmt(o), "DELETE FROM aggregate WHERE ID = $1 AND tenant_id = $2;"},
+		"exists": {g.existsStmt(o), "SELECT EXISTS (SELECT 1 FROM aggregate WHERE ID = $1 AND tenant_id = $2);"},
+		"upsert": {g.upsertStmt(o), "INSERT INTO aggregate (id,name,tenant_id) VALUES ($1,$2,$3) " +
+			"ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name WHERE aggregate.tenant_id = EXCLUDED.tenant_id RETURNING id;"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tt.stmt != tt.expected {
+				t.Fatalf("expected %s got %s", tt.expected, tt.stmt)
+			}
+		})
+	}
+}
+
+func TestEnumOf(t *testing.T) {
+	src := `package states
+
+type Status int
+
+const (
+	Draft Status = iota
+	InReview
+	Published
+)
+
+type Color string
+
+const (
+	Red   Color = "red"
+	Green Color = "green"
+	other Color = "other"
+)
+
+type Point struct{ X int }
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "states.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := new(types.Config).Check("states", fset, []*ast.File{file}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		typeName  string
+		constants []string
+		labels    []string
+		ddl       string
+		err       bool
+	}{
+		"int": {
+			typeName:  "Status",
+			constants: []string{"states.Draft", "states.InReview", "states.Published"},
+			labels:    []string{"draft", "in_review", "published"},
+			ddl:       "CREATE TYPE status AS ENUM ('draft', 'in_review', 'published');",
+		},
+		"string": {
+			typeName:  "Color",
+			constants: []string{"states.Red", "states.Green"},
+			labels:    []string{"red", "green"},
+			ddl:       "CREATE TYPE status AS ENUM ('red', 'green');",
+		},
+		"struct": {
+			typeName: "Point",
+			err:      true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			enum, err := enumOf("status", pkg.Scope().Lookup(tt.typeName).Type())
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(enum.constants, tt.constants) {
+				t.Fatalf("expected %v got %v", tt.constants, enum.constants)
+			}
+			if !slices.Equal(enum.labels, tt.labels) {
+				t.Fatalf("expected %v got %v", tt.labels, enum.labels)
+			}
+			if enum.ddl() != tt.ddl {
+				t.Fatalf("expected %s got %s", tt.ddl, enum.ddl())
+			}
+		})
+	}
+}
+
+func TestObjectType_validEventSourced(t *testing.T) {
+	src := `package accounts
+
+type Deposited struct{ Amount int }
+
+func (Deposited) EventName() string { return "Deposited" }
+
+type Closed struct{}
+
+type Account struct{ balance int }
+
+func (a *Account) applyDeposited(e Deposited) { a.balance += e.Amount }
+
+type Ledger struct{}
+
+func (l *Ledger) applyClosed(e Closed) {}
+
+type Journal struct{}
+
+func (j *Journal) applyDeposited(e Deposited) error { return nil }
+
+type Empty struct{}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "accounts.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := new(types.Config).Check("accounts", fset, []*ast.File{file}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		object   ObjectType
+		appliers []string
+		err      bool
+	}{
+		"valid": {
+			object:   ObjectType{Name: "Account", Lazy: true},
+			appliers: []string{"accounts.Deposited"},
+		},
+		"not lazy": {
+			object: ObjectType{Name: "Account"},
+			err:    true,
+		},
+		"not an event": {
+			object: ObjectType{Name: "Ledger", Lazy: true},
+			err:    true,
+		},
+		"applier with result": {
+			object: ObjectType{Name: "Journal", Lazy: true},
+			err:    true,
+		},
+		"no appliers": {
+			object: ObjectType{Name: "Empty", Lazy: true},
+			err:    true,
+		},
+		"soft delete": {
+			object: ObjectType{Name: "Account", Lazy: true, SoftDelete: true},
+			err:    true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := tt.object
+			err := o.validEventSourced(pkg.Scope().Lookup(o.Name), pkg)
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			appliers := make([]string, 0, len(o.appliers))
+			for _, v := range o.appliers {
+				appliers = append(appliers, v.typeName)
+			}
+			if !slices.Equal(appliers, tt.appliers) {
+				t.Fatalf("expected %v got %v", tt.appliers, appliers)
+			}
+		})
+	}
+}
+
+func newTestHierarchyType(strategy InheritanceStrategy) *HierarchyType {
+	h := &HierarchyType{
+		Name:          "Payment",
+		Table:         "payment",
+		Discriminator: "kind",
+		Fields: []FieldType{
+			{Name: "id", Column: "id"},
+			{Name: "amount", Column: "amount", Update: true},
+		},
+		strategy: strategy,
+	}
+	subtype := func(name, table, value string, own FieldType) *SubtypeType {
+		o := newTestObjectType("", append(slices.Clone(h.Fields), own)...)
+		o.Name = name
+		o.Table = table
+		return &SubtypeType{
+			ObjectType:         *o,
+			DiscriminatorValue: value,
+			own:                o.ValidatedFields[len(h.Fields):],
+		}
+	}
+	h.Subtypes = []*SubtypeType{
+		subtype("CardPayment", "card_payment", "card", FieldType{Name: "card", Column: "card"}),
+		subtype("BankTransfer", "bank_transfer", "bank", FieldType{Name: "iban", Column: "iban", Update: true}),
+	}
+	return h
+}
+
+func TestDataMapperGenerator_hierarchyStmts(t *testing.T) {
+	g := new(DataMapperGenerator)
+	tests := map[string]struct {
+		strategy InheritanceStrategy
+		find     string
+		insert   string
+		update   string
+		remove   string
+	}{
+		"single table": {
+			strategy: SINGLETABLE,
+			find:     "SELECT id, amount, card, iban, kind FROM payment WHERE ID = $1;",
+			insert:   "INSERT INTO payment (kind,id,amount,card) VALUES ('card',$1,$2,$3);",
+			update:   "UPDATE payment SET amount = $2,iban = $3 WHERE ID = $1",
+			remove:   "DELETE FROM payment WHERE ID = $1;",
+		},
+		"class table": {
+			strategy: CLASSTABLE,
+			find: "SELECT id, amount, card, iban, kind FROM (SELECT payment.id, payment.amount, card_payment.card, " +
+				"bank_transfer.iban, payment.kind FROM payment LEFT JOIN card_payment ON card_payment.id = payment.id " +
+				"LEFT JOIN bank_transfer ON bank_transfer.id = payment.id) AS payment WHERE ID = $1;",
+			insert: "WITH root AS (INSERT INTO payment (kind,id,amount) VALUES ('card',$1,$2)) " +
+				"INSERT INTO card_payment (id,card) VALUES ($1,$3);",
+			update: "WITH root AS (UPDATE payment SET amount = $2 WHERE ID = $1) UPDATE bank_transfer SET iban = $3 WHERE ID = $1",
+			remove: "WITH sub0 AS (DELETE FROM card_payment WHERE ID = $1), sub1 AS (DELETE FROM bank_transfer WHERE ID = $1) " +
+				"DELETE FROM payment WHERE ID = $1;",
+		},
+		"concrete table": {
+			strategy: CONCRETETABLE,
+			find: "SELECT id, amount, card, iban, kind FROM (SELECT id, amount, card, NULL AS iban, 'card' AS kind FROM card_payment " +
+				"UNION ALL SELECT id, amount, NULL AS card, iban, 'bank' AS kind FROM bank_transfer) AS payment WHERE ID = $1;",
+			insert: "INSERT INTO card_payment (id,amount,card) VALUES ($1,$2,$3);",
+			update: "UPDATE bank_transfer SET amount = $2,iban = $3 WHERE ID = $1",
+			remove: "WITH sub0 AS (DELETE FROM card_payment WHERE ID = $1) DELETE FROM bank_transfer WHERE ID = $1;",
+		},
+	}
+	for k, v := range tests {
+		h := newTestHierarchyType(v.strategy)
+		if stmt := g.hierarchyFindStmt(h); stmt != v.find {
+			t.Fatalf("%s: expected %s got %s", k, v.find, stmt)
+		}
+		if stmt := g.subtypeInsertStmt(h, h.Subtypes[0]); stmt != v.insert {
+			t.Fatalf("%s: expected %s got %s", k, v.insert, stmt)
+		}
+		if stmt := g.subtypeUpdateStmt(h, h.Subtypes[1]); stmt != v.update {
+			t.Fatalf("%s: expected %s got %s", k, v.update, stmt)
+		}
+		if stmt := g.hierarchyRemoveStmt(h); stmt != v.remove {
+			t.Fatalf("%s: expected %s got %s", k, v.remove, stmt)
+		}
+	}
+	h := newTestHierarchyType(CLASSTABLE)
+	expected := "UPDATE payment SET amount = $2 WHERE ID = $1"
+	if stmt := g.subtypeUpdateStmt(h, h.Subtypes[0]); stmt != expected {
+		t.Fatalf("expected %s got %s", expected, stmt)
+	}
+	h = newTestHierarchyType(SINGLETABLE)
+	expected = "SELECT EXISTS (SELECT 1 FROM payment WHERE ID = $1);"
+	if stmt := g.hierarchyExistsStmt(h); stmt != expected {
+		t.Fatalf("expected %s got %s", expected, stmt)
+	}
+}
+
+func TestManyToManyType_validField(t *testing.T) {
+	src := `package tags
+
+import "clearly-not-a-secret-project/associations"
+
+type Post struct {
+	tags  associations.Ids[string]
+	names []string
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "tags.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := (&types.Config{Importer: importer.ForCompiler(fset, "source", nil)}).Check("tags", fset, []*ast.File{file}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := pkg.Scope().Lookup("Post").Type().Underlying().(*types.Struct)
+	tests := map[string]struct {
+		field  int
+		object ObjectType
+		err    bool
+	}{
+		"valid": {
+			field:  0,
+			object: ObjectType{Name: "Post"},
+		},
+		"not ids": {
+			field:  1,
+			object: ObjectType{Name: "Post"},
+			err:    true,
+		},
+		"event sourced": {
+			field:  0,
+			object: ObjectType{Name: "Post", kind: EVENTSOURCED},
+			err:    true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := &ManyToManyType{Name: "tags", JoinTable: "post_tag", OwnerColumn: "post_id", TargetColumn: "tag_id"}
+			err := m.validField(&tt.object, post.Field(tt.field).Type(), pkg)
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.typeName != "string" {
+				t.Fatalf("expected the ids to be strings got %s", m.typeName)
+			}
+		})
+	}
+}
+
+func TestValidatedField_validReference(t *testing.T) {
+	entity := newTestObjectType("", FieldType{Name: "id", Column: "id"})
+	entity.Name = "DomainEntity"
+	entity.Lazy = true
+	eager := newTestObjectType("", FieldType{Name: "id", Column: "id"})
+	eager.Name = "Eager"
+	objects := []*ObjectType{entity, eager}
+	tests := map[string]struct {
+		field FieldType
+		err   bool
+	}{
+		"valid": {
+			field: FieldType{Name: "entityId", Column: "entity_id", Reference: "DomainEntity"},
+		},
+		"without the id suffix": {
+			field: FieldType{Name: "entity", Column: "entity_id", Reference: "DomainEntity"},
+			err:   true,
+		},
+		"unknown type": {
+			field: FieldType{Name: "entityId", Column: "entity_id", Reference: "Missing"},
+			err:   true,
+		},
+		"not lazy": {
+			field: FieldType{Name: "eagerId", Column: "eager_id", Reference: "Eager"},
+			err:   true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := newTestObjectType("", FieldType{Name: "id", Column: "id"}, tt.field)
+			field := o.ValidatedFields[1]
+			field.references = tt.field.Reference
+			err := field.validReference(o, objects)
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if field.reference != entity || field.referenceName() != "entity" {
+				t.Fatalf("unexpected reference %v %s", field.reference, field.referenceName())
+			}
+		})
+	}
+}
+
+func TestDataMapperGenerator_generateReference(t *testing.T) {
+	entity := newTestObjectType("", FieldType{Name: "id", Column: "id"})
+	entity.Name = "DomainEntity"
+	entity.Dir = "example_models"
+	g := &DataMapperGenerator{
+		caller: "/src/example",
+		config: &Config{RootPkg: "example_models"},
+		buff:   bytes.NewBuffer(make([]byte, 0)),
+	}
+	o := newTestObjectType("", FieldType{Name: "id", Column: "id"}, FieldType{Name: "entityId", Column: "entity_id"})
+	field := o.ValidatedFields[1]
+	field.reference = entity
+	field.localTypeName = "string"
+	g.generateReference(o, field)
+	code := g.buff.String()
+	for _, v := range []string{
+		"func (o *DomainAggregate) Entity(ctx context.Context) (interfaces.DomainObject[string], error) {",
+		`return example_models.Reference(ctx, "example/example_models.DomainEntity", id)`,
+	} {
+		if !strings.Contains(code, v) {
+			t.Fatalf("expected the generated code to contain %s got\n%s", v, code)
+		}
+	}
+}
+
+func TestObjectType_sortFields(t *testing.T) {
+	o := newTestObjectType("",
+		FieldType{Name: "id", Column: "id"},
+		FieldType{Name: "name", Column: "name"},
+		FieldType{Name: "nickname", Column: "nickname"},
+		FieldType{Name: "attributes", Column: "attributes"},
+		FieldType{Name: "tags", Column: "tags"},
+	)
+	o.ValidatedFields[2].nullable = true
+	o.ValidatedFields[3].json = true
+	tags := "[]string"
+	o.ValidatedFields[4].dataType = &tags
+	columns := columnsOf(o.sortFields())
+	if !slices.Equal(columns, []string{"id", "name"}) {
+		t.Fatalf("expected the id and name columns got %v", columns)
+	}
+}
+
+func TestValidatedField_validNullable(t *testing.T) {
+	o := newTestObjectType("",
+		FieldType{Name: "id", Column: "id"},
+		FieldType{Name: "name", Column: "name", Nullable: true},
+		FieldType{Name: "nickname", Column: "nickname", ZeroIsNull: true},
+		FieldType{Name: "email", Column: "email", Nullable: true},
+		FieldType{Name: "phone", Column: "phone", ZeroIsNull: true},
+	)
+	pointer := "*string"
+	o.ValidatedFields[3].dataType = &pointer
+	o.ValidatedFields[4].dataType = &pointer
+	for i, valid := range []bool{true, false, true, true, false} {
+		v := o.ValidatedFields[i]
+		if err := v.validNullable(); (err == nil) != valid {
+			t.Fatalf("expected the field %s to be valid %t got %v", *v.name, valid, err)
+		}
+	}
+	if !o.ValidatedFields[2].converted() || o.ValidatedFields[3].converted() {
+		t.Fatal("expected only the zero value of nickname to be converted to NULL")
+	}
+}
+
+func TestDataMapperGenerator_generateAggregates(t *testing.T) {
+	g := &DataMapperGenerator{buff: bytes.NewBuffer(make([]byte, 0))}
+	o := newTestObjectType("",
+		FieldType{Name: "id", Column: "id"},
+		FieldType{Name: "name", Column: "name"},
+		FieldType{Name: "amount", Column: "amount"},
+	)
+	amount := "int64"
+	o.ValidatedFields[1].aggregate = true
+	o.ValidatedFields[2].aggregate = true
+	o.ValidatedFields[2].dataType = &amount
+	o.ValidatedFields[2].typeName = amount
+	g.generateAggregates(o.Name, o.ValidatedFields)
+	code := g.buff.String()
+	for _, v := range []string{
+		"func (d *DomainAggregateDataMapper) SumAmount(ctx context.Context, source data_mapper.StatementSource) (int64, error) {",
+		"func (d *DomainAggregateDataMapper) MinAmount(ctx context.Context, source data_mapper.StatementSource) (int64, error) {",
+		"func (d *DomainAggregateDataMapper) MaxName(ctx context.Context, source data_mapper.StatementSource) (string, error) {",
+		`err := d.Aggregate(ctx, source, query_object.Sum("amount"), &value)`,
+	} {
+		if !strings.Contains(code, v) {
+			t.Fatalf("expected the generated code to contain %s got\n%s", v, code)
+		}
+	}
+	if strings.Contains(code, "SumName") || strings.Contains(code, "MinId") {
+		t.Fatalf("unexpected aggregates generated\n%s", code)
+	}
+}
+
+func TestDataMapperGenerator_generateSpecificationFields(t *testing.T) {
+	g := &DataMapperGenerator{buff: bytes.NewBuffer(make([]byte, 0))}
+	o := newTestObjectType("",
+		FieldType{Name: "id", Column: "id"},
+		FieldType{Name: "createdAt", Column: "created_at"},
+		FieldType{Name: "attributes", Column: "attributes"},
+	)
+	createdAt := "time.Time"
+	o.ValidatedFields[1].dataType = &createdAt
+	o.ValidatedFields[1].typeName = createdAt
+	o.ValidatedFields[2].json = true
+	g.generateSpecificationFields(o)
+	code := g.buff.String()
+	for _, v := range []string{
+		"Id specification.OrderedField[*example_subdomain.DomainAggregate, string]",
+		"CreatedAt specification.Field[*example_subdomain.DomainAggregate, time.Time]",
+		`CreatedAt: specification.NewField("created_at", (*example_subdomain.DomainAggregate).CreatedAt),`,
+	} {
+		if !strings.Contains(code, v) {
+			t.Fatalf("expected the generated code to contain %s got\n%s", v, code)
+		}
+	}
+	if strings.Contains(code, "Attributes") {
+		t.Fatalf("unexpected json field generated\n%s", code)
+	}
+}
+
+func TestParameterType(t *testing.T) {
+	tests := map[string]struct {
+		typeName string
+		path     string
+		err      bool
+	}{
+		"string":                       {typeName: "string"},
+		"[]int64":                      {typeName: "[]int64"},
+		"*time.Time":                   {typeName: "*time.Time", path: "time"},
+		"github.com/google/uuid.UUID":  {typeName: "uuid.UUID", path: "github.com/google/uuid"},
+		"Customer":                     {err: true},
+		"github.com/google/uuid.uuid":  {err: true},
+		"[]github.com/google/uuid.New": {typeName: "[]uuid.New", path: "github.com/google/uuid"},
+	}
+	for k, v := range tests {
+		typeName, path, err := parameterType(k)
+		if (err != nil) != v.err {
+			t.Fatalf("%s: unexpected error %v", k, err)
+		}
+		if typeName != v.typeName || path != v.path {
+			t.Fatalf("%s: expected %s %s got %s %s", k, v.typeName, v.path, typeName, path)
+		}
+	}
+}
+
+func TestQueryType(t *testing.T) {
+	o := newTestObjectType("",
+		FieldType{Name: "id", Column: "id"},
+		FieldType{Name: "email", Column: "email"},
+	)
+	sql := "SELECT id, email FROM aggregate WHERE email = $1 AND created_at > $2;"
+	q := &QueryType{Sql: sql, Parameters: []ParameterType{
+		{Name: "email", Type: "string"},
+		{Name: "since", Type: "time.Time"},
+	}}
+	err := q.valid("findByEmail", o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	invalid := map[string]*QueryType{
+		"FindMany":    {Sql: sql},
+		"find by":     {Sql: sql},
+		"findNothing": {},
+		"findByErr":   {Sql: sql, Parameters: []ParameterType{{Name: "err", Type: "string"}}},
+		"findByTwice": {Sql: sql, Parameters: []ParameterType{{Name: "a", Type: "string"}, {Name: "a", Type: "string"}}},
+	}
+	for k, v := range invalid {
+		if v.valid(k, o) == nil {
+			t.Fatalf("expected the query %s to be invalid", k)
+		}
+	}
+	sd := &pgconn.StatementDescription{
+		ParamOIDs: []uint32{25, 1184},
+		Fields:    []pgconn.FieldDescription{{Name: "id"}, {Name: "email"}},
+	}
+	if err = q.check("findByEmail", o, sd); err != nil {
+		t.Fatal(err)
+	}
+	sd.Fields = sd.Fields[1:]
+	if q.check("findByEmail", o, sd) == nil {
+		t.Fatal("expected an error for a query missing the id column")
+	}
+	o.Queries = map[string]*QueryType{"findByEmail": q}
+	g := &DataMapperGenerator{buff: bytes.NewBuffer(make([]byte, 0))}
+	g.generateQueries(o)
+	code := g.buff.String()
+	for _, v := range []string{
+		"func (d *DomainAggregateDataMapper) FindByEmail(ctx context.Context, email string, since time.Time) ([]*example_subdomain.DomainAggregate, error) {",
+		fmt.Sprintf("objs, err := d.FindMany(ctx, data_mapper.NewStatement(%q, email, since))", sql),
+	} {
+		if !strings.Contains(code, v) {
+			t.Fatalf("expected the generated code to contain %s got\n%s", v, code)
+		}
+	}
+	o.SoftDelete = true
+	g.buff.Reset()
+	g.generateQueries(o)
+	expected := fmt.Sprintf("objs, err := d.FindMany(ctx, data_mapper.IncludingDeleted(data_mapper.NewStatement(%q, email, since)))", sql)
+	if !strings.Contains(g.buff.String(), expected) {
+		t.Fatalf("expected the query of a soft deleted type to include the deleted rows got\n%s", g.buff.String())
+	}
+}
+
+func TestObjectType_querySignatures(t *testing.T) {
+	o := newTestObjectType("",
+		FieldType{Name: "id", Column: "id"},
+		FieldType{Name: "email", Column: "email"},
+	)
+	o.Queries = map[string]*QueryType{
+		"findByEmail": {Sql: "SELECT id, email FROM aggregate WHERE email = $1;", Parameters: []ParameterType{
+			{Name: "email", Type: "string"},
+		}},
+		"findAll": {Sql: "SELECT id, email FROM aggregate;"},
+	}
+	for k, v := range o.Queries {
+		if err := v.valid(k, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	signatures, calls := o.querySignatures()
+	expected := []string{
+		"FindAll(ctx context.Context) ([]*example_subdomain.DomainAggregate, error)",
+		"FindByEmail(ctx context.Context, email string) ([]*example_subdomain.DomainAggregate, error)",
+	}
+	if !slices.Equal(signatures, expected) {
+		t.Fatalf("expected the signatures %v got %v", expected, signatures)
+	}
+	if !slices.Equal(calls, []string{"FindAll(ctx)", "FindByEmail(ctx, email)"}) {
+		t.Fatalf("unexpected calls %v", calls)
+	}
+}
+
+func TestDataMapperGenerator_generateInMemoryDataMapper(t *testing.T) {
+	g := &DataMapperGenerator{buff: bytes.NewBuffer(make([]byte, 0))}
+	o := newTestObjectType("sequence:aggregate_id_seq",
+		FieldType{Name: "id", Column: "id"},
+		FieldType{Name: "email", Column: "email", ZeroIsNull: true},
+		FieldType{Name: "price", Column: "price"},
+		FieldType{Name: "tags", Column: "tags", Update: true},
+		FieldType{Name: "attributes", Column: "attributes", Update: true},
+	)
+	o.Builder = "NewDomainAggregate"
+	o.Lazy = true
+	o.ValidatedFields[2].converter = "money"
+	tags := "[]string"
+	o.ValidatedFields[3].dataType = &tags
+	o.ValidatedFields[4].json = true
+	g.generateInMemoryDataMapper(o)
+	code := g.buff.String()
+	for _, v := range []string{
+		"func NewDomainAggregateInMemoryDataMapper(loadedMap map[int64]interfaces.DomainObject[int64]) *data_mapper.InMemoryDataMapper[interfaces.DomainObject[int64], int64] {",
+		`"email": func(obj interfaces.DomainObject[int64]) any { return data_mapper.ToNullable(obj.(*example_subdomain.DomainAggregate).Email()) },`,
+		"NextId: data_mapper.Sequence[int64](),",
+		"subject.SetId(id)",
+		"tags := slices.Clone(subject.Tags())",
+		"attributes, err := data_mapper.CopyJSON(subject.Attributes())",
+		"return example_subdomain.NewDomainAggregate(",
+		"CreateGhost: example_subdomain.CreateDomainAggregateGhost,",
+		"tags := slices.Clone(source.Tags())",
+		"subject.SetTags(tags)",
+	} {
+		if !strings.Contains(code, v) {
+			t.Fatalf("expected the generated code to contain %s got\n%s", v, code)
+		}
+	}
+	if strings.Contains(code, `"price"`) {
+		t.Fatalf("unexpected converted column read in memory\n%s", code)
+	}
+}
@@ -0,0 +1,23 @@
+package dirty_tracking
+
+import "slices"
+
+// DirtyColumns is the set of columns changed since the
+// object was loaded or last written to the database.
+type DirtyColumns struct {
+	columns []string
+}
+
+func (d *DirtyColumns) Mark(column string) {
+	if !slices.Contains(d.columns, column) {
+		d.columns = append(d.columns, column)
+	}
+}
+
+func (d DirtyColumns) Columns() []string {
+	return slices.Clone(d.columns)
+}
+
+func (d *DirtyColumns) Clear() {
+	d.columns = nil
+}
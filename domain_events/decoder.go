@@ -0,0 +1,52 @@
+package domain_events
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Decoding decodes the json payloads of the events of one type.
+type Decoding struct {
+	name   string
+	decode func(payload []byte) (Event, error)
+}
+
+// DecodingOf returns the decoding of the events of type E, the
+// name of the events is the one returned by the zero value of E.
+func DecodingOf[E Event]() Decoding {
+	var zero E
+	return Decoding{
+		name: zero.EventName(),
+		decode: func(payload []byte) (Event, error) {
+			var event E
+			err := json.Unmarshal(payload, &event)
+			if err != nil {
+				return nil, err
+			}
+			return event, nil
+		},
+	}
+}
+
+// Decoder decodes the events stored with their name and json payload.
+type Decoder map[string]func(payload []byte) (Event, error)
+
+func NewDecoder(decodings ...Decoding) Decoder {
+	d := make(Decoder, len(decodings))
+	for _, v := range decodings {
+		d[v.name] = v.decode
+	}
+	return d
+}
+
+func (d Decoder) Decode(name string, payload []byte) (Event, error) {
+	decode, ok := d[name]
+	if !ok {
+		return nil, fmt.Errorf("the event %s has no decoding", name)
+	}
+	event, err := decode(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding the event %s %w", name, err)
+	}
+	return event, nil
+}
@@ -0,0 +1,30 @@
+package domain_events
+
+import "slices"
+
+// Event is a fact raised by an aggregate, its name identifies the type
+// of the event in the outbox and its json encoding is the payload.
+type Event interface {
+	EventName() string
+}
+
+// Events is the list of events recorded by an object
+// and not yet written to the outbox.
+type Events struct {
+	pending []Event
+}
+
+func (e *Events) Record(event Event) {
+	e.pending = append(e.pending, event)
+}
+
+func (e Events) Pending() []Event {
+	return slices.Clone(e.pending)
+}
+
+// Pull returns the pending events and empties the list.
+func (e *Events) Pull() []Event {
+	pending := e.pending
+	e.pending = nil
+	return pending
+}
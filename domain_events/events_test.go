@@ -0,0 +1,46 @@
+package domain_events
+
+import "testing"
+
+type renamed struct {
+	Name string `json:"name"`
+}
+
+func (e renamed) EventName() string {
+	return "renamed"
+}
+
+func TestEvents(t *testing.T) {
+	var e Events
+	e.Record(renamed{Name: "a"})
+	e.Record(renamed{Name: "b"})
+	if len(e.Pending()) != 2 {
+		t.Fatalf("expected 2 pending events got %d", len(e.Pending()))
+	}
+	pulled := e.Pull()
+	if len(pulled) != 2 || pulled[0].(renamed).Name != "a" {
+		t.Fatalf("unexpected pulled events %v", pulled)
+	}
+	if len(e.Pending()) != 0 {
+		t.Fatalf("expected no pending events after pull got %d", len(e.Pending()))
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	d := NewDecoder(DecodingOf[renamed]())
+	event, err := d.Decode("renamed", []byte(`{"name":"a"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event != (renamed{Name: "a"}) {
+		t.Fatalf("unexpected decoded event %v", event)
+	}
+	_, err = d.Decode("removed", []byte(`{}`))
+	if err == nil {
+		t.Fatal("expected an error for an event without decoding")
+	}
+	_, err = d.Decode("renamed", []byte(`[]`))
+	if err == nil {
+		t.Fatal("expected an error for an invalid payload")
+	}
+}
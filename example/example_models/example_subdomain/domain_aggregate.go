@@ -1,13 +1,15 @@
 package example_subdomain
 
 import (
+	"clearly-not-a-secret-project/dirty_tracking"
 	"clearly-not-a-secret-project/lazy_loading"
 )
 
 type DomainAggregate struct {
-	id         string
-	name       string
-	loadStatus lazy_loading.LoadStatus
+	id           string
+	name         string
+	loadStatus   lazy_loading.LoadStatus
+	dirtyColumns dirty_tracking.DirtyColumns
 }
 
 func NewDomainAggregate(id, name string) *DomainAggregate {
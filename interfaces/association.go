@@ -0,0 +1,9 @@
+package interfaces
+
+// Association is a set of ids associated to an object through a join
+// table, the data mappers write its changes when the object is saved
+// and commit them once written.
+type Association interface {
+	Changes() (added []any, removed []any)
+	Commit()
+}
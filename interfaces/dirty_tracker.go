@@ -0,0 +1,6 @@
+package interfaces
+
+type DirtyTracker interface {
+	DirtyColumns() []string
+	ClearDirtyColumns()
+}
@@ -0,0 +1,12 @@
+package interfaces
+
+import "clearly-not-a-secret-project/domain_events"
+
+// EventRecorder is implemented by the objects raising domain events, the
+// data mappers write the pending events to the outbox in the transaction
+// of the statement that changed the object and pull them once it succeeds.
+type EventRecorder interface {
+	RecordEvent(event domain_events.Event)
+	PendingEvents() []domain_events.Event
+	PullEvents() []domain_events.Event
+}
@@ -0,0 +1,13 @@
+package interfaces
+
+import "clearly-not-a-secret-project/domain_events"
+
+// EventSourced is implemented by the objects rebuilt by replaying their
+// events, Apply changes the state of the object for one event and the
+// version is the number of events stored for the object.
+type EventSourced interface {
+	EventRecorder
+	Apply(event domain_events.Event) error
+	Version() int64
+	SetVersion(version int64)
+}
@@ -0,0 +1,9 @@
+package interfaces
+
+import "reflect"
+
+// Polymorphic is implemented by the data mappers of a hierarchy of types,
+// the registry resolves each type of the hierarchy to the same mapper.
+type Polymorphic interface {
+	Subtypes() []reflect.Type
+}
@@ -25,6 +25,7 @@ func NewDomainAggregateDataMapper(pool *pgxpool.Pool, loadedMap map[string]inter
 			InsertStatement: `INSERT INTO AGGREGATE (ID, NAME) VALUES ($1, $2);`,
 			UpdateStatement: `UPDATE AGGREGATE SET NAME = $2 WHERE ID = $1`,
 			RemoveStatement: `DELETE FROM AGGREGATE WHERE ID = $1;`,
+			ExistsStatement: `SELECT EXISTS (SELECT 1 FROM AGGREGATE WHERE ID = $1);`,
 			DoLoad: func(resultSet pgx.Rows) (interfaces.DomainObject[string], error) {
 				var (
 					id   string
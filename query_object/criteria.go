@@ -0,0 +1,204 @@
+package query_object
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Criteria is a condition of the where clause of a query object, param
+// registers a parameter value and returns its placeholder.
+type Criteria interface {
+	Sql(param func(value any) string) string
+}
+
+// The criteria that can't be written report why, the query objects
+// holding them return the error from Err.
+type failing interface {
+	err() error
+}
+
+func errOf(criteria Criteria) error {
+	if f, ok := criteria.(failing); ok {
+		return f.err()
+	}
+	return nil
+}
+
+type comparison struct {
+	column   string
+	operator string
+	value    any
+}
+
+func (c comparison) Sql(param func(value any) string) string {
+	return fmt.Sprintf("%s %s %s", c.column, c.operator, param(c.value))
+}
+
+func Equals(column string, value any) Criteria {
+	return comparison{column: column, operator: "=", value: value}
+}
+
+func NotEquals(column string, value any) Criteria {
+	return comparison{column: column, operator: "<>", value: value}
+}
+
+func GreaterThan(column string, value any) Criteria {
+	return comparison{column: column, operator: ">", value: value}
+}
+
+func GreaterOrEquals(column string, value any) Criteria {
+	return comparison{column: column, operator: ">=", value: value}
+}
+
+func LessThan(column string, value any) Criteria {
+	return comparison{column: column, operator: "<", value: value}
+}
+
+func LessOrEquals(column string, value any) Criteria {
+	return comparison{column: column, operator: "<=", value: value}
+}
+
+func Like(column string, pattern string) Criteria {
+	return comparison{column: column, operator: "LIKE", value: pattern}
+}
+
+// In matches the rows whose column is any of the elements of values,
+// a slice pgx is able to encode as an array.
+func In(column string, values any) Criteria {
+	return in{column: column, values: values}
+}
+
+type in struct {
+	column string
+	values any
+}
+
+func (c in) Sql(param func(value any) string) string {
+	return fmt.Sprintf("%s = ANY(%s)", c.column, param(c.values))
+}
+
+type isNull struct {
+	column string
+	not    bool
+}
+
+func (c isNull) Sql(param func(value any) string) string {
+	if c.not {
+		return fmt.Sprintf("%s IS NOT NULL", c.column)
+	}
+	return fmt.Sprintf("%s IS NULL", c.column)
+}
+
+func IsNull(column string) Criteria {
+	return isNull{column: column}
+}
+
+func IsNotNull(column string) Criteria {
+	return isNull{column: column, not: true}
+}
+
+// JSONPath returns the expression of the text value found following keys
+// inside the jsonb column, it can be used as the column of any criteria,
+// JSONPath("attributes", "color") is attributes->>'color'.
+func JSONPath(column string, keys ...string) string {
+	if len(keys) == 0 {
+		return column
+	}
+	var b strings.Builder
+	b.WriteString(column)
+	for i, v := range keys {
+		if i == len(keys)-1 {
+			b.WriteString("->>")
+		} else {
+			b.WriteString("->")
+		}
+		fmt.Fprintf(&b, "'%s'", strings.ReplaceAll(v, "'", "''"))
+	}
+	return b.String()
+}
+
+// JSONContains matches the rows whose jsonb column contains the
+// json encoding of value, as in attributes @> '{"color":"red"}'. The
+// query objects holding a value that can't be encoded return its
+// error from Err and are refused by the data mappers.
+func JSONContains(column string, value any) Criteria {
+	data, err := json.Marshal(value)
+	if err != nil {
+		err = fmt.Errorf("error encoding the value of the column %s %w", column, err)
+	}
+	return jsonContains{column: column, value: value, data: data, encodeErr: err}
+}
+
+type jsonContains struct {
+	column    string
+	value     any
+	data      []byte
+	encodeErr error
+}
+
+// A value that can't be encoded is passed as it is, so that the
+// statement fails to run even when the error of the query is ignored.
+func (c jsonContains) Sql(param func(value any) string) string {
+	if c.encodeErr != nil {
+		return fmt.Sprintf("%s @> %s::jsonb", c.column, param(c.value))
+	}
+	return fmt.Sprintf("%s @> %s::jsonb", c.column, param(string(c.data)))
+}
+
+func (c jsonContains) err() error {
+	return c.encodeErr
+}
+
+type junction struct {
+	operator string
+	criteria []Criteria
+}
+
+// The conjunction of no criteria is true and the disjunction false.
+func (c junction) Sql(param func(value any) string) string {
+	if len(c.criteria) == 0 {
+		if c.operator == "AND" {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+	conditions := make([]string, 0, len(c.criteria))
+	for _, v := range c.criteria {
+		conditions = append(conditions, v.Sql(param))
+	}
+	return fmt.Sprintf("(%s)", strings.Join(conditions, fmt.Sprintf(" %s ", c.operator)))
+}
+
+func (c junction) err() error {
+	for _, v := range c.criteria {
+		if err := errOf(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func And(criteria ...Criteria) Criteria {
+	return junction{operator: "AND", criteria: criteria}
+}
+
+func Or(criteria ...Criteria) Criteria {
+	return junction{operator: "OR", criteria: criteria}
+}
+
+type not struct {
+	criteria Criteria
+}
+
+func (c not) Sql(param func(value any) string) string {
+	return fmt.Sprintf("NOT %s", c.criteria.Sql(param))
+}
+
+func (c not) err() error {
+	return errOf(c.criteria)
+}
+
+func Not(criteria Criteria) Criteria {
+	return not{criteria: criteria}
+}
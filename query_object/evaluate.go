@@ -0,0 +1,473 @@
+package query_object
+
+import (
+	"cmp"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Row reads the value of a column of an object evaluated in memory.
+type Row func(column string) (any, error)
+
+// Evaluable criteria tell whether the values of the columns of an object meet
+// them, as the in-memory data mappers filter their objects. As in SQL a
+// comparison with NULL is unknown, neither it nor its negation match.
+type Evaluable interface {
+	Matches(row Row) (bool, error)
+}
+
+// The truth values of the three-valued logic of SQL.
+type truth int
+
+const (
+	isFalse truth = iota
+	isTrue
+	isUnknown
+)
+
+func truthOf(ok bool) truth {
+	if ok {
+		return isTrue
+	}
+	return isFalse
+}
+
+// The criteria evaluated to unknown when they compare NULL.
+type ternary interface {
+	evaluate(row Row) (truth, error)
+}
+
+// Matches tells whether the row meets the criteria, they must be Evaluable.
+func Matches(criteria Criteria, row Row) (bool, error) {
+	result, err := evaluate(criteria, row)
+	return result == isTrue, err
+}
+
+// The truth value of the criteria for the row, the Evaluable criteria
+// other than those of the package are either true or false.
+func evaluate(criteria Criteria, row Row) (truth, error) {
+	if t, ok := criteria.(ternary); ok {
+		return t.evaluate(row)
+	}
+	evaluable, ok := criteria.(Evaluable)
+	if !ok {
+		return isFalse, fmt.Errorf("the criteria %T can't be evaluated in memory", criteria)
+	}
+	matches, err := evaluable.Matches(row)
+	if err != nil {
+		return isFalse, err
+	}
+	return truthOf(matches), nil
+}
+
+// The value written to the column, the valuers are replaced by their value
+// and the pointers by the value they point to, nil is NULL.
+func normalize(value any) (any, error) {
+	for {
+		if valuer, ok := value.(driver.Valuer); ok {
+			v, err := valuer.Value()
+			if err != nil {
+				return nil, fmt.Errorf("error reading the value %v %w", value, err)
+			}
+			value = v
+			continue
+		}
+		v := reflect.ValueOf(value)
+		if v.Kind() != reflect.Pointer {
+			return value, nil
+		}
+		if v.IsNil() {
+			return nil, nil
+		}
+		value = v.Elem().Interface()
+	}
+}
+
+// Compares the normalized values of the same kind, the numbers of any type.
+func compare(a, b any) (int, error) {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch {
+	case va.CanInt() && vb.CanInt():
+		return cmp.Compare(va.Int(), vb.Int()), nil
+	case va.CanUint() && vb.CanUint():
+		return cmp.Compare(va.Uint(), vb.Uint()), nil
+	case number(va) && number(vb):
+		return cmp.Compare(toFloat(va), toFloat(vb)), nil
+	case va.Kind() == reflect.String && vb.Kind() == reflect.String:
+		return strings.Compare(va.String(), vb.String()), nil
+	case va.Kind() == reflect.Bool && vb.Kind() == reflect.Bool:
+		switch {
+		case va.Bool() == vb.Bool():
+			return 0, nil
+		case va.Bool():
+			return 1, nil
+		default:
+			return -1, nil
+		}
+	}
+	ta, okA := a.(time.Time)
+	tb, okB := b.(time.Time)
+	if okA && okB {
+		return ta.Compare(tb), nil
+	}
+	return 0, fmt.Errorf("the values %v of type %T and %v of type %T can't be compared", a, a, b, b)
+}
+
+func number(v reflect.Value) bool {
+	return v.CanInt() || v.CanUint() || v.CanFloat()
+}
+
+func toFloat(v reflect.Value) float64 {
+	switch {
+	case v.CanInt():
+		return float64(v.Int())
+	case v.CanUint():
+		return float64(v.Uint())
+	default:
+		return v.Float()
+	}
+}
+
+// The values that can't be ordered are equal when they are deeply equal.
+func equals(a, b any) bool {
+	c, err := compare(a, b)
+	if err != nil {
+		return reflect.DeepEqual(a, b)
+	}
+	return c == 0
+}
+
+// Reads the column and the value compared to it, ok is false when any is NULL.
+func operands(row Row, column string, value any) (any, any, bool, error) {
+	a, err := row(column)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	a, err = normalize(a)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	b, err := normalize(value)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return a, b, a != nil && b != nil, nil
+}
+
+func (c comparison) Matches(row Row) (bool, error) {
+	return Matches(c, row)
+}
+
+func (c comparison) evaluate(row Row) (truth, error) {
+	a, b, ok, err := operands(row, c.column, c.value)
+	if err != nil {
+		return isFalse, err
+	}
+	if !ok {
+		return isUnknown, nil
+	}
+	switch c.operator {
+	case "=":
+		return truthOf(equals(a, b)), nil
+	case "<>":
+		return truthOf(!equals(a, b)), nil
+	case "LIKE":
+		s, okA := a.(string)
+		pattern, okB := b.(string)
+		if !okA || !okB {
+			return isFalse, fmt.Errorf("the column %s is not a string matched by a pattern", c.column)
+		}
+		return truthOf(like(s, pattern)), nil
+	}
+	result, err := compare(a, b)
+	if err != nil {
+		return isFalse, err
+	}
+	switch c.operator {
+	case ">":
+		return truthOf(result > 0), nil
+	case ">=":
+		return truthOf(result >= 0), nil
+	case "<":
+		return truthOf(result < 0), nil
+	default:
+		return truthOf(result <= 0), nil
+	}
+}
+
+// The % of the pattern matches any sequence of characters and the _ any one.
+func like(s, pattern string) bool {
+	var b strings.Builder
+	b.WriteString("(?s)^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String()).MatchString(s)
+}
+
+func (c in) Matches(row Row) (bool, error) {
+	return Matches(c, row)
+}
+
+// A value not found among values holding NULL is unknown.
+func (c in) evaluate(row Row) (truth, error) {
+	value, err := row(c.column)
+	if err != nil {
+		return isFalse, err
+	}
+	value, err = normalize(value)
+	if err != nil {
+		return isFalse, err
+	}
+	if value == nil {
+		return isUnknown, nil
+	}
+	values := reflect.ValueOf(c.values)
+	if values.Kind() != reflect.Slice && values.Kind() != reflect.Array {
+		return isFalse, fmt.Errorf("the values of the column %s are not a slice", c.column)
+	}
+	result := isFalse
+	for i := range values.Len() {
+		v, err := normalize(values.Index(i).Interface())
+		if err != nil {
+			return isFalse, err
+		}
+		if v == nil {
+			result = isUnknown
+			continue
+		}
+		if equals(value, v) {
+			return isTrue, nil
+		}
+	}
+	return result, nil
+}
+
+func (c isNull) Matches(row Row) (bool, error) {
+	return Matches(c, row)
+}
+
+func (c isNull) evaluate(row Row) (truth, error) {
+	value, err := row(c.column)
+	if err != nil {
+		return isFalse, err
+	}
+	value, err = normalize(value)
+	if err != nil {
+		return isFalse, err
+	}
+	return truthOf((value == nil) != c.not), nil
+}
+
+func (c jsonContains) Matches(row Row) (bool, error) {
+	return Matches(c, row)
+}
+
+func (c jsonContains) evaluate(row Row) (truth, error) {
+	value, err := row(c.column)
+	if err != nil {
+		return isFalse, err
+	}
+	value, err = normalize(value)
+	if err != nil {
+		return isFalse, err
+	}
+	if value == nil {
+		return isUnknown, nil
+	}
+	a, err := jsonValue(value)
+	if err != nil {
+		return isFalse, err
+	}
+	if c.encodeErr != nil {
+		return isFalse, c.encodeErr
+	}
+	b, err := jsonValue(c.data)
+	if err != nil {
+		return isFalse, err
+	}
+	return truthOf(contains(a, b)), nil
+}
+
+// The decoded json of the value, the bytes and strings hold the json itself.
+func jsonValue(value any) (any, error) {
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		var err error
+		data, err = json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var result any
+	err := json.Unmarshal(data, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// The json a contains b as the @> operator of jsonb, the objects contain
+// the keys of b with values containing theirs and the arrays contain
+// the elements of b.
+func contains(a, b any) bool {
+	switch b := b.(type) {
+	case map[string]any:
+		object, ok := a.(map[string]any)
+		if !ok {
+			return false
+		}
+		for k, v := range b {
+			value, ok := object[k]
+			if !ok || !contains(value, v) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		array, ok := a.([]any)
+		if !ok {
+			return false
+		}
+		for _, v := range b {
+			if !slices.ContainsFunc(array, func(value any) bool { return contains(value, v) }) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+func (c junction) Matches(row Row) (bool, error) {
+	return Matches(c, row)
+}
+
+// Any false criteria makes the conjunction false and any true one the
+// disjunction true, otherwise an unknown criteria makes them unknown.
+func (c junction) evaluate(row Row) (truth, error) {
+	decisive, result := isFalse, isTrue
+	if c.operator == "OR" {
+		decisive, result = isTrue, isFalse
+	}
+	for _, v := range c.criteria {
+		t, err := evaluate(v, row)
+		if err != nil {
+			return isFalse, err
+		}
+		if t == decisive {
+			return decisive, nil
+		}
+		if t == isUnknown {
+			result = isUnknown
+		}
+	}
+	return result, nil
+}
+
+func (c not) Matches(row Row) (bool, error) {
+	return Matches(c, row)
+}
+
+// The negation of unknown is unknown.
+func (c not) evaluate(row Row) (truth, error) {
+	t, err := evaluate(c.criteria, row)
+	if err != nil || t == isUnknown {
+		return t, err
+	}
+	return truthOf(t == isFalse), nil
+}
+
+// Select returns the objects whose rows meet the criteria of the query,
+// sorted by its order and within its limit and offset, as its statement
+// selects them. row reads the values of the columns of an object. The
+// NULLs are sorted last in ascending order as PostgreSQL sorts them.
+func Select[T any](q *QueryObject, objs []T, row func(obj T) Row) ([]T, error) {
+	type selected struct {
+		obj  T
+		keys []any
+	}
+	result := make([]selected, 0, len(objs))
+	for _, obj := range objs {
+		r := row(obj)
+		ok, err := And(q.criteria...).(Evaluable).Matches(r)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		keys := make([]any, 0, len(q.orderBy))
+		for _, v := range q.orderBy {
+			key, err := r(v.column)
+			if err != nil {
+				return nil, err
+			}
+			key, err = normalize(key)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+		result = append(result, selected{obj: obj, keys: keys})
+	}
+	var err error
+	slices.SortStableFunc(result, func(a, b selected) int {
+		for i, v := range q.orderBy {
+			var c int
+			switch {
+			case a.keys[i] == nil && b.keys[i] == nil:
+				continue
+			case a.keys[i] == nil:
+				c = 1
+			case b.keys[i] == nil:
+				c = -1
+			default:
+				var cmpErr error
+				c, cmpErr = compare(a.keys[i], b.keys[i])
+				if cmpErr != nil && err == nil {
+					err = cmpErr
+				}
+			}
+			if v.desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	result = result[min(q.offset, len(result)):]
+	if q.limit > 0 {
+		result = result[:min(q.limit, len(result))]
+	}
+	objects := make([]T, 0, len(result))
+	for _, v := range result {
+		objects = append(objects, v.obj)
+	}
+	return objects, nil
+}
@@ -0,0 +1,183 @@
+package query_object
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type Order struct {
+	column string
+	desc   bool
+}
+
+func Asc(column string) Order {
+	return Order{column: column}
+}
+
+func Desc(column string) Order {
+	return Order{column: column, desc: true}
+}
+
+func (o Order) Column() string {
+	return o.column
+}
+
+func (o Order) Descending() bool {
+	return o.desc
+}
+
+// Aggregate is a function computing a single value
+// from a column of the rows selected by a query.
+type Aggregate struct {
+	function string
+	column   string
+}
+
+// Count counts the rows.
+func Count() Aggregate {
+	return Aggregate{function: "count", column: "*"}
+}
+
+// Sum adds up the column, it's zero when no row is selected.
+func Sum(column string) Aggregate {
+	return Aggregate{function: "sum", column: column}
+}
+
+func Min(column string) Aggregate {
+	return Aggregate{function: "min", column: column}
+}
+
+func Max(column string) Aggregate {
+	return Aggregate{function: "max", column: column}
+}
+
+func (a Aggregate) Sql() string {
+	if a.function == "sum" {
+		return fmt.Sprintf("coalesce(sum(%s), 0)", a.column)
+	}
+	return fmt.Sprintf("%s(%s)", a.function, a.column)
+}
+
+// QueryObject builds the select statement of a table, it's a
+// data_mapper.StatementSource to be used with FindMany. The selected
+// columns must be the ones the data mapper of the table loads.
+type QueryObject struct {
+	table    string
+	columns  []string
+	criteria []Criteria
+	orderBy  []Order
+	limit    int
+	offset   int
+	err      error
+}
+
+func New(table string, columns ...string) *QueryObject {
+	return &QueryObject{
+		table:    table,
+		columns:  columns,
+		criteria: make([]Criteria, 0),
+		orderBy:  make([]Order, 0),
+	}
+}
+
+// Where adds criteria to the query, all of them must be met. The error of
+// the first criteria that can't be written is kept by the query.
+func (q *QueryObject) Where(criteria ...Criteria) *QueryObject {
+	for _, v := range criteria {
+		if q.err == nil {
+			q.err = errOf(v)
+		}
+	}
+	q.criteria = append(q.criteria, criteria...)
+	return q
+}
+
+func (q *QueryObject) OrderBy(orders ...Order) *QueryObject {
+	q.orderBy = append(q.orderBy, orders...)
+	return q
+}
+
+func (q *QueryObject) Limit(limit int) *QueryObject {
+	q.limit = limit
+	return q
+}
+
+func (q *QueryObject) Offset(offset int) *QueryObject {
+	q.offset = offset
+	return q
+}
+
+func (q *QueryObject) Table() string {
+	return q.table
+}
+
+func (q *QueryObject) Columns() []string {
+	return slices.Clone(q.columns)
+}
+
+// Err returns the error of the first criteria of the query that can't be
+// written, the statement of the query must not be run.
+func (q *QueryObject) Err() error {
+	return q.err
+}
+
+// Clone returns a copy of the query that can be
+// extended without modifying q.
+func (q *QueryObject) Clone() *QueryObject {
+	return &QueryObject{
+		table:    q.table,
+		columns:  slices.Clone(q.columns),
+		criteria: slices.Clone(q.criteria),
+		orderBy:  slices.Clone(q.orderBy),
+		limit:    q.limit,
+		offset:   q.offset,
+		err:      q.err,
+	}
+}
+
+func (q *QueryObject) build() (string, []interface{}) {
+	params := make([]interface{}, 0)
+	param := func(value any) string {
+		params = append(params, value)
+		return fmt.Sprintf("$%d", len(params))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "SELECT %s FROM %s", strings.Join(q.columns, ", "), q.table)
+	if len(q.criteria) > 0 {
+		conditions := make([]string, 0, len(q.criteria))
+		for _, v := range q.criteria {
+			conditions = append(conditions, v.Sql(param))
+		}
+		fmt.Fprintf(&b, " WHERE %s", strings.Join(conditions, " AND "))
+	}
+	if len(q.orderBy) > 0 {
+		orders := make([]string, 0, len(q.orderBy))
+		for _, v := range q.orderBy {
+			if v.desc {
+				orders = append(orders, fmt.Sprintf("%s DESC", v.column))
+			} else {
+				orders = append(orders, v.column)
+			}
+		}
+		fmt.Fprintf(&b, " ORDER BY %s", strings.Join(orders, ", "))
+	}
+	if q.limit > 0 {
+		fmt.Fprintf(&b, " LIMIT %d", q.limit)
+	}
+	if q.offset > 0 {
+		fmt.Fprintf(&b, " OFFSET %d", q.offset)
+	}
+	b.WriteString(";")
+	return b.String(), params
+}
+
+func (q *QueryObject) Sql() string {
+	sql, _ := q.build()
+	return sql
+}
+
+func (q *QueryObject) Parameters() []interface{} {
+	_, params := q.build()
+	return params
+}
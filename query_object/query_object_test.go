@@ -0,0 +1,186 @@
+package query_object
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQueryObject(t *testing.T) {
+	tests := map[string]struct {
+		query  *QueryObject
+		sql    string
+		params []interface{}
+	}{
+		"all": {
+			query:  New("aggregate", "id", "name"),
+			sql:    "SELECT id, name FROM aggregate;",
+			params: []interface{}{},
+		},
+		"criteria": {
+			query: New("aggregate", "id", "name").
+				Where(Equals("name", "a"), Or(GreaterThan("id", 1), IsNull("name"))).
+				OrderBy(Desc("id"), Asc("name")).
+				Limit(10).
+				Offset(20),
+			sql:    "SELECT id, name FROM aggregate WHERE name = $1 AND (id > $2 OR name IS NULL) ORDER BY id DESC, name LIMIT 10 OFFSET 20;",
+			params: []interface{}{"a", 1},
+		},
+		"json path": {
+			query:  New("aggregate", "id").Where(Equals(JSONPath("attributes", "size", "unit"), "cm")),
+			sql:    "SELECT id FROM aggregate WHERE attributes->'size'->>'unit' = $1;",
+			params: []interface{}{"cm"},
+		},
+		"json contains": {
+			query:  New("aggregate", "id").Where(Not(JSONContains("attributes", map[string]string{"color": "red"}))),
+			sql:    "SELECT id FROM aggregate WHERE NOT attributes @> $1::jsonb;",
+			params: []interface{}{`{"color":"red"}`},
+		},
+		"empty junctions": {
+			query:  New("aggregate", "id").Where(And(), Not(Or())),
+			sql:    "SELECT id FROM aggregate WHERE TRUE AND NOT FALSE;",
+			params: []interface{}{},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if sql := tt.query.Sql(); sql != tt.sql {
+				t.Fatalf("expected %s got %s", tt.sql, sql)
+			}
+			if params := tt.query.Parameters(); !reflect.DeepEqual(params, tt.params) {
+				t.Fatalf("expected %v got %v", tt.params, params)
+			}
+		})
+	}
+}
+
+func TestQueryObject_Err(t *testing.T) {
+	q := New("aggregate", "id").Where(Equals("id", 1))
+	if q.Err() != nil {
+		t.Fatalf("unexpected error %v", q.Err())
+	}
+	q.Where(Or(Equals("id", 2), Not(JSONContains("attributes", map[string]any{"f": func() {}}))))
+	if q.Err() == nil || q.Clone().Err() == nil {
+		t.Fatal("expected the error of the value that can't be encoded")
+	}
+	expected := "SELECT id FROM aggregate WHERE id = $1 AND (id = $2 OR NOT attributes @> $3::jsonb);"
+	if sql := q.Sql(); sql != expected {
+		t.Fatalf("expected the value to be passed to the statement %s got %s", expected, sql)
+	}
+	row := func(column string) (any, error) {
+		return `{"color":"red"}`, nil
+	}
+	if _, err := Matches(JSONContains("attributes", make(chan int)), row); err == nil {
+		t.Fatal("expected the error of the value that can't be encoded")
+	}
+}
+
+func TestQueryObject_Clone(t *testing.T) {
+	q := New("aggregate", "id").Where(Equals("id", 1))
+	clone := q.Clone().Where(Equals("name", "a"))
+	if q.Sql() != "SELECT id FROM aggregate WHERE id = $1;" {
+		t.Fatalf("the clone modified the query %s", q.Sql())
+	}
+	if clone.Sql() != "SELECT id FROM aggregate WHERE id = $1 AND name = $2;" {
+		t.Fatalf("unexpected clone statement %s", clone.Sql())
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	tests := map[string]struct {
+		aggregate Aggregate
+		sql       string
+	}{
+		"count": {Count(), "count(*)"},
+		"sum":   {Sum("amount"), "coalesce(sum(amount), 0)"},
+		"min":   {Min("amount"), "min(amount)"},
+		"max":   {Max("amount"), "max(amount)"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if sql := tt.aggregate.Sql(); sql != tt.sql {
+				t.Fatalf("expected %s got %s", tt.sql, sql)
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	name := "abc"
+	values := map[string]any{
+		"id":         int64(7),
+		"name":       &name,
+		"deleted_at": (*string)(nil),
+		"attributes": map[string]any{"color": "red", "sizes": []int{1, 2}},
+	}
+	row := func(column string) (any, error) {
+		return values[column], nil
+	}
+	tests := map[string]struct {
+		criteria Criteria
+		matches  bool
+	}{
+		"equals":              {criteria: Equals("id", 7), matches: true},
+		"not equals":          {criteria: NotEquals("id", 7)},
+		"greater":             {criteria: GreaterThan("id", 6.5), matches: true},
+		"less or equals":      {criteria: LessOrEquals("name", "abb")},
+		"like":                {criteria: Like("name", "a_%"), matches: true},
+		"in":                  {criteria: In("id", []int64{1, 7}), matches: true},
+		"is null":             {criteria: IsNull("deleted_at"), matches: true},
+		"null comparison":     {criteria: Equals("deleted_at", "a")},
+		"contains":            {criteria: JSONContains("attributes", map[string]any{"sizes": []int{2}}), matches: true},
+		"not contains":        {criteria: JSONContains("attributes", map[string]any{"color": "blue"})},
+		"or":                  {criteria: Or(Equals("id", 1), IsNotNull("name")), matches: true},
+		"not":                 {criteria: Not(And(Equals("id", 7), Like("name", "%c"))), matches: false},
+		"not null comparison": {criteria: Not(Equals("deleted_at", "a"))},
+		"not in with null":    {criteria: Not(In("id", []*int64{nil}))},
+		"not and unknown":     {criteria: Not(And(Equals("id", 7), Equals("deleted_at", "a")))},
+		"not and false":       {criteria: Not(And(Equals("id", 1), Equals("deleted_at", "a"))), matches: true},
+		"or unknown":          {criteria: Or(Equals("deleted_at", "a"), Equals("id", 7)), matches: true},
+		"not or unknown":      {criteria: Not(Or(Equals("deleted_at", "a"), Equals("id", 1)))},
+		"empty and":           {criteria: And(), matches: true},
+		"empty or":            {criteria: Or()},
+	}
+	for k, v := range tests {
+		matches, err := Matches(v.criteria, row)
+		if err != nil {
+			t.Fatalf("%s: %v", k, err)
+		}
+		if matches != v.matches {
+			t.Fatalf("%s: expected %v got %v", k, v.matches, matches)
+		}
+	}
+	if _, err := Matches(GreaterThan("id", "a"), row); err == nil {
+		t.Fatal("expected an error comparing a number to a string")
+	}
+}
+
+func TestSelect(t *testing.T) {
+	type row struct {
+		id    int
+		score *int
+	}
+	one, two := 1, 2
+	rows := []row{{id: 1, score: &two}, {id: 2}, {id: 3, score: &one}, {id: 4, score: &two}}
+	q := New("aggregate", "id", "score").
+		Where(NotEquals("id", 4)).
+		OrderBy(Asc("score"), Desc("id")).
+		Offset(1)
+	selected, err := Select(q, rows, func(obj row) Row {
+		return func(column string) (any, error) {
+			if column == "id" {
+				return obj.id, nil
+			}
+			return obj.score, nil
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]int, 0, len(selected))
+	for _, v := range selected {
+		ids = append(ids, v.id)
+	}
+	if !reflect.DeepEqual(ids, []int{1, 2}) {
+		t.Fatalf("expected the ids 1, 2 got %v", ids)
+	}
+}
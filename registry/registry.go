@@ -27,6 +27,13 @@ func (r *Registry[K]) Register(obj interfaces.Registrable) {
 		return
 	}
 	r.m[obj.Type()] = obj
+	if polymorphic, ok := obj.(interfaces.Polymorphic); ok {
+		for _, t := range polymorphic.Subtypes() {
+			if _, ok := r.m[t]; !ok {
+				r.m[t] = obj
+			}
+		}
+	}
 }
 
 func (r *Registry[K]) Mapper(typeName reflect.Type) (data_mapper.DataMapper[interfaces.DomainObject[K], K], error) {
@@ -46,6 +53,25 @@ func (r *Registry[K]) Mapper(typeName reflect.Type) (data_mapper.DataMapper[inte
 	return mapper, nil
 }
 
+// Named returns the mapper of the type with the qualified name, the import
+// path of its package followed by a dot and the name of the type. It resolves
+// the types declared in packages the caller can't import.
+func (r *Registry[K]) Named(name string) (data_mapper.DataMapper[interfaces.DomainObject[K], K], error) {
+	for t := range r.m {
+		if qualifiedName(t) == name {
+			return r.Mapper(t)
+		}
+	}
+	return nil, fmt.Errorf("the mapper for type %s is not in the registry", name)
+}
+
+func qualifiedName(t reflect.Type) string {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return fmt.Sprintf("%s.%s", t.PkgPath(), t.Name())
+}
+
 func (r *Registry[K]) Load(obj interfaces.DomainObject[K]) error {
 	mapper, err := r.Mapper(obj.Type())
 	if err != nil {
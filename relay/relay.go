@@ -0,0 +1,166 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Message is an event read from the outbox.
+type Message struct {
+	Id            int64
+	AggregateType string
+	AggregateId   string
+	EventType     string
+	Payload       json.RawMessage
+	OccurredAt    time.Time
+}
+
+// Publisher hands the messages to a broker, a message is marked as
+// published only if Publish returns no error, so it's delivered at
+// least once and the consumers must be idempotent.
+type Publisher interface {
+	Publish(ctx context.Context, message Message) error
+}
+
+// Beginner starts the transactions of the relay,
+// it's satisfied by *pgxpool.Pool and *pgx.Conn.
+type Beginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// Relay polls the outbox and publishes each batch of messages in the
+// order of their ids. Many relays can poll the same outbox, the rows
+// locked by one of them are skipped by the others, so with several
+// relays the messages can be published out of the order they were written.
+type Relay struct {
+	Db        Beginner
+	Table     string
+	Publisher Publisher
+	BatchSize int
+	Interval  time.Duration
+	// OnError is called with the errors Run retries, when nil they're logged.
+	OnError func(err error)
+}
+
+func New(db Beginner, publisher Publisher) Relay {
+	return Relay{
+		Db:        db,
+		Table:     "outbox",
+		Publisher: publisher,
+		BatchSize: 100,
+		Interval:  time.Second,
+	}
+}
+
+// Run relays the messages every Interval until ctx is done. The failed
+// publications and the database errors are reported to OnError and retried
+// on the next tick, Run only returns the errors retrying can't fix, as a
+// missing outbox table or privilege.
+func (r Relay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	for {
+		_, err := r.RelayOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			if unrecoverable(err) {
+				return err
+			}
+			r.report(err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce publishes a batch of unpublished messages and returns
+// how many of them were published. It stops at the first message
+// the publisher fails, the following ones are retried on the next call.
+func (r Relay) RelayOnce(ctx context.Context) (int, error) {
+	tx, err := r.Db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error at begin transaction %w", err)
+	}
+	defer tx.Rollback(ctx)
+	messages, err := r.unpublished(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+	published := make([]int64, 0, len(messages))
+	var publishErr error
+	for _, message := range messages {
+		publishErr = r.Publisher.Publish(ctx, message)
+		if publishErr != nil {
+			publishErr = fmt.Errorf("error publishing the message %d %w", message.Id, publishErr)
+			break
+		}
+		published = append(published, message.Id)
+	}
+	if len(published) > 0 {
+		_, err = tx.Exec(ctx, r.markStmt(), published)
+		if err != nil {
+			return 0, fmt.Errorf("error marking the messages as published %w", err)
+		}
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error at commit %w", err)
+	}
+	return len(published), publishErr
+}
+
+func (r Relay) report(err error) {
+	if r.OnError != nil {
+		r.OnError(err)
+		return
+	}
+	log.Printf("error relaying the outbox %s %v", r.Table, err)
+}
+
+// Reports whether the error is one of the errors of the database in the
+// classes of the invalid authorizations and of the syntax errors or access
+// rule violations, as an undefined table or an insufficient privilege.
+func unrecoverable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return strings.HasPrefix(pgErr.Code, "28") || strings.HasPrefix(pgErr.Code, "42")
+}
+
+func (r Relay) unpublished(ctx context.Context, tx pgx.Tx) ([]Message, error) {
+	rows, err := tx.Query(ctx, r.selectStmt(), r.BatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the outbox %w", err)
+	}
+	defer rows.Close()
+	messages := make([]Message, 0, r.BatchSize)
+	for rows.Next() {
+		var m Message
+		err = rows.Scan(&m.Id, &m.AggregateType, &m.AggregateId, &m.EventType, &m.Payload, &m.OccurredAt)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the outbox %w", err)
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+func (r Relay) selectStmt() string {
+	return fmt.Sprintf("SELECT id, aggregate_type, aggregate_id, event_type, payload, occurred_at FROM %s "+
+		"WHERE published_at IS NULL ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED;", r.Table)
+}
+
+func (r Relay) markStmt() string {
+	return fmt.Sprintf("UPDATE %s SET published_at = now() WHERE id = ANY($1);", r.Table)
+}
@@ -0,0 +1,163 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type fakeTx struct {
+	pgx.Tx
+	messages  []Message
+	marked    []int64
+	committed bool
+	queryErr  error
+	begun     int
+}
+
+func (tx *fakeTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx.begun++
+	return tx, nil
+}
+
+func (tx *fakeTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	if tx.queryErr != nil {
+		return nil, tx.queryErr
+	}
+	return &fakeRows{messages: tx.messages, i: -1}, nil
+}
+
+func (tx *fakeTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	tx.marked = args[0].([]int64)
+	return pgconn.CommandTag{}, nil
+}
+
+func (tx *fakeTx) Commit(ctx context.Context) error {
+	tx.committed = true
+	return nil
+}
+
+func (tx *fakeTx) Rollback(ctx context.Context) error {
+	return nil
+}
+
+type fakeRows struct {
+	pgx.Rows
+	messages []Message
+	i        int
+}
+
+func (r *fakeRows) Next() bool {
+	r.i++
+	return r.i < len(r.messages)
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	m := r.messages[r.i]
+	*dest[0].(*int64) = m.Id
+	*dest[1].(*string) = m.AggregateType
+	*dest[2].(*string) = m.AggregateId
+	*dest[3].(*string) = m.EventType
+	*dest[4].(*json.RawMessage) = m.Payload
+	*dest[5].(*time.Time) = m.OccurredAt
+	return nil
+}
+
+func (r *fakeRows) Close() {}
+
+func (r *fakeRows) Err() error {
+	return nil
+}
+
+type failingPublisher struct {
+	failAt    int64
+	published []int64
+}
+
+func (p *failingPublisher) Publish(ctx context.Context, message Message) error {
+	if message.Id == p.failAt {
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, message.Id)
+	return nil
+}
+
+func TestRelayOnce(t *testing.T) {
+	tests := []struct {
+		name      string
+		failAt    int64
+		published []int64
+		fails     bool
+	}{
+		{name: "all published", published: []int64{1, 2, 3}},
+		{name: "stops at the failed message", failAt: 2, published: []int64{1}, fails: true},
+		{name: "first message failed", failAt: 1, published: []int64{}, fails: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tx := &fakeTx{messages: []Message{
+				{Id: 1, EventType: "PersonCreated", Payload: []byte("{}")},
+				{Id: 2, EventType: "PersonRenamed", Payload: []byte("{}")},
+				{Id: 3, EventType: "PersonRenamed", Payload: []byte("{}")},
+			}}
+			publisher := &failingPublisher{failAt: test.failAt}
+			n, err := New(tx, publisher).RelayOnce(context.Background())
+			if (err != nil) != test.fails {
+				t.Fatalf("expected failure %v got %v", test.fails, err)
+			}
+			if n != len(test.published) {
+				t.Fatalf("expected %d published got %d", len(test.published), n)
+			}
+			if !slices.Equal(publisher.published, test.published) {
+				t.Fatalf("expected %v published got %v", test.published, publisher.published)
+			}
+			if !slices.Equal(tx.marked, test.published) {
+				t.Fatalf("expected %v marked got %v", test.published, tx.marked)
+			}
+			if !tx.committed {
+				t.Fatal("expected the transaction to be committed")
+			}
+		})
+	}
+}
+
+func TestRelay_Run(t *testing.T) {
+	t.Run("retries the failed publications", func(t *testing.T) {
+		tx := &fakeTx{messages: []Message{{Id: 1, EventType: "PersonCreated", Payload: []byte("{}")}}}
+		r := New(tx, &failingPublisher{failAt: 1})
+		r.Interval = time.Millisecond
+		ctx, cancel := context.WithCancel(context.Background())
+		reported := 0
+		r.OnError = func(err error) {
+			reported++
+			if reported == 3 {
+				cancel()
+			}
+		}
+		err := r.Run(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tx.begun < 3 {
+			t.Fatalf("expected the relay to keep polling got %d polls", tx.begun)
+		}
+	})
+	t.Run("stops at the unrecoverable errors", func(t *testing.T) {
+		tx := &fakeTx{queryErr: &pgconn.PgError{Code: "42P01"}}
+		r := New(tx, &failingPublisher{})
+		r.Interval = time.Millisecond
+		r.OnError = func(err error) {
+			t.Fatalf("unexpected retry of %v", err)
+		}
+		err := r.Run(context.Background())
+		if !errors.Is(err, tx.queryErr) {
+			t.Fatalf("expected the undefined table error got %v", err)
+		}
+	})
+}
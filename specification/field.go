@@ -0,0 +1,76 @@
+package specification
+
+import (
+	"clearly-not-a-secret-project/query_object"
+	"cmp"
+	"slices"
+)
+
+// Field is a column of the objects of type T whose value is read by get,
+// its specifications compare the value of the objects in memory and the
+// column of the rows. The data mappers generate the fields of their objects.
+type Field[T any, V comparable] struct {
+	column string
+	get    func(obj T) V
+}
+
+func NewField[T any, V comparable](column string, get func(obj T) V) Field[T, V] {
+	return Field[T, V]{column: column, get: get}
+}
+
+func (f Field[T, V]) Column() string {
+	return f.column
+}
+
+func (f Field[T, V]) Equals(value V) Specification[T] {
+	return New(func(obj T) bool {
+		return f.get(obj) == value
+	}, query_object.Equals(f.column, value))
+}
+
+func (f Field[T, V]) NotEquals(value V) Specification[T] {
+	return New(func(obj T) bool {
+		return f.get(obj) != value
+	}, query_object.NotEquals(f.column, value))
+}
+
+func (f Field[T, V]) In(values ...V) Specification[T] {
+	return New(func(obj T) bool {
+		return slices.Contains(values, f.get(obj))
+	}, query_object.In(f.column, values))
+}
+
+// OrderedField is a Field whose values are ordered, the strings are compared
+// by their bytes in memory so their columns must have the C collation for
+// the specifications to select the same objects they are satisfied by.
+type OrderedField[T any, V cmp.Ordered] struct {
+	Field[T, V]
+}
+
+func NewOrderedField[T any, V cmp.Ordered](column string, get func(obj T) V) OrderedField[T, V] {
+	return OrderedField[T, V]{Field: NewField(column, get)}
+}
+
+func (f OrderedField[T, V]) GreaterThan(value V) Specification[T] {
+	return New(func(obj T) bool {
+		return f.get(obj) > value
+	}, query_object.GreaterThan(f.column, value))
+}
+
+func (f OrderedField[T, V]) GreaterOrEquals(value V) Specification[T] {
+	return New(func(obj T) bool {
+		return f.get(obj) >= value
+	}, query_object.GreaterOrEquals(f.column, value))
+}
+
+func (f OrderedField[T, V]) LessThan(value V) Specification[T] {
+	return New(func(obj T) bool {
+		return f.get(obj) < value
+	}, query_object.LessThan(f.column, value))
+}
+
+func (f OrderedField[T, V]) LessOrEquals(value V) Specification[T] {
+	return New(func(obj T) bool {
+		return f.get(obj) <= value
+	}, query_object.LessOrEquals(f.column, value))
+}
@@ -0,0 +1,97 @@
+package specification
+
+import (
+	"clearly-not-a-secret-project/query_object"
+)
+
+// Specification is a rule of the domain satisfied by some objects of type T,
+// it's evaluated in memory by IsSatisfiedBy and its Criteria select the rows
+// of the objects satisfying it, so the same rule validates the objects and
+// finds them with FindMany.
+type Specification[T any] interface {
+	IsSatisfiedBy(obj T) bool
+	Criteria() query_object.Criteria
+}
+
+type specification[T any] struct {
+	satisfied func(obj T) bool
+	criteria  query_object.Criteria
+}
+
+func (s specification[T]) IsSatisfiedBy(obj T) bool {
+	return s.satisfied(obj)
+}
+
+func (s specification[T]) Criteria() query_object.Criteria {
+	return s.criteria
+}
+
+// New returns the specification evaluated by satisfied whose rows are
+// selected by criteria, both of them must express the same rule.
+func New[T any](satisfied func(obj T) bool, criteria query_object.Criteria) Specification[T] {
+	return specification[T]{satisfied: satisfied, criteria: criteria}
+}
+
+func criteriaOf[T any](specs []Specification[T]) []query_object.Criteria {
+	criteria := make([]query_object.Criteria, 0, len(specs))
+	for _, v := range specs {
+		criteria = append(criteria, v.Criteria())
+	}
+	return criteria
+}
+
+// And is satisfied by the objects satisfying all the specs, by any object without specs.
+func And[T any](specs ...Specification[T]) Specification[T] {
+	return specification[T]{
+		satisfied: func(obj T) bool {
+			for _, v := range specs {
+				if !v.IsSatisfiedBy(obj) {
+					return false
+				}
+			}
+			return true
+		},
+		criteria: query_object.And(criteriaOf(specs)...),
+	}
+}
+
+// Or is satisfied by the objects satisfying any of the specs, by none without specs.
+func Or[T any](specs ...Specification[T]) Specification[T] {
+	return specification[T]{
+		satisfied: func(obj T) bool {
+			for _, v := range specs {
+				if v.IsSatisfiedBy(obj) {
+					return true
+				}
+			}
+			return false
+		},
+		criteria: query_object.Or(criteriaOf(specs)...),
+	}
+}
+
+func Not[T any](spec Specification[T]) Specification[T] {
+	return specification[T]{
+		satisfied: func(obj T) bool {
+			return !spec.IsSatisfiedBy(obj)
+		},
+		criteria: query_object.Not(spec.Criteria()),
+	}
+}
+
+// Query returns a copy of q restricted to the rows of the objects
+// satisfying spec, to be found with FindMany.
+func Query[T any](q *query_object.QueryObject, spec Specification[T]) *query_object.QueryObject {
+	return q.Clone().Where(spec.Criteria())
+}
+
+// Filter returns the objects satisfying spec.
+func Filter[T any](objs []T, spec Specification[T]) []T {
+	result := make([]T, 0, len(objs))
+	for _, v := range objs {
+		if spec.IsSatisfiedBy(v) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,87 @@
+package specification
+
+import (
+	"clearly-not-a-secret-project/query_object"
+	"reflect"
+	"testing"
+)
+
+type invoice struct {
+	customer string
+	amount   int64
+}
+
+func (i *invoice) Customer() string { return i.customer }
+func (i *invoice) Amount() int64    { return i.amount }
+
+var invoiceFields = struct {
+	Customer OrderedField[*invoice, string]
+	Amount   OrderedField[*invoice, int64]
+}{
+	Customer: NewOrderedField("customer", (*invoice).Customer),
+	Amount:   NewOrderedField("amount", (*invoice).Amount),
+}
+
+func TestSpecification(t *testing.T) {
+	large := invoiceFields.Amount.GreaterOrEquals(100)
+	tests := map[string]struct {
+		spec      Specification[*invoice]
+		satisfied []bool
+		sql       string
+		params    []interface{}
+	}{
+		"field": {
+			spec:      large,
+			satisfied: []bool{true, false, true},
+			sql:       "SELECT customer, amount FROM invoice WHERE amount >= $1;",
+			params:    []interface{}{int64(100)},
+		},
+		"and": {
+			spec:      And(large, invoiceFields.Customer.Equals("acme")),
+			satisfied: []bool{true, false, false},
+			sql:       "SELECT customer, amount FROM invoice WHERE (amount >= $1 AND customer = $2);",
+			params:    []interface{}{int64(100), "acme"},
+		},
+		"or not": {
+			spec:      Or(Not(large), invoiceFields.Customer.In("initech")),
+			satisfied: []bool{false, true, true},
+			sql:       "SELECT customer, amount FROM invoice WHERE (NOT amount >= $1 OR customer = ANY($2));",
+			params:    []interface{}{int64(100), []string{"initech"}},
+		},
+		"empty": {
+			spec:      Or[*invoice](),
+			satisfied: []bool{false, false, false},
+			sql:       "SELECT customer, amount FROM invoice WHERE FALSE;",
+			params:    []interface{}{},
+		},
+	}
+	invoices := []*invoice{{"acme", 100}, {"acme", 10}, {"initech", 200}}
+	q := query_object.New("invoice", "customer", "amount")
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			for i, v := range invoices {
+				if tt.spec.IsSatisfiedBy(v) != tt.satisfied[i] {
+					t.Fatalf("expected the invoice %d satisfied %v", i, tt.satisfied[i])
+				}
+			}
+			query := Query(q, tt.spec)
+			if query.Sql() != tt.sql {
+				t.Fatalf("expected %s got %s", tt.sql, query.Sql())
+			}
+			if !reflect.DeepEqual(query.Parameters(), tt.params) {
+				t.Fatalf("expected %v got %v", tt.params, query.Parameters())
+			}
+		})
+	}
+	if q.Sql() != "SELECT customer, amount FROM invoice;" {
+		t.Fatalf("the specification modified the query %s", q.Sql())
+	}
+}
+
+func TestFilter(t *testing.T) {
+	invoices := []*invoice{{"acme", 100}, {"acme", 10}, {"initech", 200}}
+	filtered := Filter(invoices, invoiceFields.Customer.NotEquals("acme"))
+	if len(filtered) != 1 || filtered[0] != invoices[2] {
+		t.Fatalf("unexpected filtered invoices %v", filtered)
+	}
+}
@@ -27,3 +27,25 @@ func TestRegistry_Mapper(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestRegistry_Named(t *testing.T) {
+	pool, err := pre_generated_conn.CreatePool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadedMap := make(map[string]interfaces.DomainObject[string], 0)
+	newMapper := pre_generated_data_mapper.NewDomainAggregateDataMapper(pool, loadedMap)
+	reg, err := pre_generated_registry.Instance[string]()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.Register(newMapper)
+	_, err = reg.Named("clearly-not-a-secret-project/pre_generated/pre_generated_models/pre_generated_sub_domain.DomainAggregate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = reg.Named("pre_generated_sub_domain.DomainAggregate")
+	if err == nil {
+		t.Fatal("expected an error for a name without the package path")
+	}
+}